@@ -3,6 +3,9 @@ module github.com/jylc/cloudserver
 go 1.16
 
 require (
+	github.com/aws/aws-sdk-go v1.44.298
+	github.com/blevesearch/bleve/v2 v2.0.5
+	github.com/dhowden/tag v0.0.0-20240417053706-3d75831295e8
 	github.com/duo-labs/webauthn v0.0.0-20220330035159-03696f3d4499
 	github.com/gin-contrib/cors v1.4.0
 	github.com/gin-contrib/gzip v0.0.6
@@ -19,14 +22,17 @@ require (
 	github.com/mholt/archiver/v4 v4.0.0-alpha.7
 	github.com/mojocn/base64Captcha v0.0.0-20190801020520-752b1cd608b2
 	github.com/pelletier/go-toml/v2 v2.0.2 // indirect
+	github.com/pkg/sftp v1.13.5
 	github.com/pquerna/otp v1.3.0
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd
 	github.com/sirupsen/logrus v1.8.1
 	github.com/speps/go-hashids v2.0.0+incompatible
 	github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common v1.0.445
 	github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/scf v1.0.445
-	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
-	golang.org/x/net v0.0.0-20220630215102-69896b714898 // indirect
-	golang.org/x/sys v0.0.0-20220702020025-31831981b65f // indirect
+	github.com/tencentyun/cos-go-sdk-v5 v0.7.33
+	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d
+	golang.org/x/image v0.0.0-20190802002840-cff245a6509b
 	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 	gopkg.in/ini.v1 v1.66.6
 	gorm.io/driver/mysql v1.3.4
@@ -68,7 +68,7 @@ func uploadCallbackCheck(c *gin.Context, policyType string) serializer.Response
 
 	callbackSessionRaw, exist := cache.Get(filesystem.UploadSessionCachePrefix + sessionID)
 	if !exist {
-		serializer.ParamErr("upload session does not exist or has expired", nil)
+		return serializer.ParamErr("upload session does not exist or has expired", nil)
 	}
 
 	callbackSession := callbackSessionRaw.(serializer.UploadSession)
@@ -77,6 +77,22 @@ func BeforeShareDownload() gin.HandlerFunc {
 	}
 }
 
+// ShareNotAlbum 相册分享的内容只能通过相册相关的路由访问
+func ShareNotAlbum() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if share, ok := c.Get("share"); ok {
+			if !share.(*models.Share).IsAlbum {
+				c.Next()
+				return
+			}
+			c.JSON(200, serializer.Err(serializer.CodeNotFound, "share does not exist or has expired", nil))
+			c.Abort()
+			return
+		}
+		c.Abort()
+	}
+}
+
 func ShareCanPreview() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if share, ok := c.Get("share"); ok {
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// TakenAtLayout 元数据中拍摄时间的格式，EXIF 不含时区，按拍摄地的本地时间保存
+const TakenAtLayout = "2006-01-02 15:04:05"
+
+// photoOrder 按拍摄时间由新到旧排序，无拍摄时间时使用文件修改时间，
+// 兼容以 RFC3339 格式保存的拍摄时间
+const photoOrder = "replace(left(coalesce(case when json_valid(metadata) then json_unquote(json_extract(metadata, '$.taken_at')) end, " +
+	"date_format(updated_at, '%Y-%m-%d %H:%i:%s')), 19), 'T', ' ') desc, id desc"
+
+// Album 相册，仅引用文件而不改变文件所在位置
+type Album struct {
+	gorm.Model
+	Name   string
+	UserID uint `gorm:"index"`
+}
+
+// AlbumFile 相册与文件的关联
+type AlbumFile struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	AlbumID   uint `gorm:"index"`
+	FileID    uint `gorm:"index"`
+}
+
+// Create 创建相册
+func (album *Album) Create() (uint, error) {
+	if err := Db.Create(album).Error; err != nil {
+		return 0, err
+	}
+	return album.ID, nil
+}
+
+// GetAlbumByID 根据 ID 获取用户的相册
+func GetAlbumByID(id, uid uint) (*Album, error) {
+	var album Album
+	result := Db.Where("id = ? and user_id = ?", id, uid).First(&album)
+	return &album, result.Error
+}
+
+// GetAlbumsByUser 列出用户的所有相册
+func GetAlbumsByUser(uid uint) ([]Album, error) {
+	var albums []Album
+	result := Db.Where("user_id = ?", uid).Order("id desc").Find(&albums)
+	return albums, result.Error
+}
+
+// Rename 重命名相册
+func (album *Album) Rename(name string) error {
+	album.Name = name
+	return Db.Model(album).UpdateColumn("name", name).Error
+}
+
+// Delete 删除相册、相册关联及相册的分享，不影响相册中的文件
+func (album *Album) Delete() error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("album_id = ?", album.ID).Delete(&AlbumFile{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("source_id = ? and is_album = ?", album.ID, true).Delete(&Share{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(album).Error
+	})
+}
+
+// AddFiles 将用户的文件加入相册，已在相册中的文件会被忽略，返回实际加入的数量
+func (album *Album) AddFiles(fileIDs []uint) (int, error) {
+	files, err := GetFilesByIDs(fileIDs, album.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	var existed []uint
+	if err := Db.Model(&AlbumFile{}).Where("album_id = ? and file_id in (?)", album.ID, fileIDs).
+		Pluck("file_id", &existed).Error; err != nil {
+		return 0, err
+	}
+
+	existedMap := make(map[uint]bool, len(existed))
+	for _, id := range existed {
+		existedMap[id] = true
+	}
+
+	records := make([]AlbumFile, 0, len(files))
+	for _, file := range files {
+		if !existedMap[file.ID] {
+			existedMap[file.ID] = true
+			records = append(records, AlbumFile{AlbumID: album.ID, FileID: file.ID})
+		}
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return len(records), Db.Create(&records).Error
+}
+
+// RemoveFiles 将文件移出相册
+func (album *Album) RemoveFiles(fileIDs []uint) error {
+	return Db.Where("album_id = ? and file_id in (?)", album.ID, fileIDs).Delete(&AlbumFile{}).Error
+}
+
+// albumFiles 相册中文件的查询条件
+func (album *Album) albumFiles() *gorm.DB {
+	return Db.Model(&File{}).Where("user_id = ? and id in (?)", album.UserID,
+		Db.Model(&AlbumFile{}).Select("file_id").Where("album_id = ?", album.ID))
+}
+
+// ListFiles 分页列出相册中的文件，按上传时间由新到旧排列
+func (album *Album) ListFiles(page, pageSize int) ([]File, int) {
+	var (
+		files []File
+		total int64
+	)
+
+	album.albumFiles().Count(&total)
+	album.albumFiles().Order("id desc").Limit(pageSize).Offset((page - 1) * pageSize).Find(&files)
+	return files, int(total)
+}
+
+// HasFile 文件是否在相册中
+func (album *Album) HasFile(fileID uint) (*File, bool) {
+	var file File
+	if err := album.albumFiles().Where("id = ?", fileID).First(&file).Error; err != nil {
+		return nil, false
+	}
+	return &file, true
+}
+
+// DeleteAlbumFilesByFileIDs 删除文件与相册的关联
+func DeleteAlbumFilesByFileIDs(fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return Db.Where("file_id in (?)", fileIDs).Delete(&AlbumFile{}).Error
+}
+
+// TakenAt 获取照片的拍摄时间，无 EXIF 拍摄时间时使用文件修改时间。
+// 拍摄时间不含时区，返回值的日期与时刻即为拍摄地的本地时间
+func (file *File) TakenAt() time.Time {
+	if takenAt, ok := file.MetadataSerialized["taken_at"]; ok && len(takenAt) >= len(TakenAtLayout) {
+		// 早期以 RFC3339 格式保存，忽略其中的时区
+		takenAt = strings.Replace(takenAt[:len(TakenAtLayout)], "T", " ", 1)
+		if t, err := time.ParseInLocation(TakenAtLayout, takenAt, time.Local); err == nil {
+			return t
+		}
+	}
+	return file.UpdatedAt
+}
+
+// GetPhotos 按拍摄时间由新到旧分页列出用户文件名匹配 patterns 的照片，不含上传占位文件
+func GetPhotos(uid uint, patterns []string, page, pageSize int) ([]File, int, error) {
+	var (
+		files []File
+		total int64
+	)
+
+	conditions := make([]string, len(patterns))
+	args := make([]interface{}, len(patterns))
+	for i, pattern := range patterns {
+		conditions[i] = "name like ?"
+		args[i] = pattern
+	}
+
+	photos := func() *gorm.DB {
+		return Db.Model(&File{}).Where("user_id = ? and upload_session_id is NULL", uid).
+			Where("("+strings.Join(conditions, " or ")+")", args...)
+	}
+
+	if err := photos().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := photos().Order(photoOrder).Limit(pageSize).Offset((page - 1) * pageSize).Find(&files)
+	return files, int(total), result.Error
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFile_TakenAt(t *testing.T) {
+	updatedAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.Local)
+	testCases := []struct {
+		takenAt  string
+		expected time.Time
+	}{
+		{"2021-05-01 23:30:00", time.Date(2021, 5, 1, 23, 30, 0, 0, time.Local)},
+		// 早期的 RFC3339 格式按其中的本地时间解析，日期不随服务器时区变化
+		{"2021-05-01T23:30:00+08:00", time.Date(2021, 5, 1, 23, 30, 0, 0, time.Local)},
+		{"invalid", updatedAt},
+		{"", updatedAt},
+	}
+
+	for _, testCase := range testCases {
+		file := File{MetadataSerialized: map[string]string{}}
+		file.UpdatedAt = updatedAt
+		if testCase.takenAt != "" {
+			file.MetadataSerialized["taken_at"] = testCase.takenAt
+		}
+
+		if res := file.TakenAt(); !res.Equal(testCase.expected) {
+			t.Errorf("TakenAt(%q) = %s, want %s", testCase.takenAt, res, testCase.expected)
+		}
+	}
+}
+
+func TestGetPhotos(t *testing.T) {
+	var queries []string
+	useFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		queries = append(queries, query)
+		if strings.HasPrefix(query, "SELECT count(*)") {
+			return []string{"count"}, [][]driver.Value{{int64(25)}}
+		}
+		return []string{"id", "name"}, [][]driver.Value{{int64(1), "a.jpg"}}
+	})
+
+	files, total, err := GetPhotos(1, []string{"%.jpg", "%.png"}, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 25 || len(files) != 1 || files[0].Name != "a.jpg" {
+		t.Errorf("files = %v, total = %d", files, total)
+	}
+
+	// 排序及分页在数据库中完成
+	if len(queries) != 2 || !strings.Contains(queries[1], "ORDER BY "+photoOrder) || !strings.Contains(queries[1], "LIMIT 10 OFFSET 20") {
+		t.Errorf("unexpected queries %q", queries)
+	}
+	if !strings.Contains(queries[1], "(name like ? or name like ?)") {
+		t.Errorf("unexpected name conditions in %q", queries[1])
+	}
+}
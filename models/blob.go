@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+)
+
+// Blob 按内容寻址的物理文件，相同存储策略下内容相同的文件记录共用同一物理文件
+type Blob struct {
+	gorm.Model
+	PolicyID   uint   `gorm:"uniqueIndex:idx_blob_hash"`
+	SHA256     string `gorm:"size:64;uniqueIndex:idx_blob_hash"`
+	SourceName string `gorm:"type:text"`
+	Size       uint64
+	// RefCount 引用此物理文件的文件记录数量
+	RefCount int
+}
+
+// GetBlob 根据内容哈希查找存储策略下的物理文件
+func GetBlob(policyID uint, hash string) (*Blob, error) {
+	var blob Blob
+	result := Db.Where("policy_id = ? and sha256 = ? and ref_count > 0", policyID, hash).First(&blob)
+	return &blob, result.Error
+}
+
+// IsOwnedBy 用户自己的文件记录（含回收站中的文件）是否引用了此物理文件
+func (blob *Blob) IsOwnedBy(uid uint) bool {
+	var count int64
+	Db.Unscoped().Model(&File{}).
+		Where("policy_id = ? and source_name = ? and user_id = ?", blob.PolicyID, blob.SourceName, uid).
+		Count(&count)
+	return count > 0
+}
+
+// DeleteBlobsBySource 物理文件被删除后删除对应的记录，避免之后的去重引用已不存在的内容
+func DeleteBlobsBySource(policyID uint, sources []string) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	return Db.Unscoped().Where("policy_id = ? and source_name in (?)", policyID, sources).Delete(&Blob{}).Error
+}
+
+// refBlob 调整文件记录所引用物理文件的引用计数，计数归零时删除记录
+func refBlob(tx *gorm.DB, file *File, delta int) error {
+	if file.SHA256 == "" || file.UploadSessionID != nil {
+		return nil
+	}
+
+	var blob Blob
+	err := tx.Where("policy_id = ? and sha256 = ?", file.PolicyID, file.SHA256).First(&blob).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if delta <= 0 {
+			return nil
+		}
+
+		return tx.Create(&Blob{
+			PolicyID:   file.PolicyID,
+			SHA256:     file.SHA256,
+			SourceName: file.SourceName,
+			Size:       file.Size,
+			RefCount:   delta,
+		}).Error
+	} else if err != nil {
+		return err
+	}
+
+	// 内容相同但未共用物理文件的记录不计入引用
+	if blob.SourceName != file.SourceName {
+		return nil
+	}
+
+	if blob.RefCount+delta <= 0 {
+		return tx.Unscoped().Delete(&blob).Error
+	}
+	return tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count + ?", delta)).Error
+}
+
+// AfterCreate 创建文件记录后增加物理文件的引用计数
+func (file *File) AfterCreate(tx *gorm.DB) error {
+	return refBlob(tx, file, 1)
+}
+
+// AfterDelete 删除文件记录后减少物理文件的引用计数
+func (file *File) AfterDelete(tx *gorm.DB) error {
+	return refBlob(tx, file, -1)
+}
+
+// UpdateSHA256 文件内容变化后更新内容哈希及引用计数，hash 为空表示内容未知
+func (file *File) UpdateSHA256(hash string) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if err := refBlob(tx, file, -1); err != nil {
+			return err
+		}
+
+		if err := tx.Model(file).UpdateColumn("sha256", hash).Error; err != nil {
+			return err
+		}
+
+		file.SHA256 = hash
+		return refBlob(tx, file, 1)
+	})
+}
+
+// LinkBlob 将文件记录指向已有的相同内容物理文件
+func (file *File) LinkBlob(blob *Blob) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if err := refBlob(tx, file, -1); err != nil {
+			return err
+		}
+
+		if err := tx.Model(file).UpdateColumns(map[string]interface{}{
+			"sha256":      blob.SHA256,
+			"source_name": blob.SourceName,
+		}).Error; err != nil {
+			return err
+		}
+
+		file.SHA256 = blob.SHA256
+		file.SourceName = blob.SourceName
+		return refBlob(tx, file, 1)
+	})
+}
+
+// MigrateSource 将存储策略下引用同一物理文件的文件记录（含回收站中的文件）、历史版本及其内容索引
+// 一并指向新存储策略下的物理文件
+func MigrateSource(srcPolicy uint, source string, dstPolicy uint, dst string) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		values := map[string]interface{}{
+			"policy_id":   dstPolicy,
+			"source_name": dst,
+		}
+
+		if err := tx.Unscoped().Model(&File{}).Where("policy_id = ? and source_name = ?", srcPolicy, source).
+			UpdateColumns(values).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&FileVersion{}).Where("policy_id = ? and source_name = ?", srcPolicy, source).
+			UpdateColumns(values).Error; err != nil {
+			return err
+		}
+
+		var blob Blob
+		err := tx.Where("policy_id = ? and source_name = ?", srcPolicy, source).First(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// 新存储策略下已有相同内容的物理文件时，迁移后的记录不再参与去重
+		var existed int64
+		if err := tx.Model(&Blob{}).Where("policy_id = ? and sha256 = ?", dstPolicy, blob.SHA256).
+			Count(&existed).Error; err != nil {
+			return err
+		}
+		if existed > 0 {
+			return tx.Unscoped().Delete(&blob).Error
+		}
+		return tx.Model(&blob).UpdateColumns(values).Error
+	})
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceSourceName(t *testing.T) {
+	db := useFakeDB(t, nil)
+	if err := ReplaceSourceName(1, "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	execs := db.Execs()
+	if len(execs) != 5 || execs[0] != "BEGIN" || execs[4] != "COMMIT" {
+		t.Fatalf("statements should run in one transaction, got %q", execs)
+	}
+
+	// 回收站中的文件及历史版本同样需要更新
+	for i, table := range []string{"`files`", "`file_versions`", "`blobs`"} {
+		exec := execs[i+1]
+		if !strings.HasPrefix(exec, "UPDATE "+table+" SET `source_name`=") {
+			t.Errorf("statement %d = %q, want update of %s", i+1, exec, table)
+		}
+		if strings.Contains(exec, "deleted_at") && table != "`blobs`" {
+			t.Errorf("statement %q should be unscoped", exec)
+		}
+	}
+}
+
+func TestMigrateSource(t *testing.T) {
+	db := useFakeDB(t, nil)
+	if err := MigrateSource(1, "old", 2, "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	execs := db.Execs()
+	if len(execsContaining(execs, "UPDATE `files`")) != 1 || len(execsContaining(execs, "UPDATE `file_versions`")) != 1 {
+		t.Errorf("files and versions should be updated, got %q", execs)
+	}
+	if len(execsContaining(execs, "deleted_at")) != 0 {
+		t.Errorf("updates should be unscoped, got %q", execs)
+	}
+	if execs[0] != "BEGIN" || execs[len(execs)-1] != "COMMIT" {
+		t.Errorf("statements should run in one transaction, got %q", execs)
+	}
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB 记录执行的 SQL 语句，查询按语句返回预设的结果，用于在没有数据库的环境中测试
+type fakeDB struct {
+	mu    sync.Mutex
+	execs []string
+	// query 根据查询语句返回结果的列名及各行数据，为空时返回空结果
+	query func(query string, args []driver.NamedValue) ([]string, [][]driver.Value)
+}
+
+// useFakeDB 将 Db 替换为 fakeDB，测试结束后恢复
+func useFakeDB(t *testing.T, query func(query string, args []driver.NamedValue) ([]string, [][]driver.Value)) *fakeDB {
+	fake := &fakeDB{query: query}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(fake),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origin := Db
+	Db = db
+	t.Cleanup(func() { Db = origin })
+	return fake
+}
+
+// Execs 返回已执行的语句，事务的开始与结束记为 BEGIN、COMMIT 和 ROLLBACK
+func (db *fakeDB) Execs() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string(nil), db.execs...)
+}
+
+func (db *fakeDB) record(query string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.execs = append(db.execs, query)
+}
+
+func (db *fakeDB) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{db: db}, nil
+}
+
+func (db *fakeDB) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (conn *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (conn *fakeConn) Close() error {
+	return nil
+}
+
+func (conn *fakeConn) Begin() (driver.Tx, error) {
+	conn.db.record("BEGIN")
+	return conn, nil
+}
+
+func (conn *fakeConn) Commit() error {
+	conn.db.record("COMMIT")
+	return nil
+}
+
+func (conn *fakeConn) Rollback() error {
+	conn.db.record("ROLLBACK")
+	return nil
+}
+
+func (conn *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn.db.record(query)
+	return driver.RowsAffected(1), nil
+}
+
+func (conn *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows := &fakeRows{}
+	if conn.db.query != nil {
+		rows.columns, rows.values = conn.db.query(query, args)
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (rows *fakeRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *fakeRows) Close() error {
+	return nil
+}
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if len(rows.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, rows.values[0])
+	rows.values = rows.values[1:]
+	return nil
+}
+
+// execsContaining 筛选包含 substr 的语句
+func execsContaining(execs []string, substr string) []string {
+	var res []string
+	for _, exec := range execs {
+		if strings.Contains(exec, substr) {
+			res = append(res, exec)
+		}
+	}
+	return res
+}
@@ -80,6 +80,14 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 	{Name: "share_view_method", Value: "list", Type: "view"},
 	{Name: "cron_garbage_collect", Value: "@hourly", Type: "cron"},
 	{Name: "cron_recycle_upload_session", Value: "@every 1h30m", Type: "cron"},
+	{Name: "cron_purge_trash", Value: "@every 1h", Type: "cron"},
+	{Name: "cron_scrub_storage", Value: "@weekly", Type: "cron"},
+	{Name: "cron_recalc_storage", Value: "@daily", Type: "cron"},
+	{Name: "cron_lifecycle", Value: "@daily", Type: "cron"},
+	{Name: "cron_mirror_repair", Value: "@weekly", Type: "cron"},
+	{Name: "lifecycle_batch_size", Value: "1000", Type: "lifecycle"},
+	{Name: "scrub_rehash", Value: "0", Type: "scrub"},
+	{Name: "reconcile_quarantine_path", Value: "quarantine", Type: "scrub"},
 	{Name: "authn_enabled", Value: "0", Type: "authn"},
 	{Name: "captcha_type", Value: "normal", Type: "captcha"},
 	{Name: "captcha_height", Value: "60", Type: "captcha"},
@@ -106,6 +114,21 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 	{Name: "thumb_encode_method", Value: "jpg", Type: "thumb"},
 	{Name: "thumb_gc_after_gen", Value: "0", Type: "thumb"},
 	{Name: "thumb_encode_quality", Value: "85", Type: "thumb"},
+	{Name: "thumb_max_pixels", Value: "50000000", Type: "thumb"},
+	{Name: "thumb_ffmpeg_enabled", Value: "0", Type: "thumb"},
+	{Name: "thumb_ffmpeg_path", Value: "ffmpeg", Type: "thumb"},
+	{Name: "thumb_ffmpeg_seek", Value: "00:00:01.00", Type: "thumb"},
+	{Name: "thumb_pdf_enabled", Value: "0", Type: "thumb"},
+	{Name: "thumb_pdf_path", Value: "pdftoppm", Type: "thumb"},
+	{Name: "metadata_ffprobe_enabled", Value: "0", Type: "metadata"},
+	{Name: "metadata_ffprobe_path", Value: "ffprobe", Type: "metadata"},
+	{Name: "search_content_enabled", Value: "0", Type: "search"},
+	{Name: "search_index_path", Value: "search.bleve", Type: "search"},
+	{Name: "search_content_max_size", Value: "10485760", Type: "search"},
+	{Name: "search_content_max_results", Value: "100", Type: "search"},
+	{Name: "search_pdf_enabled", Value: "0", Type: "search"},
+	{Name: "search_pdf_path", Value: "pdftotext", Type: "search"},
+	{Name: "recent_files_max", Value: "50", Type: "explorer"},
 	{Name: "pwa_small_icon", Value: "/static/img/favicon.ico", Type: "pwa"},
 	{Name: "pwa_medium_icon", Value: "/static/img/logo192.png", Type: "pwa"},
 	{Name: "pwa_large_icon", Value: "/static/img/logo512.png", Type: "pwa"},
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"github.com/jylc/cloudserver/pkg/utils"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Favorite 用户收藏的文件或目录
+type Favorite struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint `gorm:"index"`
+	ObjectID  uint `gorm:"index"`
+	IsFolder  bool
+}
+
+// AddFavorites 收藏文件及目录，已收藏的对象会被忽略
+func AddFavorites(uid uint, folderIDs, fileIDs []uint) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		for _, group := range []struct {
+			ids      []uint
+			isFolder bool
+		}{{folderIDs, true}, {fileIDs, false}} {
+			if len(group.ids) == 0 {
+				continue
+			}
+
+			var existed []uint
+			if err := tx.Model(&Favorite{}).Where("user_id = ? and is_folder = ? and object_id in (?)",
+				uid, group.isFolder, group.ids).Pluck("object_id", &existed).Error; err != nil {
+				return err
+			}
+
+			records := make([]Favorite, 0, len(group.ids))
+			for _, id := range group.ids {
+				if !utils.ContainsUint(existed, id) {
+					existed = append(existed, id)
+					records = append(records, Favorite{UserID: uid, ObjectID: id, IsFolder: group.isFolder})
+				}
+			}
+
+			if len(records) > 0 {
+				if err := tx.Create(&records).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveFavorites 取消收藏文件及目录
+func RemoveFavorites(uid uint, folderIDs, fileIDs []uint) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if len(folderIDs) > 0 {
+			if err := tx.Where("user_id = ? and is_folder = ? and object_id in (?)", uid, true, folderIDs).
+				Delete(&Favorite{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(fileIDs) > 0 {
+			return tx.Where("user_id = ? and is_folder = ? and object_id in (?)", uid, false, fileIDs).
+				Delete(&Favorite{}).Error
+		}
+		return nil
+	})
+}
+
+// GetFavorites 列出用户收藏的目录和文件，按收藏时间倒序排列
+func GetFavorites(uid uint) ([]Folder, []File, error) {
+	var records []Favorite
+	if err := Db.Where("user_id = ?", uid).Order("id desc").Find(&records).Error; err != nil {
+		return nil, nil, err
+	}
+
+	folderIDs, fileIDs := make([]uint, 0), make([]uint, 0)
+	for _, record := range records {
+		if record.IsFolder {
+			folderIDs = append(folderIDs, record.ObjectID)
+		} else {
+			fileIDs = append(fileIDs, record.ObjectID)
+		}
+	}
+
+	var (
+		folders []Folder
+		files   []File
+	)
+	if len(folderIDs) > 0 {
+		res, err := GetFoldersByIDs(folderIDs, uid)
+		if err != nil {
+			return nil, nil, err
+		}
+		folderMap := make(map[uint]Folder, len(res))
+		for _, folder := range res {
+			folderMap[folder.ID] = folder
+		}
+		for _, id := range folderIDs {
+			if folder, ok := folderMap[id]; ok {
+				folders = append(folders, folder)
+			}
+		}
+	}
+	if len(fileIDs) > 0 {
+		res, err := GetFilesByIDs(fileIDs, uid)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = sortFilesByIDs(fileIDs, res)
+	}
+
+	return folders, files, nil
+}
+
+// DeleteFavoritesByObjects 删除已删除的文件或目录的收藏记录
+func DeleteFavoritesByObjects(ids []uint, isFolder bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return Db.Where("object_id in (?) and is_folder = ?", ids, isFolder).Delete(&Favorite{}).Error
+}
@@ -1,10 +1,13 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"path"
+	"strings"
 	"time"
 )
 
@@ -19,12 +22,58 @@ type File struct {
 	PolicyID        uint
 	UploadSessionID *string `gorm:"index:session_id;unique_index:session_only_one"`
 	Metadata        string  `gorm:"type:text"`
+	SHA256          string  `gorm:"size:64;index"`
+	// AccessedAt 最近一次下载或预览的时间
+	AccessedAt *time.Time `gorm:"index"`
 
 	Policy             Policy            `gorm:"PRELOAD:false,association_autoupdate:false"`
 	Position           string            `gorm:"-"`
 	MetadataSerialized map[string]string `gorm:"-"`
 }
 
+// AfterFind 找到文件后的钩子
+func (file *File) AfterFind(tx *gorm.DB) (err error) {
+	if file.Metadata != "" {
+		err = json.Unmarshal([]byte(file.Metadata), &file.MetadataSerialized)
+	}
+	return err
+}
+
+// BeforeSave 保存文件前的钩子
+func (file *File) BeforeSave(tx *gorm.DB) (err error) {
+	if len(file.MetadataSerialized) > 0 {
+		metaValue, err := json.Marshal(&file.MetadataSerialized)
+		file.Metadata = string(metaValue)
+		return err
+	}
+	return nil
+}
+
+// UpdateMetadata 使用新提取的元数据替换文件原有的元数据
+func (file *File) UpdateMetadata(data map[string]string) error {
+	metaValue, err := json.Marshal(&data)
+	if err != nil {
+		return err
+	}
+
+	file.MetadataSerialized = data
+	file.Metadata = string(metaValue)
+	return Db.Model(file).UpdateColumn("metadata", file.Metadata).Error
+}
+
+// Touch 记录文件的访问时间，一小时内的重复访问不再更新
+func (file *File) Touch() {
+	now := time.Now()
+	if file.ID == 0 || (file.AccessedAt != nil && now.Sub(*file.AccessedAt) < time.Hour) {
+		return
+	}
+
+	file.AccessedAt = &now
+	if err := Db.Model(file).UpdateColumn("accessed_at", now).Error; err != nil {
+		logrus.Warningf("Unable to update access time of [%s], %s", file.Name, err)
+	}
+}
+
 func (file *File) GetSize() uint64 {
 	return file.Size
 }
@@ -126,33 +175,55 @@ func GetFilesByUploadSession(sessionID string, uid uint) (*File, error) {
 
 func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 	filteredFiles := make([]File, 0)
+	if len(files) == 0 {
+		return filteredFiles, nil
+	}
 
+	ids := make([]uint, 0, len(files))
+	for _, value := range files {
+		ids = append(ids, value.ID)
+	}
+
+	// 同一批次中删除的记录不视为软链接，回收站中的记录仍引用物理文件
 	var filesWithSoftLinks []File
 	tx := Db
 	for _, value := range files {
-		tx = tx.Or("source_name = ? and policy_id = ? and id != ?", value.SourceName, value.PolicyID, value.ID)
+		tx = tx.Or("source_name = ? and policy_id = ?", value.SourceName, value.PolicyID)
 	}
-	result := tx.Find(&filesWithSoftLinks)
+	result := Db.Unscoped().Where(tx).Where("id not in (?)", ids).Find(&filesWithSoftLinks)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
-	if len(filesWithSoftLinks) == 0 {
-		filteredFiles = files
-	} else {
-		for i := 0; i < len(files); i++ {
-			finder := false
-			for _, value := range filesWithSoftLinks {
-				if value.PolicyID == files[i].PolicyID && value.SourceName == files[i].SourceName {
-					finder = true
-					break
-				}
-			}
+	// 历史版本同样引用物理文件
+	var versions []FileVersion
+	if err := Db.Where(tx).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		filesWithSoftLinks = append(filesWithSoftLinks, File{PolicyID: version.PolicyID, SourceName: version.SourceName})
+	}
 
-			if !finder {
-				filteredFiles = append(filteredFiles, files[i])
+	// 多条记录引用同一物理文件时只保留一条
+	seen := make(map[string]bool, len(files))
+	for i := 0; i < len(files); i++ {
+		key := fmt.Sprintf("%d/%s", files[i].PolicyID, files[i].SourceName)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		finder := false
+		for _, value := range filesWithSoftLinks {
+			if value.PolicyID == files[i].PolicyID && value.SourceName == files[i].SourceName {
+				finder = true
+				break
 			}
 		}
+
+		if !finder {
+			filteredFiles = append(filteredFiles, files[i])
+		}
 	}
 
 	return filteredFiles, nil
@@ -228,8 +299,29 @@ func (folder *Folder) GetChildFile(name string) (*File, error) {
 	return &file, result.Error
 }
 
+// UpdatePicInfo 更新文件的图像信息
+func (file *File) UpdatePicInfo(value string) error {
+	file.PicInfo = value
+	return Db.Model(file).UpdateColumn("pic_info", value).Error
+}
+
+// UpdateSourceName 更新文件记录引用的物理文件，原物理文件的引用随之释放
 func (file *File) UpdateSourceName(value string) error {
-	return Db.Model(&file).Set("gorm:association_autoupdate", false).Update("source_name", value).Error
+	return Db.Transaction(func(tx *gorm.DB) error {
+		// 调用方可能已修改了 file 中的路径，以数据库中的记录为准
+		var origin File
+		if err := tx.Unscoped().First(&origin, file.ID).Error; err != nil {
+			return err
+		}
+
+		if origin.SourceName != value {
+			if err := refBlob(tx, &origin, -1); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(file).Set("gorm:association_autoupdate", false).Update("source_name", value).Error
+	})
 }
 
 func (folder *Folder) Rename(new string) error {
@@ -255,6 +347,193 @@ func GetUploadPlaceholderFiles(uid uint) []*File {
 	return files
 }
 
+// GetFilesByPolicy 按 ID 顺序分批获取存储策略下 ID 大于 after 的已上传文件
+func GetFilesByPolicy(policyID, after uint, limit int) ([]File, error) {
+	var files []File
+	result := Db.Where("policy_id = ? and id > ? and upload_session_id is NULL", policyID, after).
+		Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// GetAllFilesByPolicy 分批获取存储策略下包括回收站中在内的所有文件，不含上传占位文件，
+// ids 不为空时仅获取其中的文件
+func GetAllFilesByPolicy(policyID uint, ids []uint, after uint, limit int) ([]File, error) {
+	var files []File
+	tx := Db.Unscoped().Where("policy_id = ? and id > ? and upload_session_id is NULL", policyID, after)
+	if len(ids) > 0 {
+		tx = tx.Where("id in (?)", ids)
+	}
+	result := tx.Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// FileFilter 结构化搜索的筛选条件，不同条件之间为且关系
+type FileFilter struct {
+	// Names 文件名 LIKE 模式，满足任一即可
+	Names []string
+	// Keywords 文件名需包含的关键字
+	Keywords []string
+	// PatternGroups 每组文件名 LIKE 模式满足其一即可，用于标签筛选
+	PatternGroups [][]string
+	// TagIDs 文件需带有的手动标签
+	TagIDs []uint
+	// Parents 限定文件所在的目录
+	Parents []uint
+	// MinSize、MaxSize 文件大小范围（含边界）
+	MinSize *uint64
+	MaxSize *uint64
+	// UpdatedAfter、UpdatedBefore 修改时间范围，前闭后开
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// CreatedAfter、CreatedBefore 创建时间范围，前闭后开
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// likeAny 构建满足任一 LIKE 模式的条件
+func likeAny(column string, patterns []string) (string, []interface{}) {
+	conditions := make([]string, len(patterns))
+	args := make([]interface{}, len(patterns))
+	for i, pattern := range patterns {
+		conditions[i] = column + " like ?"
+		args[i] = pattern
+	}
+	return "(" + strings.Join(conditions, " or ") + ")", args
+}
+
+// apply 将筛选条件应用至查询
+func (filter *FileFilter) apply(tx *gorm.DB) *gorm.DB {
+	if len(filter.Names) > 0 {
+		condition, args := likeAny("name", filter.Names)
+		tx = tx.Where(condition, args...)
+	}
+	for _, keyword := range filter.Keywords {
+		tx = tx.Where("name like ?", "%"+keyword+"%")
+	}
+	for _, group := range filter.PatternGroups {
+		condition, args := likeAny("name", group)
+		tx = tx.Where(condition, args...)
+	}
+	for _, tagID := range filter.TagIDs {
+		tx = tx.Where("id in (?)", Db.Model(&ObjectTag{}).Select("object_id").
+			Where("tag_id = ? and is_folder = ?", tagID, false))
+	}
+	if len(filter.Parents) > 0 {
+		tx = tx.Where("folder_id in (?)", filter.Parents)
+	}
+	if filter.MinSize != nil {
+		tx = tx.Where("size >= ?", *filter.MinSize)
+	}
+	if filter.MaxSize != nil {
+		tx = tx.Where("size <= ?", *filter.MaxSize)
+	}
+	if filter.UpdatedAfter != nil {
+		tx = tx.Where("updated_at >= ?", *filter.UpdatedAfter)
+	}
+	if filter.UpdatedBefore != nil {
+		tx = tx.Where("updated_at < ?", *filter.UpdatedBefore)
+	}
+	if filter.CreatedAfter != nil {
+		tx = tx.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		tx = tx.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	return tx
+}
+
+// GetFilesByFilter 根据结构化筛选条件分页搜索用户的文件，pageSize 为 0 时不分页
+func GetFilesByFilter(uid uint, filter *FileFilter, page, pageSize int, order string) ([]File, int, error) {
+	var (
+		files []File
+		total int64
+	)
+
+	query := func() *gorm.DB {
+		return filter.apply(Db.Model(&File{}).Where("user_id = ? and upload_session_id is NULL", uid))
+	}
+	if err := query().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tx := query()
+	if pageSize > 0 {
+		tx = tx.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+	result := tx.Order(order).Find(&files)
+	return files, int(total), result.Error
+}
+
+// GetFilesAfter 按 ID 顺序分批获取所有已上传完成的文件
+func GetFilesAfter(after uint, limit int) ([]File, error) {
+	var files []File
+	result := Db.Where("id > ? and upload_session_id is NULL", after).
+		Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// ReplaceSourceName 更新存储策略下所有引用同一物理文件的文件记录（含回收站中的文件）、历史版本及其内容索引
+func ReplaceSourceName(policyID uint, origin, value string) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&File{}).Where("policy_id = ? and source_name = ?", policyID, origin).
+			UpdateColumn("source_name", value).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&FileVersion{}).Where("policy_id = ? and source_name = ?", policyID, origin).
+			UpdateColumn("source_name", value).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&Blob{}).Where("policy_id = ? and source_name = ?", policyID, origin).
+			UpdateColumn("source_name", value).Error
+	})
+}
+
+// GetFilesByMetadata 根据元数据字段搜索文件，value 为空时仅匹配包含该字段的文件
+func GetFilesByMetadata(uid uint, parents []uint, key, value string) ([]File, error) {
+	var files []File
+	result := Db.Where("user_id = ?", uid)
+	if len(parents) > 0 {
+		result = result.Where("folder_id in (?)", parents)
+	}
+
+	// 元数据以 JSON 保存，按与保存时相同的序列化形式匹配
+	encodedKey, _ := json.Marshal(key)
+	pattern := "%" + escapeLike(string(encodedKey)) + ":%"
+	if value != "" {
+		encodedValue, _ := json.Marshal(value)
+		pattern = "%" + escapeLike(string(encodedKey)) + ":\"%" + escapeLike(strings.Trim(string(encodedValue), `"`)) + "%\"%"
+	}
+
+	if err := result.Where("metadata like ? escape '!'", pattern).Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	// LIKE 的通配部分可能跨越到其他字段，按解析后的字段值再次过滤
+	return filterFilesByMetadata(files, key, value), nil
+}
+
+// filterFilesByMetadata 筛选包含元数据字段 key 且字段值包含 value（不区分大小写）的文件
+func filterFilesByMetadata(files []File, key, value string) []File {
+	filtered := files[:0]
+	for _, file := range files {
+		fieldValue, ok := file.MetadataSerialized[key]
+		if ok && strings.Contains(strings.ToLower(fieldValue), strings.ToLower(value)) {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
+
+// likeEscaper 转义 LIKE 语句中的通配符，转义字符为 !
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// escapeLike 转义字符串，使其在 LIKE 语句中按原文匹配
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
 func GetFilesByKeywords(uid uint, parents []uint, keywords ...interface{}) ([]File, error) {
 	var (
 		files      []File
@@ -0,0 +1,36 @@
+package models
+
+import "testing"
+
+func TestFilterFilesByMetadata(t *testing.T) {
+	files := []File{
+		{Name: "a.jpg", MetadataSerialized: map[string]string{"camera": "Canon EOS", "lens": "50mm"}},
+		{Name: "b.jpg", MetadataSerialized: map[string]string{"camera": "Nikon", "lens": "Canon adapter"}},
+		{Name: "c.jpg"},
+	}
+
+	testCases := []struct {
+		key, value string
+		expected   []string
+	}{
+		{"camera", "", []string{"a.jpg", "b.jpg"}},
+		{"camera", "canon", []string{"a.jpg"}},
+		{"lens", "canon", []string{"b.jpg"}},
+		{"camera", "EOS\",\"lens", nil},
+		{"missing", "", nil},
+	}
+
+	for _, testCase := range testCases {
+		input := append([]File(nil), files...)
+		filtered := filterFilesByMetadata(input, testCase.key, testCase.value)
+		if len(filtered) != len(testCase.expected) {
+			t.Errorf("%s=%s: got %d files, want %v", testCase.key, testCase.value, len(filtered), testCase.expected)
+			continue
+		}
+		for i, file := range filtered {
+			if file.Name != testCase.expected[i] {
+				t.Errorf("%s=%s: file %d = %s, want %s", testCase.key, testCase.value, i, file.Name, testCase.expected[i])
+			}
+		}
+	}
+}
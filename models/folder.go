@@ -174,12 +174,14 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 		return 0, err
 	}
 
+	fileIDCache := make(map[uint]uint, len(originFiles))
 	for _, oldFile := range originFiles {
 		if !oldFile.CanCopy() {
 			logrus.Warningf("Unable to copy the file being uploaded [%s], skipping", oldFile.Name)
 			continue
 		}
 
+		originID := oldFile.ID
 		oldFile.Model = gorm.Model{}
 		oldFile.FolderID = newIDCache[oldFile.FolderID]
 		oldFile.UserID = dstFolder.OwnerID
@@ -187,8 +189,19 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 			return size, err
 		}
 
+		fileIDCache[originID] = oldFile.ID
 		size += oldFile.Size
 	}
+
+	// 同一用户内复制时保留标签
+	if folder.OwnerID == dstFolder.OwnerID {
+		if err := copyObjectTags(newIDCache, true); err != nil {
+			return size, err
+		}
+		if err := copyObjectTags(fileIDCache, false); err != nil {
+			return size, err
+		}
+	}
 	return size, nil
 }
 
@@ -205,12 +218,14 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 			return 0, err
 		}
 
+		fileIDCache := make(map[uint]uint, len(originFiles))
 		for _, oldFile := range originFiles {
 			if !oldFile.CanCopy() {
 				logrus.Warningf("Unable to copy the file being uploaded [%s], skipping", oldFile.Name)
 				continue
 			}
 
+			originID := oldFile.ID
 			oldFile.Model = gorm.Model{}
 			oldFile.FolderID = dstFolder.ID
 			oldFile.UserID = dstFolder.OwnerID
@@ -218,8 +233,16 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 			if err := Db.Create(&oldFile).Error; err != nil {
 				return copiedSize, err
 			}
+			fileIDCache[originID] = oldFile.ID
 			copiedSize += oldFile.Size
 		}
+
+		// 同一用户内复制时保留标签
+		if folder.OwnerID == dstFolder.OwnerID {
+			if err := copyObjectTags(fileIDCache, false); err != nil {
+				return copiedSize, err
+			}
+		}
 	} else {
 		err := Db.Model(File{}).Where(
 			"id in (?) and user_id = ? and folder_id = ?",
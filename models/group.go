@@ -1,6 +1,9 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"encoding/json"
+	"gorm.io/gorm"
+)
 
 type Group struct {
 	gorm.Model
@@ -27,6 +30,9 @@ type GroupOption struct {
 	Aria2Options    map[string]interface{} `json:"aria2_options,omitempty"` // 离线下载用户组配置
 	SourceBatchSize int                    `json:"source_batch,omitempty"`
 	Aria2BatchSize  int                    `json:"aria2_batch,omitempty"`
+	TrashRetention  int                    `json:"trash_retention,omitempty"`  // 回收站保留天数，0 为不自动清除
+	MaxVersions     int                    `json:"max_versions,omitempty"`     // 单个文件保留的历史版本数量，0 为不保留
+	VersionCapacity bool                   `json:"version_capacity,omitempty"` // 历史版本是否计入容量
 }
 
 func GetGroupByID(ID interface{}) (Group, error) {
@@ -34,3 +40,31 @@ func GetGroupByID(ID interface{}) (Group, error) {
 	result := Db.First(&group, ID)
 	return group, result.Error
 }
+
+// AfterFind 找到用户组后的钩子
+func (group *Group) AfterFind(tx *gorm.DB) (err error) {
+	if group.Policies != "" {
+		err = json.Unmarshal([]byte(group.Policies), &group.PolicyList)
+	}
+	if err != nil {
+		return err
+	}
+
+	if group.Options != "" {
+		err = json.Unmarshal([]byte(group.Options), &group.OptionsSerialized)
+	}
+	return err
+}
+
+// BeforeSave 保存用户组前的钩子
+func (group *Group) BeforeSave(tx *gorm.DB) (err error) {
+	policies, err := json.Marshal(&group.PolicyList)
+	if err != nil {
+		return err
+	}
+	group.Policies = string(policies)
+
+	options, err := json.Marshal(&group.OptionsSerialized)
+	group.Options = string(options)
+	return err
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"encoding/json"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+const (
+	// LifecycleTransition 将长期未访问的文件迁移至其他存储策略
+	LifecycleTransition = "transition"
+	// LifecycleExpire 删除长期未修改的文件
+	LifecycleExpire = "expire"
+)
+
+// LifecycleRule 存储生命周期规则，作用范围由存储策略、用户组及目录共同限定，为空时不限
+type LifecycleRule struct {
+	gorm.Model
+	Name    string
+	Enabled bool
+	// PolicyID 仅作用于该存储策略下的文件
+	PolicyID uint
+	// GroupID 仅作用于该用户组下用户的文件
+	GroupID uint
+	// Path 仅作用于各用户该目录及其下级目录中的文件
+	Path   string `gorm:"type:text"`
+	Action string
+	// Days 迁移规则为文件未被访问的天数，删除规则为文件未被修改的天数
+	Days int
+	// TargetPolicyID 迁移规则的目标存储策略
+	TargetPolicyID uint
+	// Cursor 上次评估到的最后一个文件 ID，下次从其后继续，所有文件评估完后从头开始
+	Cursor uint
+	// TaskIDs 上次评估创建的任务 ID 列表，以 JSON 保存，任务未结束前不再评估此规则
+	TaskIDs string `gorm:"type:text"`
+}
+
+// GetLifecycleRules 获取所有生命周期规则
+func GetLifecycleRules() ([]LifecycleRule, error) {
+	var rules []LifecycleRule
+	result := Db.Order("id asc").Find(&rules)
+	return rules, result.Error
+}
+
+// GetEnabledLifecycleRules 获取已启用的生命周期规则
+func GetEnabledLifecycleRules() ([]LifecycleRule, error) {
+	var rules []LifecycleRule
+	result := Db.Where("enabled = ?", true).Order("id asc").Find(&rules)
+	return rules, result.Error
+}
+
+// DeleteLifecycleRule 删除生命周期规则
+func DeleteLifecycleRule(id uint) error {
+	return Db.Delete(&LifecycleRule{}, id).Error
+}
+
+// Tasks 上次评估创建的任务 ID
+func (rule *LifecycleRule) Tasks() []uint {
+	var ids []uint
+	if rule.TaskIDs != "" {
+		_ = json.Unmarshal([]byte(rule.TaskIDs), &ids)
+	}
+	return ids
+}
+
+// UpdateProgress 记录本次评估的进度及创建的任务
+func (rule *LifecycleRule) UpdateProgress(cursor uint, tasks []uint) error {
+	taskIDs, _ := json.Marshal(tasks)
+	rule.Cursor, rule.TaskIDs = cursor, string(taskIDs)
+	return Db.Model(rule).UpdateColumns(map[string]interface{}{
+		"cursor":   rule.Cursor,
+		"task_ids": rule.TaskIDs,
+	}).Error
+}
+
+// Candidates 列出 ID 在 after 之后满足规则的文件，最多 limit 个
+func (rule *LifecycleRule) Candidates(after uint, limit int) ([]File, error) {
+	tx, err := rule.scope()
+	if tx == nil || err != nil {
+		return nil, err
+	}
+
+	var files []File
+	result := tx.Where("id > ?", after).Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// Siblings 列出与 files 共用物理文件且同样满足规则的其他文件，共用的物理文件须随所有引用一同迁移
+func (rule *LifecycleRule) Siblings(files []File) ([]File, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	tx, err := rule.scope()
+	if tx == nil || err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(files))
+	sources := Db
+	for _, file := range files {
+		ids = append(ids, file.ID)
+		sources = sources.Or("policy_id = ? and source_name = ?", file.PolicyID, file.SourceName)
+	}
+
+	var siblings []File
+	result := tx.Where(sources).Where("id not in (?)", ids).Find(&siblings)
+	return siblings, result.Error
+}
+
+// scope 满足规则的文件查询条件，规则目录不存在时返回空
+func (rule *LifecycleRule) scope() (*gorm.DB, error) {
+	threshold := time.Now().AddDate(0, 0, -rule.Days)
+	tx := Db.Where("upload_session_id is NULL")
+	if rule.PolicyID > 0 {
+		tx = tx.Where("policy_id = ?", rule.PolicyID)
+	}
+
+	if rule.GroupID > 0 {
+		tx = tx.Where("user_id in (?)", rule.groupUsers())
+	}
+
+	if strings.Trim(rule.Path, "/") != "" {
+		folders, err := rule.folders()
+		if err != nil || len(folders) == 0 {
+			return nil, err
+		}
+		tx = tx.Where("folder_id in (?)", folders)
+	}
+
+	if rule.Action == LifecycleTransition {
+		tx = tx.Where("policy_id <> ? and coalesce(accessed_at, created_at) < ?", rule.TargetPolicyID, threshold)
+	} else {
+		tx = tx.Where("updated_at < ?", threshold)
+	}
+	return tx, nil
+}
+
+func (rule *LifecycleRule) groupUsers() *gorm.DB {
+	return Db.Model(&User{}).Select("id").Where("group_id = ?", rule.GroupID)
+}
+
+// folders 列出各用户规则目录及其下级目录的 ID
+func (rule *LifecycleRule) folders() ([]uint, error) {
+	tx := Db.Model(&Folder{}).Where("parent_id is NULL")
+	if rule.GroupID > 0 {
+		tx = tx.Where("owner_id in (?)", rule.groupUsers())
+	}
+
+	var ids []uint
+	if err := tx.Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(strings.Trim(rule.Path, "/"), "/") {
+		if len(ids) == 0 {
+			return ids, nil
+		}
+
+		var next []uint
+		if err := Db.Model(&Folder{}).Where("parent_id in (?) and name = ?", ids, name).Pluck("id", &next).Error; err != nil {
+			return nil, err
+		}
+		ids = next
+	}
+
+	res := append([]uint{}, ids...)
+	for len(ids) > 0 {
+		var children []uint
+		if err := Db.Model(&Folder{}).Where("parent_id in (?)", ids).Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+		res = append(res, children...)
+		ids = children
+	}
+	return res, nil
+}
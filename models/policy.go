@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"github.com/gofrs/uuid"
 	"github.com/jylc/cloudserver/pkg/cache"
 	"github.com/jylc/cloudserver/pkg/utils"
@@ -60,6 +61,24 @@ type PolicyOption struct {
 	TPSLimit float64 `json:"tps_limit,omitempty"`
 	// 每秒 API 请求爆发上限
 	TPSLimitBurst int `json:"tps_limit_burst,omitempty"`
+	// HostKey SFTP 服务器公钥，authorized_keys 格式，必填
+	HostKey string `json:"host_key,omitempty"`
+	// Encryption 静态加密设置，为空时不加密
+	Encryption *EncryptionOption `json:"encryption,omitempty"`
+	// Replicas 镜像存储策略的副本存储策略 ID，按读取优先级排列
+	Replicas []uint `json:"replicas,omitempty"`
+}
+
+// EncryptionOption 存储策略静态加密设置
+type EncryptionOption struct {
+	// Enabled 是否加密存储文件
+	Enabled bool `json:"enabled"`
+	// KeyWrapping 数据密钥的包装方式，system 使用站点密钥，config 使用配置文件中的 encryption_key
+	KeyWrapping string `json:"key_wrapping,omitempty"`
+	// KeyVersion 加密新文件使用的密钥版本
+	KeyVersion uint32 `json:"key_version,omitempty"`
+	// Keys 各版本被包装后的数据密钥
+	Keys map[uint32]string `json:"keys,omitempty"`
 }
 
 // thumbSuffix 支持缩略图处理的文件扩展名
@@ -87,7 +106,72 @@ func GetPolicyByID(ID interface{}) (Policy, error) {
 	return policy, result.Error
 }
 
+// GetPolicies 列出所有存储策略
+func GetPolicies() ([]Policy, error) {
+	var policies []Policy
+	result := Db.Find(&policies)
+	return policies, result.Error
+}
+
+// GetMirrorsByReplica 列出将指定存储策略用作副本的镜像存储策略
+func GetMirrorsByReplica(id uint) ([]Policy, error) {
+	var mirrors []Policy
+	if err := Db.Where("type = ?", "mirror").Find(&mirrors).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]Policy, 0, len(mirrors))
+	for _, mirror := range mirrors {
+		for _, replica := range mirror.OptionsSerialized.Replicas {
+			if replica == id {
+				res = append(res, mirror)
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// AfterFind 找到存储策略后的钩子
+func (policy *Policy) AfterFind(tx *gorm.DB) (err error) {
+	if policy.Options != "" {
+		err = json.Unmarshal([]byte(policy.Options), &policy.OptionsSerialized)
+	}
+	return err
+}
+
+// BeforeSave 保存存储策略前的钩子
+func (policy *Policy) BeforeSave(tx *gorm.DB) (err error) {
+	return policy.SerializeOptions()
+}
+
+// SerializeOptions 将设置序列化后写入 Options 字段
+func (policy *Policy) SerializeOptions() (err error) {
+	optionsValue, err := json.Marshal(&policy.OptionsSerialized)
+	policy.Options = string(optionsValue)
+	return err
+}
+
+// UpdateOptions 保存设置并清除缓存
+func (policy *Policy) UpdateOptions() error {
+	if err := policy.SerializeOptions(); err != nil {
+		return err
+	}
+	err := Db.Model(policy).UpdateColumn("options", policy.Options).Error
+	policy.CleanCache()
+	return err
+}
+
+// IsEncrypted 存储策略是否开启了静态加密
+func (policy *Policy) IsEncrypted() bool {
+	return policy.OptionsSerialized.Encryption != nil && policy.OptionsSerialized.Encryption.Enabled
+}
+
 func (policy *Policy) IsThumbExist(name string) bool {
+	// 加密后的文件无法由存储端处理缩略图
+	if policy.IsEncrypted() {
+		return false
+	}
 	if list, ok := thumbSuffix[policy.Type]; ok {
 		if len(list) == 1 && list[0] == "*" {
 			return true
@@ -98,7 +182,7 @@ func (policy *Policy) IsThumbExist(name string) bool {
 }
 
 func (policy *Policy) IsThumbGenerateNeeded() bool {
-	return policy.Type == "local"
+	return policy.Type == "local" || policy.Type == "mirror" || policy.IsEncrypted()
 }
 
 // GeneratePath 生成存储文件的路径
@@ -124,6 +208,33 @@ func (policy *Policy) GeneratePath(uid uint, origin string) string {
 	return path.Clean(dirRule)
 }
 
+// StaticRoot 存储路径规则中不含变量的前缀目录，所有物理文件均位于其下，无法确定时返回空
+func (policy *Policy) StaticRoot() string {
+	root := policy.DirNameRule
+	if i := strings.Index(root, "{"); i >= 0 {
+		root = root[:strings.LastIndex(root[:i], "/")+1]
+	}
+
+	root = path.Clean(root)
+	if root == "." || root == "/" || root == ".." || strings.HasPrefix(root, "../") {
+		return ""
+	}
+	return root
+}
+
+// SharesRootWith 两个存储策略是否使用同一存储端且存储目录可能重叠，存储目录无法确定时视为重叠
+func (policy *Policy) SharesRootWith(other *Policy) bool {
+	if policy.Type != other.Type || policy.Server != other.Server || policy.BucketName != other.BucketName {
+		return false
+	}
+
+	root, otherRoot := policy.StaticRoot(), other.StaticRoot()
+	if root == "" || otherRoot == "" {
+		return true
+	}
+	return strings.HasPrefix(root+"/", otherRoot+"/") || strings.HasPrefix(otherRoot+"/", root+"/")
+}
+
 // GenerateFileName 生成存储文件名
 func (policy *Policy) GenerateFileName(uid uint, origin string) string {
 	// 未开启自动重命名时，直接返回原始文件名
@@ -187,5 +298,14 @@ func (policy *Policy) IsUploadPlaceholderWithSize() bool {
 }
 
 func (policy *Policy) IsTransitUpload(size uint64) bool {
-	return policy.Type == "local"
+	// 加密需要在主机完成，文件须经主机中转
+	if policy.IsEncrypted() {
+		return true
+	}
+	return utils.ContainsString([]string{"local", "sftp", "webdav", "mirror"}, policy.Type)
+}
+
+// IsAppendable 存储端是否支持从主机追加写入分片
+func (policy *Policy) IsAppendable() bool {
+	return utils.ContainsString([]string{"local", "sftp", "webdav"}, policy.Type)
 }
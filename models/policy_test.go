@@ -0,0 +1,53 @@
+package models
+
+import "testing"
+
+func TestPolicy_StaticRoot(t *testing.T) {
+	testCases := []struct {
+		rule     string
+		expected string
+	}{
+		{"uploads/{uid}/{path}", "uploads"},
+		{"./uploads/{uid}/{path}", "uploads"},
+		{"/data/uploads/{date}", "/data/uploads"},
+		{"uploads/user_{uid}", "uploads"},
+		{"uploads/static", "uploads/static"},
+		{"{uid}/{path}", ""},
+		{"/{uid}", ""},
+		{"../{uid}", ""},
+		{"../uploads/{uid}", ""},
+		{"", ""},
+	}
+
+	for _, testCase := range testCases {
+		policy := Policy{DirNameRule: testCase.rule}
+		if root := policy.StaticRoot(); root != testCase.expected {
+			t.Errorf("StaticRoot(%q) = %q, want %q", testCase.rule, root, testCase.expected)
+		}
+	}
+}
+
+func TestPolicy_SharesRootWith(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a, b     Policy
+		expected bool
+	}{
+		{"same root", Policy{Type: "local", DirNameRule: "uploads/{uid}"}, Policy{Type: "local", DirNameRule: "uploads/{date}"}, true},
+		{"nested root", Policy{Type: "local", DirNameRule: "uploads/{uid}"}, Policy{Type: "local", DirNameRule: "uploads/a/{uid}"}, true},
+		{"sibling with common prefix", Policy{Type: "local", DirNameRule: "uploads/{uid}"}, Policy{Type: "local", DirNameRule: "uploads2/{uid}"}, false},
+		{"unknown root", Policy{Type: "local", DirNameRule: "uploads/{uid}"}, Policy{Type: "local", DirNameRule: "{uid}"}, true},
+		{"different type", Policy{Type: "local", DirNameRule: "uploads/{uid}"}, Policy{Type: "remote", DirNameRule: "uploads/{uid}"}, false},
+		{"different server", Policy{Type: "remote", Server: "a", DirNameRule: "uploads/{uid}"}, Policy{Type: "remote", Server: "b", DirNameRule: "uploads/{uid}"}, false},
+		{"different bucket", Policy{Type: "s3", BucketName: "a", DirNameRule: "uploads/{uid}"}, Policy{Type: "s3", BucketName: "b", DirNameRule: "uploads/{uid}"}, false},
+	}
+
+	for _, testCase := range testCases {
+		if res := testCase.a.SharesRootWith(&testCase.b); res != testCase.expected {
+			t.Errorf("%s: SharesRootWith = %v, want %v", testCase.name, res, testCase.expected)
+		}
+		if res := testCase.b.SharesRootWith(&testCase.a); res != testCase.expected {
+			t.Errorf("%s: reversed SharesRootWith = %v, want %v", testCase.name, res, testCase.expected)
+		}
+	}
+}
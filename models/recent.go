@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	// RecentActionUpload 上传
+	RecentActionUpload = "upload"
+	// RecentActionDownload 下载
+	RecentActionDownload = "download"
+	// RecentActionPreview 预览
+	RecentActionPreview = "preview"
+	// RecentActionEdit 在线编辑
+	RecentActionEdit = "edit"
+)
+
+// RecentFile 用户最近访问的文件，每个文件仅保留最后一次访问
+type RecentFile struct {
+	ID         uint      `gorm:"primarykey"`
+	AccessedAt time.Time `gorm:"index"`
+	UserID     uint      `gorm:"index"`
+	FileID     uint      `gorm:"index"`
+	Action     string
+}
+
+// RecordRecentFile 记录文件访问，并清理超出数量上限的旧记录
+func RecordRecentFile(uid, fileID uint, action string) error {
+	var record RecentFile
+	if err := Db.Where("user_id = ? and file_id = ?", uid, fileID).First(&record).Error; err == nil {
+		if err := Db.Model(&record).Updates(map[string]interface{}{
+			"accessed_at": time.Now(),
+			"action":      action,
+		}).Error; err != nil {
+			return err
+		}
+	} else {
+		record = RecentFile{AccessedAt: time.Now(), UserID: uid, FileID: fileID, Action: action}
+		if err := Db.Create(&record).Error; err != nil {
+			return err
+		}
+	}
+
+	limit := GetIntSetting("recent_files_max", 50)
+	var expired []uint
+	if err := Db.Model(&RecentFile{}).Where("user_id = ?", uid).Order("accessed_at desc").
+		Offset(limit).Pluck("id", &expired).Error; err != nil || len(expired) == 0 {
+		return err
+	}
+	return Db.Where("id in (?)", expired).Delete(&RecentFile{}).Error
+}
+
+// GetRecentFiles 列出用户最近访问的文件，按访问时间倒序排列
+func GetRecentFiles(uid uint) ([]File, error) {
+	var records []RecentFile
+	if err := Db.Where("user_id = ?", uid).Order("accessed_at desc").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.FileID)
+	}
+
+	files, err := GetFilesByIDs(ids, uid)
+	if err != nil {
+		return nil, err
+	}
+	return sortFilesByIDs(ids, files), nil
+}
+
+// DeleteRecentFilesByFileIDs 删除已删除文件的访问记录
+func DeleteRecentFilesByFileIDs(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return Db.Where("file_id in (?)", ids).Delete(&RecentFile{}).Error
+}
+
+// sortFilesByIDs 按 ids 的顺序排列文件，不存在的文件会被忽略
+func sortFilesByIDs(ids []uint, files []File) []File {
+	fileMap := make(map[uint]File, len(files))
+	for _, file := range files {
+		fileMap[file.ID] = file
+	}
+
+	res := make([]File, 0, len(files))
+	for _, id := range ids {
+		if file, ok := fileMap[id]; ok {
+			res = append(res, file)
+		}
+	}
+	return res
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	// ScrubMissing 物理文件不存在
+	ScrubMissing = iota
+	// ScrubSizeMismatch 物理文件大小与记录不符
+	ScrubSizeMismatch
+	// ScrubHashMismatch 物理文件内容与记录的哈希不符
+	ScrubHashMismatch
+	// ScrubOrphaned 物理文件未被任何文件记录引用
+	ScrubOrphaned
+)
+
+// DanglingMetadataKey 标记物理文件已丢失的文件元数据字段
+const DanglingMetadataKey = "sys_dangling"
+
+// ScrubIssue 存储巡检及对账任务发现的问题
+type ScrubIssue struct {
+	gorm.Model
+	TaskID     uint `gorm:"index"`
+	PolicyID   uint
+	Type       int
+	SourceName string `gorm:"type:text"`
+	// FileID 对应的文件记录，孤立文件为 0
+	FileID   uint
+	Expected string
+	Actual   string
+	// Repaired 是否已执行修复
+	Repaired bool
+}
+
+// Create 创建巡检问题记录
+func (issue *ScrubIssue) Create() error {
+	return Db.Create(issue).Error
+}
+
+// GetReferencedSourceNames 从 names 中筛选出仍被 policyIDs 中存储策略的文件或历史版本引用的物理文件
+func GetReferencedSourceNames(policyIDs []uint, names []string) (map[string]bool, error) {
+	res := make(map[string]bool, len(names))
+	if len(names) == 0 {
+		return res, nil
+	}
+
+	var fileSources []string
+	if err := Db.Unscoped().Model(&File{}).Where("policy_id in (?) and source_name in (?)", policyIDs, names).
+		Pluck("source_name", &fileSources).Error; err != nil {
+		return nil, err
+	}
+
+	var versionSources []string
+	if err := Db.Model(&FileVersion{}).Where("policy_id in (?) and source_name in (?)", policyIDs, names).
+		Pluck("source_name", &versionSources).Error; err != nil {
+		return nil, err
+	}
+
+	for _, name := range append(fileSources, versionSources...) {
+		res[name] = true
+	}
+	return res, nil
+}
+
+// CountSourceReferences 统计存储策略下引用同一物理文件的文件记录及历史版本数量，
+// exclude 中的文件及其历史版本不计入
+func CountSourceReferences(policyID uint, source string, exclude []uint) (int64, error) {
+	var files, versions int64
+	fileTx := Db.Unscoped().Model(&File{}).Where("policy_id = ? and source_name = ?", policyID, source)
+	versionTx := Db.Model(&FileVersion{}).Where("policy_id = ? and source_name = ?", policyID, source)
+	if len(exclude) > 0 {
+		fileTx = fileTx.Where("id not in (?)", exclude)
+		versionTx = versionTx.Where("file_id not in (?)", exclude)
+	}
+
+	if err := fileTx.Count(&files).Error; err != nil {
+		return 0, err
+	}
+
+	if err := versionTx.Count(&versions).Error; err != nil {
+		return 0, err
+	}
+	return files + versions, nil
+}
+
+// DeleteScrubIssuesByTasks 删除巡检任务的报告
+func DeleteScrubIssuesByTasks(taskIDs []uint) error {
+	return Db.Where("task_id in (?)", taskIDs).Delete(&ScrubIssue{}).Error
+}
+
+// MarkDangling 在文件元数据中标记或清除物理文件丢失状态
+func (file *File) MarkDangling(dangling bool) error {
+	data := make(map[string]string, len(file.MetadataSerialized)+1)
+	for k, v := range file.MetadataSerialized {
+		data[k] = v
+	}
+
+	if dangling {
+		data[DanglingMetadataKey] = "true"
+	} else {
+		delete(data, DanglingMetadataKey)
+	}
+	return file.UpdateMetadata(data)
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestGetReferencedSourceNames(t *testing.T) {
+	var queries []string
+	useFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		queries = append(queries, query)
+		if strings.Contains(query, "`files`") {
+			return []string{"source_name"}, [][]driver.Value{{"a"}}
+		}
+		return []string{"source_name"}, [][]driver.Value{{"b"}}
+	})
+
+	referenced, err := GetReferencedSourceNames([]uint{1, 2}, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(referenced) != 2 || !referenced["a"] || !referenced["b"] {
+		t.Errorf("referenced = %v", referenced)
+	}
+
+	// 回收站中的文件同样视为引用
+	if len(queries) != 2 || strings.Contains(queries[0], "deleted_at") || !strings.Contains(queries[0], "policy_id in (?,?)") {
+		t.Errorf("unexpected queries %q", queries)
+	}
+
+	queries = nil
+	if referenced, err := GetReferencedSourceNames([]uint{1}, nil); err != nil || len(referenced) != 0 || len(queries) != 0 {
+		t.Errorf("empty names should not query, got %v, %v, %q", referenced, err, queries)
+	}
+}
@@ -17,6 +17,7 @@ type Share struct {
 	gorm.Model
 	Password        string
 	IsDir           bool
+	IsAlbum         bool
 	UserID          uint
 	SourceID        uint
 	Views           int
@@ -29,6 +30,7 @@ type Share struct {
 	User   User   `gorm:"PRELOAD:false,association_autoupdate:false"`
 	File   File   `gorm:"PRELOAD:false,association_autoupdate:false"`
 	Folder Folder `gorm:"PRELOAD:false,association_autoupdate:false"`
+	Album  Album  `gorm:"-"`
 }
 
 func ListShares(uid uint, page, pageSize int, order string, publicOnly bool) ([]Share, int) {
@@ -48,6 +50,9 @@ func ListShares(uid uint, page, pageSize int, order string, publicOnly bool) ([]
 }
 
 func (share *Share) Source() interface{} {
+	if share.IsAlbum {
+		return share.SourceAlbum()
+	}
 	if share.IsDir {
 		return share.SourceFolder()
 	}
@@ -74,8 +79,19 @@ func (share *Share) SourceFile() *File {
 	return &share.File
 }
 
+// SourceAlbum 获取分享的相册
+func (share *Share) SourceAlbum() *Album {
+	if share.Album.ID == 0 {
+		album, err := GetAlbumByID(share.SourceID, share.UserID)
+		if err == nil {
+			share.Album = *album
+		}
+	}
+	return &share.Album
+}
+
 func DeleteShareBySourceIDs(sources []uint, isDir bool) error {
-	return Db.Where("source_id in (?) and is_dir = ?", sources, isDir).Delete(&Share{}).Error
+	return Db.Where("source_id in (?) and is_dir = ? and is_album = ?", sources, isDir, false).Delete(&Share{}).Error
 }
 
 func (share *Share) Viewed() {
@@ -156,7 +172,9 @@ func (share *Share) IsAvailable() bool {
 	}
 
 	var sourceID uint
-	if share.IsDir {
+	if share.IsAlbum {
+		sourceID = share.SourceAlbum().ID
+	} else if share.IsDir {
 		folder := share.SourceFolder()
 		sourceID = folder.ID
 	} else {
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"github.com/jylc/cloudserver/pkg/utils"
+	"gorm.io/gorm"
+	"time"
+)
 
 type Tag struct {
 	gorm.Model
@@ -9,6 +13,7 @@ type Tag struct {
 	Color      string //图标颜色
 	Type       int    //标签类型
 	Expression string `gorm:"type:text"` //搜索表达式
+	Query      bool   //表达式是否为结构化搜索语句
 	UserID     uint   //创建者ID
 }
 
@@ -17,8 +22,19 @@ const (
 	FileTagType = iota
 	// DirectoryLinkType 目录快捷方式标签
 	DirectoryLinkType
+	// ManualTagType 手动标签，直接关联至文件或目录
+	ManualTagType
 )
 
+// ObjectTag 文件或目录与手动标签的关联
+type ObjectTag struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	TagID     uint `gorm:"index"`
+	ObjectID  uint `gorm:"index"`
+	IsFolder  bool
+}
+
 func GetTagsByUID(uid uint) ([]Tag, error) {
 	var tag []Tag
 	result := Db.Where("user_id = ?", uid).Find(&tag)
@@ -30,3 +46,200 @@ func GetTagsByID(id, uid uint) (*Tag, error) {
 	result := Db.Where("user_id = ? and id = ?", uid, id).First(&tag)
 	return &tag, result.Error
 }
+
+// GetTagByName 根据名称获取用户的标签
+func GetTagByName(name string, tagType int, uid uint) (*Tag, error) {
+	var tag Tag
+	result := Db.Where("user_id = ? and name = ? and type = ?", uid, name, tagType).First(&tag)
+	return &tag, result.Error
+}
+
+// Create 创建标签
+func (tag *Tag) Create() (uint, error) {
+	if err := Db.Create(tag).Error; err != nil {
+		return 0, err
+	}
+	return tag.ID, nil
+}
+
+// DeleteTagByID 删除用户的标签及其与文件、目录的关联
+func DeleteTagByID(id, uid uint) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? and user_id = ?", id, uid).Delete(&Tag{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Where("tag_id = ?", id).Delete(&ObjectTag{}).Error
+	})
+}
+
+// GetTagsByIDs 获取用户指定类型的标签
+func GetTagsByIDs(ids []uint, tagType int, uid uint) ([]Tag, error) {
+	var tags []Tag
+	result := Db.Where("id in (?) and type = ? and user_id = ?", ids, tagType, uid).Find(&tags)
+	return tags, result.Error
+}
+
+// AddObjectTags 为文件及目录添加手动标签，已存在的关联会被忽略
+func AddObjectTags(tagIDs, folderIDs, fileIDs []uint) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		for _, tagID := range tagIDs {
+			for _, group := range []struct {
+				ids      []uint
+				isFolder bool
+			}{{folderIDs, true}, {fileIDs, false}} {
+				if len(group.ids) == 0 {
+					continue
+				}
+
+				var existed []uint
+				if err := tx.Model(&ObjectTag{}).Where("tag_id = ? and is_folder = ? and object_id in (?)",
+					tagID, group.isFolder, group.ids).Pluck("object_id", &existed).Error; err != nil {
+					return err
+				}
+
+				records := make([]ObjectTag, 0, len(group.ids))
+				for _, id := range group.ids {
+					if !utils.ContainsUint(existed, id) {
+						existed = append(existed, id)
+						records = append(records, ObjectTag{TagID: tagID, ObjectID: id, IsFolder: group.isFolder})
+					}
+				}
+
+				if len(records) > 0 {
+					if err := tx.Create(&records).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveObjectTags 移除文件及目录的手动标签
+func RemoveObjectTags(tagIDs, folderIDs, fileIDs []uint) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if len(folderIDs) > 0 {
+			if err := tx.Where("tag_id in (?) and is_folder = ? and object_id in (?)", tagIDs, true, folderIDs).
+				Delete(&ObjectTag{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(fileIDs) > 0 {
+			return tx.Where("tag_id in (?) and is_folder = ? and object_id in (?)", tagIDs, false, fileIDs).
+				Delete(&ObjectTag{}).Error
+		}
+		return nil
+	})
+}
+
+// DeleteObjectTagsByObjects 删除已删除的文件或目录的标签关联
+func DeleteObjectTagsByObjects(ids []uint, isFolder bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return Db.Where("object_id in (?) and is_folder = ?", ids, isFolder).Delete(&ObjectTag{}).Error
+}
+
+// GetObjectTags 获取文件及目录的手动标签，返回以对象 ID 为键的标签列表
+func GetObjectTags(folderIDs, fileIDs []uint) (folderTags, fileTags map[uint][]Tag, err error) {
+	folderTags, fileTags = make(map[uint][]Tag), make(map[uint][]Tag)
+	if len(folderIDs) == 0 && len(fileIDs) == 0 {
+		return
+	}
+
+	tx := Db.Where("1 = 0")
+	if len(folderIDs) > 0 {
+		tx = tx.Or("is_folder = ? and object_id in (?)", true, folderIDs)
+	}
+	if len(fileIDs) > 0 {
+		tx = tx.Or("is_folder = ? and object_id in (?)", false, fileIDs)
+	}
+
+	var relations []ObjectTag
+	if err = Db.Where(tx).Find(&relations).Error; err != nil || len(relations) == 0 {
+		return
+	}
+
+	tagIDs := make([]uint, 0, len(relations))
+	for _, relation := range relations {
+		tagIDs = append(tagIDs, relation.TagID)
+	}
+
+	var tags []Tag
+	if err = Db.Where("id in (?)", tagIDs).Find(&tags).Error; err != nil {
+		return
+	}
+	tagMap := make(map[uint]Tag, len(tags))
+	for _, tag := range tags {
+		tagMap[tag.ID] = tag
+	}
+
+	for _, relation := range relations {
+		tag, ok := tagMap[relation.TagID]
+		if !ok {
+			continue
+		}
+		if relation.IsFolder {
+			folderTags[relation.ObjectID] = append(folderTags[relation.ObjectID], tag)
+		} else {
+			fileTags[relation.ObjectID] = append(fileTags[relation.ObjectID], tag)
+		}
+	}
+	return
+}
+
+// copyObjectTags 复制对象时为新对象复制标签，idMap 为原对象 ID 至新对象 ID 的映射
+func copyObjectTags(idMap map[uint]uint, isFolder bool) error {
+	if len(idMap) == 0 {
+		return nil
+	}
+
+	origins := make([]uint, 0, len(idMap))
+	for id := range idMap {
+		origins = append(origins, id)
+	}
+
+	var relations []ObjectTag
+	if err := Db.Where("object_id in (?) and is_folder = ?", origins, isFolder).Find(&relations).Error; err != nil {
+		return err
+	}
+
+	if len(relations) == 0 {
+		return nil
+	}
+
+	records := make([]ObjectTag, 0, len(relations))
+	for _, relation := range relations {
+		records = append(records, ObjectTag{TagID: relation.TagID, ObjectID: idMap[relation.ObjectID], IsFolder: isFolder})
+	}
+	return Db.Create(&records).Error
+}
+
+// GetTaggedObjects 列出用户带有指定手动标签的目录和文件，parents 不为空时仅列出其中的对象
+func GetTaggedObjects(tagID, uid uint, parents []uint) ([]Folder, []File, error) {
+	tagged := func(isFolder bool) *gorm.DB {
+		return Db.Model(&ObjectTag{}).Select("object_id").Where("tag_id = ? and is_folder = ?", tagID, isFolder)
+	}
+
+	var folders []Folder
+	folderTx := Db.Where("owner_id = ? and id in (?)", uid, tagged(true))
+	if len(parents) > 0 {
+		folderTx = folderTx.Where("parent_id in (?)", parents)
+	}
+	if err := folderTx.Find(&folders).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var files []File
+	fileTx := Db.Where("user_id = ? and id in (?)", uid, tagged(false))
+	if len(parents) > 0 {
+		fileTx = fileTx.Where("folder_id in (?)", parents)
+	}
+	if err := fileTx.Find(&files).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return folders, files, nil
+}
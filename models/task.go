@@ -35,6 +35,11 @@ func (task *Task) SetError(err string) error {
 	return Db.Model(task).Select("error").Updates(map[string]interface{}{"error": err}).Error
 }
 
+// SetProps 更新任务属性，用于记录可恢复任务的进度
+func (task *Task) SetProps(props string) error {
+	return Db.Model(task).Select("props").Updates(map[string]interface{}{"props": props}).Error
+}
+
 func GetTasksByID(id interface{}) (*Task, error) {
 	task := &Task{}
 	result := Db.Where("id = ?", id).First(task)
@@ -47,6 +52,17 @@ func GetTasksByStatus(status ...int) []Task {
 	return tasks
 }
 
+// CountTasksByStatus 统计指定任务中处于给定状态的数量
+func CountTasksByStatus(ids []uint, status ...int) int64 {
+	if len(ids) == 0 {
+		return 0
+	}
+
+	var total int64
+	Db.Model(&Task{}).Where("id in (?) and status in (?)", ids, status).Count(&total)
+	return total
+}
+
 func ListTasks(uid uint, page, pageSize int, order string) ([]Task, int) {
 	var (
 		tasks []Task
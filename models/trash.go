@@ -0,0 +1,201 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Trash 回收站记录，只记录用户直接删除的文件或目录，其下级对象随之软删除
+type Trash struct {
+	gorm.Model
+	UserID   uint `gorm:"index"`
+	ObjectID uint
+	IsFolder bool
+	Name     string
+	// Path 删除前所在的父目录路径，用于还原
+	Path string `gorm:"type:text"`
+	// Size 随之删除的文件总大小
+	Size uint64
+}
+
+// Create 软删除对象并创建回收站记录
+func (trash *Trash) Create(folderIDs, fileIDs []uint) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(trash).Error; err != nil {
+			return err
+		}
+
+		// 直接更新删除时间，避免触发文件记录的删除钩子
+		now := time.Now()
+		if len(fileIDs) > 0 {
+			if err := tx.Model(&File{}).Where("id in (?)", fileIDs).UpdateColumn("deleted_at", now).Error; err != nil {
+				return err
+			}
+		}
+		if len(folderIDs) > 0 {
+			if err := tx.Model(&Folder{}).Where("id in (?)", folderIDs).UpdateColumn("deleted_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Objects 列出随回收站记录删除的目录及文件，跳过拥有独立回收站记录的下级对象
+func (trash *Trash) Objects() ([]Folder, []File, error) {
+	var (
+		folders []Folder
+		files   []File
+	)
+
+	if !trash.IsFolder {
+		err := Db.Unscoped().Where("id = ? and user_id = ? and deleted_at is not null", trash.ObjectID, trash.UserID).
+			Find(&files).Error
+		return folders, files, err
+	}
+
+	var others []Trash
+	if err := Db.Where("user_id = ? and id <> ?", trash.UserID, trash.ID).Find(&others).Error; err != nil {
+		return nil, nil, err
+	}
+	excludedFolders := make(map[uint]bool)
+	excludedFiles := make(map[uint]bool)
+	for _, other := range others {
+		if other.IsFolder {
+			excludedFolders[other.ObjectID] = true
+		} else {
+			excludedFiles[other.ObjectID] = true
+		}
+	}
+
+	if err := Db.Unscoped().Where("id = ? and owner_id = ? and deleted_at is not null", trash.ObjectID, trash.UserID).
+		Find(&folders).Error; err != nil {
+		return nil, nil, err
+	}
+
+	parentIDs := make([]uint, 0, len(folders))
+	for _, folder := range folders {
+		parentIDs = append(parentIDs, folder.ID)
+	}
+	folderIDs := append([]uint{}, parentIDs...)
+
+	for len(parentIDs) > 0 {
+		var children []Folder
+		if err := Db.Unscoped().Where("owner_id = ? and parent_id in (?) and deleted_at is not null", trash.UserID, parentIDs).
+			Find(&children).Error; err != nil {
+			return nil, nil, err
+		}
+
+		parentIDs = make([]uint, 0, len(children))
+		for _, child := range children {
+			if !excludedFolders[child.ID] {
+				parentIDs = append(parentIDs, child.ID)
+				folders = append(folders, child)
+			}
+		}
+		folderIDs = append(folderIDs, parentIDs...)
+	}
+
+	if len(folderIDs) == 0 {
+		return folders, files, nil
+	}
+
+	var children []File
+	if err := Db.Unscoped().Where("folder_id in (?) and deleted_at is not null", folderIDs).Find(&children).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, child := range children {
+		if !excludedFiles[child.ID] {
+			files = append(files, child)
+		}
+	}
+
+	return folders, files, nil
+}
+
+// Restore 还原回收站记录中的对象到指定目录，并删除回收站记录
+func (trash *Trash) Restore(parentID uint, name string, folders []Folder, files []File) error {
+	folderIDs := make([]uint, 0, len(folders))
+	for _, folder := range folders {
+		folderIDs = append(folderIDs, folder.ID)
+	}
+	fileIDs := make([]uint, 0, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+	}
+
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if len(fileIDs) > 0 {
+			if err := tx.Unscoped().Model(&File{}).Where("id in (?)", fileIDs).
+				UpdateColumn("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		if len(folderIDs) > 0 {
+			if err := tx.Unscoped().Model(&Folder{}).Where("id in (?)", folderIDs).
+				UpdateColumn("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+
+		if trash.IsFolder {
+			if err := tx.Model(&Folder{}).Where("id = ?", trash.ObjectID).
+				UpdateColumns(map[string]interface{}{"parent_id": parentID, "name": name}).Error; err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Model(&File{}).Where("id = ?", trash.ObjectID).
+				UpdateColumns(map[string]interface{}{"folder_id": parentID, "name": name}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().Delete(trash).Error
+	})
+}
+
+// Delete 删除回收站记录
+func (trash *Trash) Delete() error {
+	return Db.Unscoped().Delete(trash).Error
+}
+
+// GetTrashByIDs 根据 ID 获取用户的回收站记录
+func GetTrashByIDs(ids []uint, uid uint) ([]Trash, error) {
+	var trashes []Trash
+	result := Db.Where("id in (?) and user_id = ?", ids, uid).Find(&trashes)
+	return trashes, result.Error
+}
+
+// GetTrashByUser 获取用户的全部回收站记录
+func GetTrashByUser(uid uint) ([]Trash, error) {
+	var trashes []Trash
+	result := Db.Where("user_id = ?", uid).Find(&trashes)
+	return trashes, result.Error
+}
+
+// GetExpiredTrash 获取在指定时间前删除的回收站记录
+func GetExpiredTrash(uid uint, before time.Time) ([]Trash, error) {
+	var trashes []Trash
+	result := Db.Where("user_id = ? and created_at < ?", uid, before).Find(&trashes)
+	return trashes, result.Error
+}
+
+// GetTrashOwners 获取回收站中有记录的用户 ID
+func GetTrashOwners() ([]uint, error) {
+	var uids []uint
+	result := Db.Model(&Trash{}).Distinct("user_id").Pluck("user_id", &uids)
+	return uids, result.Error
+}
+
+// ListTrash 分页列出用户的回收站记录
+func ListTrash(uid uint, page, pageSize int, order string) ([]Trash, int) {
+	var (
+		trashes []Trash
+		total   int64
+	)
+	dbChain := Db.Where("user_id = ?", uid)
+	dbChain.Model(&Trash{}).Count(&total)
+
+	dbChain.Limit(pageSize).Offset((page - 1) * pageSize).Order(order).Find(&trashes)
+	return trashes, int(total)
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"github.com/jylc/cloudserver/pkg/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"strings"
 )
 
@@ -155,6 +156,41 @@ func (user *User) IncreaseStorageWithoutCheck(size uint64) {
 	Db.Model(user).Update("storage", gorm.Expr("storage + ?", size))
 }
 
+// GetUserIDsAfter 按 ID 顺序分批列出用户 ID
+func GetUserIDsAfter(after uint, limit int) ([]uint, error) {
+	var ids []uint
+	result := Db.Model(&User{}).Where("id > ?", after).Order("id asc").Limit(limit).Pluck("id", &ids)
+	return ids, result.Error
+}
+
+// RecalculateStorage 根据文件（含回收站中的文件及上传占位文件）与计入容量的历史版本重新计算用户已用容量，
+// 计算期间锁定用户记录，并发的容量变更须等待计算完成。返回修正前后的容量
+func RecalculateStorage(uid uint) (before, after uint64, err error) {
+	err = Db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "storage").First(&user, uid).Error; err != nil {
+			return err
+		}
+
+		var fileSize, versionSize uint64
+		if err := tx.Unscoped().Model(&File{}).Where("user_id = ?", uid).
+			Select("coalesce(sum(size), 0)").Scan(&fileSize).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&FileVersion{}).Where("user_id = ? and counted = ?", uid, true).
+			Select("coalesce(sum(size), 0)").Scan(&versionSize).Error; err != nil {
+			return err
+		}
+
+		before, after = user.Storage, fileSize+versionSize
+		if before == after {
+			return nil
+		}
+		return tx.Model(&user).UpdateColumn("storage", after).Error
+	})
+	return
+}
+
 func (user *User) UpdateOptions() error {
 	if err := user.SerializeOptions(); err != nil {
 		return err
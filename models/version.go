@@ -0,0 +1,185 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// FileVersion 文件被覆盖前的历史版本，持有原有内容对应的物理文件
+type FileVersion struct {
+	gorm.Model
+	FileID     uint `gorm:"index"`
+	UserID     uint
+	PolicyID   uint
+	SourceName string `gorm:"type:text"`
+	Size       uint64
+	SHA256     string `gorm:"size:64"`
+	// Counted 版本大小是否计入用户容量
+	Counted bool
+}
+
+// GetVersionsByFile 获取文件的历史版本，按创建时间由新到旧排列
+func GetVersionsByFile(fileID, uid uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := Db.Where("file_id = ? and user_id = ?", fileID, uid).Order("id desc").Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionsByFiles 获取多个文件的历史版本
+func GetVersionsByFiles(fileIDs []uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	if len(fileIDs) == 0 {
+		return versions, nil
+	}
+	result := Db.Where("file_id in (?)", fileIDs).Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionByID 获取文件的指定历史版本
+func GetVersionByID(id, fileID, uid uint) (*FileVersion, error) {
+	var version FileVersion
+	result := Db.Where("id = ? and file_id = ? and user_id = ?", id, fileID, uid).First(&version)
+	return &version, result.Error
+}
+
+// GetVersionsByPolicy 分批获取存储策略下的历史版本
+func GetVersionsByPolicy(policyID, after uint, limit int) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := Db.Where("policy_id = ? and id > ?", policyID, after).Order("id asc").Limit(limit).Find(&versions)
+	return versions, result.Error
+}
+
+// newVersion 根据文件当前内容创建历史版本
+func newVersion(tx *gorm.DB, file *File, counted bool) error {
+	version := &FileVersion{
+		FileID:     file.ID,
+		UserID:     file.UserID,
+		PolicyID:   file.PolicyID,
+		SourceName: file.SourceName,
+		Size:       file.Size,
+		SHA256:     file.SHA256,
+		Counted:    counted,
+	}
+	if err := tx.Create(version).Error; err != nil {
+		return err
+	}
+
+	if counted {
+		user := &User{}
+		user.ID = file.UserID
+		return user.ChangeStorage(tx, "+", file.Size)
+	}
+	return nil
+}
+
+// KeepVersion 将文件当前内容保留为历史版本，counted 表示版本是否计入用户容量。
+// 原有内容的引用计数在文件记录改为引用新的物理文件时释放
+func (file *File) KeepVersion(counted bool) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		return newVersion(tx, file, counted)
+	})
+}
+
+// RestoreVersion 将文件内容替换为历史版本，当前内容保留为新的历史版本
+func (file *File) RestoreVersion(version *FileVersion, counted bool) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if err := refBlob(tx, file, -1); err != nil {
+			return err
+		}
+
+		if err := newVersion(tx, file, counted); err != nil {
+			return err
+		}
+
+		if err := tx.Model(file).UpdateColumns(map[string]interface{}{
+			"source_name": version.SourceName,
+			"policy_id":   version.PolicyID,
+			"size":        version.Size,
+			"sha256":      version.SHA256,
+		}).Error; err != nil {
+			return err
+		}
+
+		// 版本转为文件内容后，文件大小计入容量，版本自身计入的大小释放
+		user := &User{}
+		user.ID = file.UserID
+		if err := user.ChangeStorage(tx, "+", version.Size); err != nil {
+			return err
+		}
+		released := file.Size
+		if version.Counted {
+			released += version.Size
+		}
+		if err := user.ChangeStorage(tx, "-", released); err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(version).Error; err != nil {
+			return err
+		}
+
+		file.SourceName = version.SourceName
+		file.PolicyID = version.PolicyID
+		file.Size = version.Size
+		file.SHA256 = version.SHA256
+		return refBlob(tx, file, 1)
+	})
+}
+
+// Delete 删除历史版本记录
+func (version *FileVersion) Delete() error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(version).Error; err != nil {
+			return err
+		}
+
+		if version.Counted {
+			user := &User{}
+			user.ID = version.UserID
+			return user.ChangeStorage(tx, "-", version.Size)
+		}
+		return nil
+	})
+}
+
+// RemoveVersionsWithSoftLinks 过滤出物理文件未被其他文件或版本引用的历史版本
+func RemoveVersionsWithSoftLinks(versions []FileVersion) ([]FileVersion, error) {
+	filteredVersions := make([]FileVersion, 0)
+	if len(versions) == 0 {
+		return filteredVersions, nil
+	}
+
+	ids := make([]uint, 0, len(versions))
+	tx := Db
+	for _, value := range versions {
+		ids = append(ids, value.ID)
+		tx = tx.Or("source_name = ? and policy_id = ?", value.SourceName, value.PolicyID)
+	}
+
+	var files []File
+	if err := Db.Unscoped().Where(tx).Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	var others []FileVersion
+	if err := Db.Where(tx).Where("id not in (?)", ids).Find(&others).Error; err != nil {
+		return nil, err
+	}
+
+	linked := make(map[string]bool, len(files)+len(others))
+	for _, file := range files {
+		linked[fmt.Sprintf("%d/%s", file.PolicyID, file.SourceName)] = true
+	}
+	for _, other := range others {
+		linked[fmt.Sprintf("%d/%s", other.PolicyID, other.SourceName)] = true
+	}
+
+	for _, version := range versions {
+		key := fmt.Sprintf("%d/%s", version.PolicyID, version.SourceName)
+		if !linked[key] {
+			linked[key] = true
+			filteredVersions = append(filteredVersions, version)
+		}
+	}
+	return filteredVersions, nil
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/conf"
 	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/sirupsen/logrus"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -97,7 +99,15 @@ func CheckURI(instance Auth, url *url.URL) error {
 
 func Init() {
 	var secretKey string
-	secretKey = models.GetSettingByName("secret_key")
+	if conf.Sc.Role == "master" {
+		secretKey = models.GetSettingByName("secret_key")
+	} else {
+		// 从机模式下使用配置文件中的从机密钥，需与主机存储策略中的 SecretKey 一致
+		secretKey = conf.Slavec.Secret
+		if secretKey == "" {
+			logrus.Panic("Slave secret is not set, please set it in the [Slave] section of config file")
+		}
+	}
 	General = HMACAuth{
 		SecretKey: []byte(secretKey),
 	}
@@ -26,6 +26,8 @@ type systemConf struct {
 	SessionSecret string `ini:"secret"`
 	HashIDSalt    string `ini:"hashidsalt"`
 	Role          string `ini:"role"`
+	// EncryptionKey 用于包装存储策略数据密钥的主密钥
+	EncryptionKey string `ini:"encryption_key"`
 }
 
 type redisConf struct {
@@ -78,6 +80,7 @@ func Init(path string) {
 			"Database": Dbc,
 			"System":   Sc,
 			"Redis":    Rc,
+			"Slave":    Slavec,
 		}
 
 		for name, entity := range configMaps {
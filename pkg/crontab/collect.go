@@ -84,3 +84,43 @@ func uploadSessionCollect() {
 	}
 	logrus.Info("The scheduled task [cron_recycle_upload_session] is completed")
 }
+
+// trashCollect 永久删除超过用户组回收站保留期限的对象
+func trashCollect() {
+	uids, err := models.GetTrashOwners()
+	if err != nil {
+		logrus.Warningf("Unable to list trash owners,%s", err)
+		return
+	}
+
+	for _, uid := range uids {
+		user, err := models.GetUserByID(uid)
+		if err != nil {
+			logrus.Warningf("The user of the trash does not exist,%s", err)
+			continue
+		}
+
+		group, err := models.GetGroupByID(user.GroupID)
+		if err != nil || group.OptionsSerialized.TrashRetention <= 0 {
+			continue
+		}
+
+		expires := time.Now().AddDate(0, 0, -group.OptionsSerialized.TrashRetention)
+		trashes, err := models.GetExpiredTrash(uid, expires)
+		if err != nil || len(trashes) == 0 {
+			continue
+		}
+
+		fs, err := filesystem.NewFileSystem(&user)
+		if err != nil {
+			logrus.Warningf("Unable to initialize file system,%s", err)
+			continue
+		}
+
+		if err = fs.Purge(context.Background(), trashes); err != nil {
+			logrus.Warningf("Unable to purge trash,%s", err)
+		}
+		fs.Recycle()
+	}
+	logrus.Info("The scheduled task [cron_purge_trash] is completed")
+}
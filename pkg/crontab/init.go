@@ -13,6 +13,11 @@ func Init() {
 	options := models.GetSettingByNames(
 		"cron_garbage_collect",
 		"cron_recycle_upload_session",
+		"cron_purge_trash",
+		"cron_scrub_storage",
+		"cron_recalc_storage",
+		"cron_lifecycle",
+		"cron_mirror_repair",
 	)
 
 	Cron := cron.New()
@@ -23,6 +28,16 @@ func Init() {
 			handler = garbageCollect
 		case "cron_recycle_upload_session":
 			handler = uploadSessionCollect
+		case "cron_purge_trash":
+			handler = trashCollect
+		case "cron_scrub_storage":
+			handler = storageScrub
+		case "cron_recalc_storage":
+			handler = storageRecalc
+		case "cron_lifecycle":
+			handler = lifecycleEvaluate
+		case "cron_mirror_repair":
+			handler = mirrorRepair
 		default:
 			logrus.Warningf("Unknown scheduled task type [%s], skipping", k)
 			continue
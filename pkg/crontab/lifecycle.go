@@ -0,0 +1,91 @@
+package crontab
+
+import (
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/task"
+	"github.com/sirupsen/logrus"
+)
+
+// lifecycleEvaluate 评估已启用的生命周期规则，以初始管理员身份为满足规则的文件创建迁移或删除任务
+func lifecycleEvaluate() {
+	rules, err := models.GetEnabledLifecycleRules()
+	if err != nil {
+		logrus.Warningf("Unable to list lifecycle rules,%s", err)
+		return
+	}
+
+	limit := models.GetIntSetting("lifecycle_batch_size", 1000)
+	for i := range rules {
+		// 上次创建的任务尚未结束时跳过，避免重复处理同一批文件
+		if models.CountTasksByStatus(rules[i].Tasks(), task.Queued, task.Processing) > 0 {
+			continue
+		}
+
+		files, err := rules[i].Candidates(rules[i].Cursor, limit)
+		if err != nil {
+			logrus.Warningf("Unable to evaluate lifecycle rule [%s],%s", rules[i].Name, err)
+			continue
+		}
+
+		// 下次从本批之后继续，跳过或失败的文件不会阻塞之后的文件；不足一批时说明已评估完，下次从头开始
+		cursor := uint(0)
+		if len(files) >= limit {
+			cursor = files[len(files)-1].ID
+		}
+
+		var jobs []task.Job
+		switch rules[i].Action {
+		case models.LifecycleTransition:
+			// 共用物理文件的其他文件同样满足规则时一同迁移
+			siblings, err := rules[i].Siblings(files)
+			if err != nil {
+				logrus.Warningf("Unable to evaluate lifecycle rule [%s],%s", rules[i].Name, err)
+				continue
+			}
+			files = append(files, siblings...)
+
+			policyFiles := make(map[uint][]uint)
+			for _, file := range files {
+				policyFiles[file.PolicyID] = append(policyFiles[file.PolicyID], file.ID)
+			}
+
+			for policyID, fileIDs := range policyFiles {
+				job, err := task.NewPolicyMigrateTask(1, policyID, rules[i].TargetPolicyID, fileIDs)
+				if err != nil {
+					logrus.Warningf("Unable to create migration task,%s", err)
+					continue
+				}
+				jobs = append(jobs, job)
+			}
+		case models.LifecycleExpire:
+			if len(files) == 0 {
+				break
+			}
+
+			userFiles := make(map[uint][]uint)
+			for _, file := range files {
+				userFiles[file.UserID] = append(userFiles[file.UserID], file.ID)
+			}
+
+			job, err := task.NewFileDeleteTask(1, userFiles)
+			if err != nil {
+				logrus.Warningf("Unable to create delete task,%s", err)
+				continue
+			}
+			jobs = append(jobs, job)
+		}
+
+		taskIDs := make([]uint, 0, len(jobs))
+		for _, job := range jobs {
+			taskIDs = append(taskIDs, job.Model().ID)
+		}
+		if err := rules[i].UpdateProgress(cursor, taskIDs); err != nil {
+			logrus.Warningf("Unable to save progress of lifecycle rule [%s],%s", rules[i].Name, err)
+		}
+
+		for _, job := range jobs {
+			task.TaskPool.Submit(job)
+		}
+	}
+	logrus.Info("The scheduled task [cron_lifecycle] is completed")
+}
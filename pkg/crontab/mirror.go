@@ -0,0 +1,24 @@
+package crontab
+
+import (
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/task"
+	"github.com/sirupsen/logrus"
+)
+
+// mirrorRepair 以初始管理员身份创建修复所有镜像存储策略副本的任务
+func mirrorRepair() {
+	var count int64
+	if models.Db.Model(&models.Policy{}).Where("type = ?", "mirror").Count(&count); count == 0 {
+		return
+	}
+
+	job, err := task.NewMirrorRepairTask(1, nil)
+	if err != nil {
+		logrus.Warningf("Unable to create mirror repair task,%s", err)
+		return
+	}
+
+	task.TaskPool.Submit(job)
+	logrus.Info("The scheduled task [cron_mirror_repair] is completed")
+}
@@ -0,0 +1,19 @@
+package crontab
+
+import (
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/task"
+	"github.com/sirupsen/logrus"
+)
+
+// storageScrub 以初始管理员身份创建巡检所有存储策略的任务，仅生成报告不执行修复
+func storageScrub() {
+	job, err := task.NewScrubTask(1, nil, models.IsTrueVal(models.GetSettingByName("scrub_rehash")), false)
+	if err != nil {
+		logrus.Warningf("Unable to create storage scrub task,%s", err)
+		return
+	}
+
+	task.TaskPool.Submit(job)
+	logrus.Info("The scheduled task [cron_scrub_storage] is completed")
+}
@@ -0,0 +1,18 @@
+package crontab
+
+import (
+	"github.com/jylc/cloudserver/pkg/task"
+	"github.com/sirupsen/logrus"
+)
+
+// storageRecalc 以初始管理员身份创建重新计算所有用户已用容量的任务
+func storageRecalc() {
+	job, err := task.NewStorageRecalcTask(1, nil)
+	if err != nil {
+		logrus.Warningf("Unable to create storage recalculation task,%s", err)
+		return
+	}
+
+	task.TaskPool.Submit(job)
+	logrus.Info("The scheduled task [cron_recalc_storage] is completed")
+}
@@ -0,0 +1,154 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrUnsupported 不支持的校验和算法
+	ErrUnsupported = errors.New("unsupported checksum algorithm")
+	// ErrInvalid 校验和格式错误
+	ErrInvalid = errors.New("invalid checksum")
+	// ErrMismatch 内容与校验和不一致
+	ErrMismatch = errors.New("checksum mismatch")
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// algorithms 支持的算法，名称参照 RFC 3230 Digest 头
+var algorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha":     sha1.New,
+	"sha-256": sha256.New,
+	"crc32c":  func() hash.Hash { return crc32.New(crc32cTable) },
+}
+
+// aliases 算法名称的其他写法
+var aliases = map[string]string{
+	"sha1":   "sha",
+	"sha-1":  "sha",
+	"sha256": "sha-256",
+}
+
+// Checksum 期望的内容校验和
+type Checksum struct {
+	Algorithm string
+	Value     []byte
+}
+
+// New 创建校验和，algorithm 不区分大小写
+func New(algorithm string, value []byte) (*Checksum, error) {
+	algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+	if alias, ok := aliases[algorithm]; ok {
+		algorithm = alias
+	}
+
+	if _, ok := algorithms[algorithm]; !ok {
+		return nil, ErrUnsupported
+	}
+	if len(value) == 0 {
+		return nil, ErrInvalid
+	}
+	return &Checksum{Algorithm: algorithm, Value: value}, nil
+}
+
+// Parse 解析 Digest 头，格式为 algorithm=base64(value)，有多个时使用第一个支持的算法
+func Parse(header string) (*Checksum, error) {
+	err := ErrUnsupported
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			err = ErrInvalid
+			continue
+		}
+
+		value, decodeErr := base64.StdEncoding.DecodeString(parts[1])
+		if decodeErr != nil {
+			err = ErrInvalid
+			continue
+		}
+
+		var res *Checksum
+		if res, err = New(parts[0], value); err == nil {
+			return res, nil
+		}
+	}
+	return nil, err
+}
+
+// Hash 创建对应算法的哈希
+func (c *Checksum) Hash() hash.Hash {
+	return algorithms[c.Algorithm]()
+}
+
+// Verify 校验计算得到的哈希
+func (c *Checksum) Verify(h hash.Hash) error {
+	if string(h.Sum(nil)) != string(c.Value) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// String 格式化为 Digest 头
+func (c *Checksum) String() string {
+	return c.Algorithm + "=" + base64.StdEncoding.EncodeToString(c.Value)
+}
+
+// Compute 计算内容的校验和
+func Compute(algorithm string, r io.Reader) (*Checksum, error) {
+	newHash, ok := algorithms[algorithm]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	h := newHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return &Checksum{Algorithm: algorithm, Value: h.Sum(nil)}, nil
+}
+
+// FromSHA256 由十六进制 SHA-256 哈希创建校验和，用于输出 Digest 头
+func FromSHA256(hexHash string) (*Checksum, error) {
+	value, err := hex.DecodeString(hexHash)
+	if err != nil || len(value) != sha256.Size {
+		return nil, ErrInvalid
+	}
+	return &Checksum{Algorithm: "sha-256", Value: value}, nil
+}
+
+// Reader 读取时同步计算哈希
+type Reader struct {
+	io.ReadCloser
+	checksum *Checksum
+	hash     hash.Hash
+}
+
+// NewReader 创建读取时计算哈希的 Reader
+func NewReader(r io.ReadCloser, c *Checksum) *Reader {
+	return &Reader{
+		ReadCloser: r,
+		checksum:   c,
+		hash:       c.Hash(),
+	}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	return n, err
+}
+
+// Verify 校验已读取内容的哈希
+func (r *Reader) Verify() error {
+	return r.checksum.Verify(r.hash)
+}
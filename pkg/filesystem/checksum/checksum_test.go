@@ -0,0 +1,55 @@
+package checksum
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		header    string
+		algorithm string
+		value     string
+		err       error
+	}{
+		{"sha-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=", "sha-256", "uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=", nil},
+		{"MD5=XrY7u+Ae7tCTyyK7j1rNww==", "md5", "XrY7u+Ae7tCTyyK7j1rNww==", nil},
+		{"SHA1=Kq5sNclPz7QV2+lfQIuc6R7oRu0=", "sha", "Kq5sNclPz7QV2+lfQIuc6R7oRu0=", nil},
+		// 有多个时使用第一个支持的算法
+		{"unixsum=MzA2Mzc=, sha-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=", "sha-256", "uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=", nil},
+		{"unixsum=MzA2Mzc=", "", "", ErrUnsupported},
+		{"sha-256", "", "", ErrInvalid},
+		{"sha-256=not base64", "", "", ErrInvalid},
+		{"sha-256=", "", "", ErrInvalid},
+		{"", "", "", ErrInvalid},
+	}
+
+	for _, testCase := range testCases {
+		res, err := Parse(testCase.header)
+		if err != testCase.err {
+			t.Errorf("Parse(%q) err = %v, want %v", testCase.header, err, testCase.err)
+			continue
+		}
+		if err == nil && (res.Algorithm != testCase.algorithm || res.String() != testCase.algorithm+"="+testCase.value) {
+			t.Errorf("Parse(%q) = %s", testCase.header, res)
+		}
+	}
+}
+
+func TestReader_Verify(t *testing.T) {
+	expected, err := Parse("sha-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for content, want := range map[string]error{"hello world": nil, "hello": ErrMismatch} {
+		r := NewReader(ioutil.NopCloser(strings.NewReader(content)), expected)
+		if _, err := ioutil.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Verify(); err != want {
+			t.Errorf("Verify(%q) = %v, want %v", content, err, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import "C"
 import (
 	"context"
 	"fmt"
+	"github.com/jylc/cloudserver/pkg/filesystem/checksum"
 	"github.com/jylc/cloudserver/pkg/filesystem/chunk/backoff"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/sirupsen/logrus"
@@ -25,6 +26,7 @@ type Group struct {
 	currentIndex int
 	chunkNum     uint64
 	bufferTemp   *os.File
+	digest       string
 }
 
 func NewGroup(file fsctx.FileHeader, chunkSize uint64, backoff backoff.Backoff, useBuffer bool) *Group {
@@ -84,6 +86,10 @@ func (c *Group) Process(processor ProcessFunc) error {
 		}
 	}
 
+	if err := c.computeDigest(); err != nil {
+		return err
+	}
+
 	err := processor(c, reader)
 	if err != nil {
 		if err != context.Canceled && (c.file.Seekable() || c.TempAvailable()) && c.backoff.Next() {
@@ -102,6 +108,40 @@ func (c *Group) Process(processor ProcessFunc) error {
 	return nil
 }
 
+// computeDigest 内容可以重新读取时，预先计算当前分片的 CRC32C 校验和
+func (c *Group) computeDigest() error {
+	c.digest = ""
+
+	var (
+		content io.ReadSeeker
+		start   int64
+	)
+	if c.TempAvailable() {
+		content = c.bufferTemp
+	} else if c.file.Seekable() {
+		content, start = c.file, c.Start()
+	} else {
+		return nil
+	}
+
+	res, err := checksum.Compute("crc32c", io.LimitReader(content, c.Length()))
+	if err != nil {
+		return fmt.Errorf("failed to compute chunk checksum: %w", err)
+	}
+
+	if _, err := content.Seek(start, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek back to chunk start: %w", err)
+	}
+
+	c.digest = res.String()
+	return nil
+}
+
+// Digest 当前分片的校验和，格式同 Digest 头，无法预先计算时为空
+func (c *Group) Digest() string {
+	return c.digest
+}
+
 func (c *Group) Start() int64 {
 	return int64(uint64(c.Index()) * c.chunkSize)
 }
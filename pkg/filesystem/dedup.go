@@ -0,0 +1,237 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/cache"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/sirupsen/logrus"
+	"hash"
+	"io"
+	"io/ioutil"
+)
+
+// hashReader 读取文件时同步计算 SHA-256
+type hashReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	read uint64
+}
+
+func newHashReader(file io.ReadCloser) *hashReader {
+	return &hashReader{
+		ReadCloser: file,
+		hash:       sha256.New(),
+	}
+}
+
+func (r *hashReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	r.read += uint64(n)
+	return n, err
+}
+
+// Sum 返回内容哈希，读取的数据量与文件大小不一致（如发生了 Seek）时返回空
+func (r *hashReader) Sum(size uint64) string {
+	if r.read != size {
+		return ""
+	}
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
+// FindDuplicate 查找当前存储策略下内容相同的物理文件
+func (fs *FileSystem) FindDuplicate(hash string, size uint64) (*models.Blob, bool) {
+	if hash == "" {
+		return nil, false
+	}
+
+	blob, err := models.GetBlob(fs.Policy.ID, hash)
+	if err != nil || blob.Size != size {
+		return nil, false
+	}
+	return blob, true
+}
+
+// Deduplicate 计算已上传完成文件的内容哈希，存在相同内容的物理文件时改为引用该文件，
+// 并删除重复写入的物理文件
+func (fs *FileSystem) Deduplicate(ctx context.Context, file *models.File, hash string) error {
+	if hash == "" {
+		content, err := fs.Handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, *file), file.SourceName)
+		if err != nil {
+			return err
+		}
+
+		hasher := newHashReader(content)
+		_, err = io.Copy(ioutil.Discard, hasher)
+		content.Close()
+		if err != nil {
+			return err
+		}
+
+		if hash = hasher.Sum(file.Size); hash == "" {
+			return ErrFileSizeMismatch
+		}
+	}
+
+	blob, ok := fs.FindDuplicate(hash, file.Size)
+	if !ok || blob.SourceName == file.SourceName {
+		return file.UpdateSHA256(hash)
+	}
+
+	// 仅在物理文件没有被其他记录引用时才能删除
+	duplicate := *file
+	linked, err := models.RemoveFilesWithSoftLinks([]models.File{duplicate})
+	if err != nil || len(linked) == 0 {
+		return file.UpdateSHA256(hash)
+	}
+
+	if err := file.LinkBlob(blob); err != nil {
+		return err
+	}
+
+	if _, err := fs.Handler.Delete(ctx, []string{duplicate.SourceName}); err != nil {
+		logrus.Warningf("Unable to delete duplicate file [%s], %s", duplicate.SourceName, err)
+	}
+	return nil
+}
+
+// HookDeduplicate 上传完成后在后台进行内容去重
+func HookDeduplicate(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	fileInfo := fileHeader.Info()
+	fileModel, ok := fileInfo.Model.(*models.File)
+	if !ok {
+		return nil
+	}
+
+	fs.runInBackground(func() {
+		if err := fs.Deduplicate(context.Background(), fileModel, fileInfo.SHA256); err != nil {
+			logrus.Debugf("Unable to deduplicate file [%s], %s", fileModel.Name, err)
+		}
+	})
+	return nil
+}
+
+// ChunkHasher 按顺序累加分片内容的 SHA-256，中间状态保存在上传会话中，
+// 最后一个分片完成后无需重新读取文件即可得到完整文件的哈希
+type ChunkHasher struct {
+	session *serializer.UploadSession
+	hash    hash.Hash
+	start   uint64
+	read    uint64
+}
+
+// NewChunkHasher 从上传会话中恢复哈希状态，分片与已计算的内容不连续（如重传已完成的分片）时返回 nil
+func NewChunkHasher(session *serializer.UploadSession, start uint64) *ChunkHasher {
+	if start != session.HashedSize {
+		return nil
+	}
+
+	hasher := sha256.New()
+	if start > 0 {
+		if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(session.HashState); err != nil {
+			return nil
+		}
+	}
+	return &ChunkHasher{session: session, hash: hasher, start: start}
+}
+
+func (h *ChunkHasher) Write(p []byte) (int, error) {
+	h.read += uint64(len(p))
+	return h.hash.Write(p)
+}
+
+// Wrap 读取分片内容时同步计算哈希
+func (h *ChunkHasher) Wrap(chunk io.ReadCloser) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(chunk, h), chunk}
+}
+
+// HookDrainChunk 引用已有物理文件时分片不写入，只读取内容用于计算哈希
+func HookDrainChunk(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	_, err := io.Copy(ioutil.Discard, fileHeader)
+	return err
+}
+
+// HookSaveChunkHash 分片写入成功后保存哈希状态，最后一个分片完成后设置完整文件的哈希，
+// 须在其他校验钩子之后执行
+func HookSaveChunkHash(h *ChunkHasher) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		if h.read != fileHeader.Info().Size {
+			return nil
+		}
+
+		state, err := h.hash.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		h.session.HashState = state
+		h.session.HashedSize = h.start + h.read
+		if h.session.HashedSize == h.session.Size {
+			fileHeader.SetSHA256(hex.EncodeToString(h.hash.Sum(nil)))
+		}
+		return cache.Set(UploadSessionCachePrefix+h.session.Key, *h.session, models.GetIntSetting("upload_session_timeout", 86400))
+	}
+}
+
+// HookVerifyLinkedContent 最后一个分片完成后校验内容哈希与声明一致，并将文件记录指向已有的物理文件，
+// 须在 HookSaveChunkHash 之后、HookPopPlaceholderToFile 之前执行
+func HookVerifyLinkedContent(session *serializer.UploadSession) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		fileInfo := fileHeader.Info()
+		if fileInfo.SHA256 != session.SHA256 {
+			return ErrContentMismatch
+		}
+
+		blob, ok := fs.FindDuplicate(session.SHA256, fileInfo.AppendStart+fileInfo.Size)
+		if !ok {
+			return ErrBlobNotExist
+		}
+
+		// 之后的钩子读取已有的物理文件，占位文件为空
+		if fileModel, ok := fileInfo.Model.(*models.File); ok {
+			fileModel.SourceName = blob.SourceName
+		}
+		return nil
+	}
+}
+
+// HookSaveSHA256 转为正式文件后保存完整文件的哈希
+func HookSaveSHA256(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	fileInfo := fileHeader.Info()
+	fileModel, ok := fileInfo.Model.(*models.File)
+	if !ok || fileInfo.SHA256 == "" || fileModel.SHA256 == fileInfo.SHA256 {
+		return nil
+	}
+	return fileModel.UpdateSHA256(fileInfo.SHA256)
+}
+
+// HookLinkBlob 转为正式文件后引用已有的物理文件，并删除空的占位文件
+func HookLinkBlob(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	fileInfo := fileHeader.Info()
+	fileModel, ok := fileInfo.Model.(*models.File)
+	if !ok {
+		return nil
+	}
+
+	blob, ok := fs.FindDuplicate(fileInfo.SHA256, fileModel.Size)
+	if !ok {
+		return ErrBlobNotExist
+	}
+
+	if err := fileModel.LinkBlob(blob); err != nil {
+		return err
+	}
+
+	if _, err := fs.Handler.Delete(ctx, []string{fileInfo.SavePath}); err != nil {
+		logrus.Warningf("Unable to delete upload placeholder [%s], %s", fileInfo.SavePath, err)
+	}
+	return nil
+}
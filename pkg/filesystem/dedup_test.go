@@ -0,0 +1,76 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/jylc/cloudserver/pkg/cache"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestChunkHasher(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello world"))
+	session := &serializer.UploadSession{Key: "chunk_hasher", Size: 11}
+	fs := &FileSystem{}
+
+	var (
+		file  *fsctx.FileStream
+		start uint64
+	)
+	for _, chunk := range []string{"hello", " ", "world"} {
+		// 每个分片使用缓存中的会话，模拟独立的请求
+		if cached, ok := cache.Get(UploadSessionCachePrefix + session.Key); ok {
+			restored := cached.(serializer.UploadSession)
+			session = &restored
+		}
+
+		// 重传已计算过的分片时不累加
+		if start > 0 && NewChunkHasher(session, 0) != nil {
+			t.Fatal("expected nil hasher for a retried chunk")
+		}
+
+		hasher := NewChunkHasher(session, start)
+		if hasher == nil {
+			t.Fatalf("unexpected nil hasher at %d", start)
+		}
+		file = &fsctx.FileStream{
+			File:        hasher.Wrap(ioutil.NopCloser(strings.NewReader(chunk))),
+			Size:        uint64(len(chunk)),
+			AppendStart: start,
+		}
+		if err := HookDrainChunk(context.Background(), fs, file); err != nil {
+			t.Fatal(err)
+		}
+		if err := HookSaveChunkHash(hasher)(context.Background(), fs, file); err != nil {
+			t.Fatal(err)
+		}
+		start += uint64(len(chunk))
+	}
+
+	if file.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("hash of chunks = %s, want %x", file.SHA256, sum)
+	}
+
+	// 未完整读取的分片不保存哈希状态
+	hasher := NewChunkHasher(&serializer.UploadSession{Key: "chunk_hasher_partial", Size: 11}, 0)
+	partial := &fsctx.FileStream{File: hasher.Wrap(ioutil.NopCloser(strings.NewReader("hello"))), Size: 11}
+	if err := HookDrainChunk(context.Background(), fs, partial); err != nil {
+		t.Fatal(err)
+	}
+	if err := HookSaveChunkHash(hasher)(context.Background(), fs, partial); err != nil || hasher.session.HashedSize != 0 || partial.SHA256 != "" {
+		t.Errorf("partial chunk should not be recorded, got %v, %d, %q", err, hasher.session.HashedSize, partial.SHA256)
+	}
+
+	if NewChunkHasher(&serializer.UploadSession{HashedSize: 5, HashState: []byte("invalid")}, 5) != nil {
+		t.Error("expected nil hasher for invalid hash state")
+	}
+
+	mismatch := &serializer.UploadSession{SHA256: strings.Repeat("0", 64)}
+	if err := HookVerifyLinkedContent(mismatch)(context.Background(), fs, file); err != ErrContentMismatch {
+		t.Errorf("err = %v, want %v", err, ErrContentMismatch)
+	}
+}
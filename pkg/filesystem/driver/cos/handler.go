@@ -0,0 +1,389 @@
+package cos
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/request"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/tencentyun/cos-go-sdk-v5"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// UploadPolicy 腾讯云COS上传策略
+type UploadPolicy struct {
+	Expiration string        `json:"expiration"`
+	Conditions []interface{} `json:"conditions"`
+}
+
+// MetaData 文件元信息
+type MetaData struct {
+	Size        uint64
+	CallbackKey string
+	CallbackURL string
+}
+
+// deleteBatchSize 单次批量删除的最大对象数
+const deleteBatchSize = 1000
+
+type urlOption struct {
+	Speed              int    `url:"x-cos-traffic-limit,omitempty"`
+	ContentDescription string `url:"response-content-disposition,omitempty"`
+}
+
+// Driver 腾讯云COS适配器
+type Driver struct {
+	Policy     *models.Policy
+	Client     *cos.Client
+	HTTPClient request.Client
+}
+
+// NewDriver 根据存储策略创建 COS 适配器
+func NewDriver(policy *models.Policy) (*Driver, error) {
+	u, err := url.Parse(policy.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	client := cos.NewClient(&cos.BaseURL{BucketURL: u}, &http.Client{
+		Transport: &cos.AuthorizationTransport{
+			SecretID:  policy.AccessKey,
+			SecretKey: policy.SecretKey,
+		},
+	})
+
+	return &Driver{
+		Policy:     policy,
+		Client:     client,
+		HTTPClient: request.NewClient(),
+	}, nil
+}
+
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.TrimPrefix(base, "/")
+	if base != "" && !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	opt := &cos.BucketGetOptions{
+		Prefix:  base,
+		MaxKeys: 1000,
+	}
+	if !recursive {
+		opt.Delimiter = "/"
+	}
+
+	var (
+		objects []cos.Object
+		commons []string
+	)
+	for {
+		res, _, err := handler.Client.Bucket.Get(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, res.Contents...)
+		commons = append(commons, res.CommonPrefixes...)
+
+		if !res.IsTruncated || res.NextMarker == "" {
+			break
+		}
+		opt.Marker = res.NextMarker
+	}
+
+	res := make([]response.Object, 0, len(objects)+len(commons))
+	for _, object := range commons {
+		rel := strings.TrimSuffix(strings.TrimPrefix(object, base), "/")
+		res = append(res, response.Object{
+			Name:         path.Base(object),
+			RelativePath: rel,
+			Source:       object,
+			IsDir:        true,
+			LastModify:   time.Now(),
+		})
+	}
+
+	for _, object := range objects {
+		if strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+
+		lastModify, _ := time.Parse(time.RFC3339, object.LastModified)
+		res = append(res, response.Object{
+			Name:         path.Base(object.Key),
+			RelativePath: strings.TrimPrefix(object.Key, base),
+			Source:       object.Key,
+			Size:         uint64(object.Size),
+			IsDir:        false,
+			LastModify:   lastModify,
+		})
+	}
+
+	return res, nil
+}
+
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	downloadURL, err := handler.Source(ctx, path, url.URL{}, int64(models.GetIntSetting("preview_timeout", 60)), false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := handler.HTTPClient.Request(
+		"GET",
+		downloadURL,
+		nil,
+		request.WithContext(ctx),
+		request.WithTimeout(time.Duration(0)),
+	).CheckHTTPResponse(200).GetRSCloser()
+	if err != nil {
+		return nil, err
+	}
+
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(models.File); ok {
+		resp.SetContentLength(int64(file.Size))
+	}
+	return resp, nil
+}
+
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+
+	opt := &cos.ObjectPutOptions{
+		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
+			ContentLength: int64(fileInfo.Size),
+		},
+	}
+	_, err := handler.Client.Object.Put(ctx, fileInfo.SavePath, file, opt)
+	return err
+}
+
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var lastErr error
+
+	// 单次批量删除最多 1000 个对象
+	for start := 0; start < len(files); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(files) {
+			end = len(files)
+		}
+
+		obs := make([]cos.Object, 0, end-start)
+		for _, v := range files[start:end] {
+			obs = append(obs, cos.Object{Key: v})
+		}
+
+		res, _, err := handler.Client.Object.DeleteMulti(ctx, &cos.ObjectDeleteMultiOptions{
+			Objects: obs,
+			Quiet:   true,
+		})
+		if err != nil {
+			lastErr = err
+			failed = append(failed, files[start:end]...)
+			continue
+		}
+
+		for _, v := range res.Errors {
+			failed = append(failed, v.Key)
+			lastErr = errors.New("failed to delete some objects")
+		}
+	}
+
+	return failed, lastErr
+}
+
+func (handler *Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	thumbSize, ok := ctx.Value(fsctx.ThumbSizeCtx).([2]uint)
+	if !ok {
+		return nil, errors.New("failed to get thumbnail size")
+	}
+
+	source, err := handler.signSourceURL(ctx, path, int64(models.GetIntSetting("preview_timeout", 60)), &urlOption{})
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用数据万象处理缩略图
+	thumbURL, _ := url.Parse(source)
+	thumbParam := fmt.Sprintf("imageMogr2/thumbnail/%dx%d", thumbSize[0], thumbSize[1])
+	if thumbURL.RawQuery == "" {
+		thumbURL.RawQuery = thumbParam
+	} else {
+		thumbURL.RawQuery += "&" + thumbParam
+	}
+
+	return &response.ContentResponse{
+		Redirect: true,
+		URL:      thumbURL.String(),
+	}, nil
+}
+
+func (handler *Driver) Source(ctx context.Context, path string, baseURL url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	fileName := ""
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(models.File); ok {
+		fileName = file.Name
+	}
+
+	options := urlOption{}
+	if speed > 0 {
+		// COS 单链接限速范围为 100KB/s 到 100MB/s，单位为 bit/s
+		speed *= 8
+		if speed < 819200 {
+			speed = 819200
+		}
+		if speed > 838860800 {
+			speed = 838860800
+		}
+		options.Speed = speed
+	}
+
+	if isDownload {
+		options.ContentDescription = "attachment; filename=\"" + url.PathEscape(fileName) + "\""
+	}
+
+	return handler.signSourceURL(ctx, path, ttl, &options)
+}
+
+func (handler *Driver) signSourceURL(ctx context.Context, path string, ttl int64, options *urlOption) (string, error) {
+	// 未设置加速域名时使用存储桶域名
+	cdnURL := handler.Client.BaseURL.BucketURL
+	if handler.Policy.BaseURL != "" {
+		var err error
+		if cdnURL, err = url.Parse(handler.Policy.BaseURL); err != nil {
+			return "", err
+		}
+	}
+
+	// 公有空间不需要签名
+	if !handler.Policy.IsPrivate {
+		sourceURL := cdnURL.ResolveReference(&url.URL{Path: path})
+		query := sourceURL.Query()
+		if options.ContentDescription != "" {
+			query.Add("response-content-disposition", options.ContentDescription)
+		}
+		if options.Speed > 0 {
+			query.Add("x-cos-traffic-limit", fmt.Sprint(options.Speed))
+		}
+		sourceURL.RawQuery = query.Encode()
+		return sourceURL.String(), nil
+	}
+
+	presignedURL, err := handler.Client.Object.GetPresignedURL(ctx, http.MethodGet, path,
+		handler.Policy.AccessKey, handler.Policy.SecretKey, time.Duration(ttl)*time.Second, options)
+	if err != nil {
+		return "", err
+	}
+
+	// 将最终生成的签名URL域名换成用户自定义的加速域名（如果有）
+	presignedURL.Host = cdnURL.Host
+	presignedURL.Scheme = cdnURL.Scheme
+
+	return presignedURL.String(), nil
+}
+
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	fileInfo := file.Info()
+
+	siteURL := models.GetSiteURL()
+	apiURL := siteURL.ResolveReference(&url.URL{Path: "/api/v3/callback/cos/" + uploadSession.Key}).String()
+	uploadSession.Callback = apiURL
+
+	startTime := time.Now()
+	endTime := startTime.Add(time.Duration(ttl) * time.Second)
+	keyTime := fmt.Sprintf("%d;%d", startTime.Unix(), endTime.Unix())
+	postPolicy := UploadPolicy{
+		Expiration: endTime.UTC().Format(time.RFC3339),
+		Conditions: []interface{}{
+			map[string]string{"bucket": handler.Policy.BucketName},
+			map[string]string{"$key": fileInfo.SavePath},
+			map[string]string{"x-cos-meta-callback": apiURL},
+			map[string]string{"x-cos-meta-key": uploadSession.Key},
+			map[string]string{"q-sign-algorithm": "sha1"},
+			map[string]string{"q-ak": handler.Policy.AccessKey},
+			map[string]string{"q-sign-time": keyTime},
+		},
+	}
+
+	if handler.Policy.MaxSize > 0 {
+		postPolicy.Conditions = append(postPolicy.Conditions,
+			[]interface{}{"content-length-range", 0, handler.Policy.MaxSize})
+	}
+
+	res, err := handler.getUploadCredential(postPolicy, keyTime, fileInfo.SavePath)
+	if err != nil {
+		return nil, err
+	}
+
+	res.SessionID = uploadSession.Key
+	res.Callback = apiURL
+	res.UploadURLs = []string{handler.Policy.Server}
+	return res, nil
+}
+
+// getUploadCredential 签名表单上传策略
+func (handler *Driver) getUploadCredential(policy UploadPolicy, keyTime string, savePath string) (*serializer.UploadCredential, error) {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	policyEncoded := base64.StdEncoding.EncodeToString(policyJSON)
+
+	// 签名密钥
+	hmacSign := hmac.New(sha1.New, []byte(handler.Policy.SecretKey))
+	if _, err := io.WriteString(hmacSign, keyTime); err != nil {
+		return nil, err
+	}
+	signKey := fmt.Sprintf("%x", hmacSign.Sum(nil))
+
+	// 待签名字符串
+	sha1Sign := sha1.New()
+	if _, err := sha1Sign.Write(policyJSON); err != nil {
+		return nil, err
+	}
+	stringToSign := fmt.Sprintf("%x", sha1Sign.Sum(nil))
+
+	// 最终签名
+	hmacFinalSign := hmac.New(sha1.New, []byte(signKey))
+	if _, err := hmacFinalSign.Write([]byte(stringToSign)); err != nil {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{
+		Policy:     policyEncoded,
+		Path:       savePath,
+		AccessKey:  handler.Policy.AccessKey,
+		Credential: fmt.Sprintf("%x", hmacFinalSign.Sum(nil)),
+		KeyTime:    keyTime,
+	}, nil
+}
+
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return nil
+}
+
+// Meta 获取文件信息
+func (handler *Driver) Meta(ctx context.Context, path string) (*MetaData, error) {
+	res, err := handler.Client.Object.Head(ctx, path, &cos.ObjectHeadOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetaData{
+		Size:        uint64(res.ContentLength),
+		CallbackKey: res.Header.Get("x-cos-meta-key"),
+		CallbackURL: res.Header.Get("x-cos-meta-callback"),
+	}, nil
+}
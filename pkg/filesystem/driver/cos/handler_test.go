@@ -0,0 +1,233 @@
+package cos
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"hash/crc64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestDriver 创建指向测试服务器的公有空间 COS 适配器
+func newTestDriver(t *testing.T, handler http.HandlerFunc) *Driver {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	driver, err := NewDriver(&models.Policy{
+		Type:       "cos",
+		Server:     server.URL,
+		BaseURL:    server.URL,
+		BucketName: "bucket-1250000000",
+		AccessKey:  "ak",
+		SecretKey:  "sk",
+		MaxSize:    1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return driver
+}
+
+func TestDriver_Token(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	})
+
+	uploadSession := &serializer.UploadSession{Key: "session"}
+	credential, err := driver.Token(context.Background(), 3600, uploadSession, &fsctx.FileStream{
+		Size:     1,
+		SavePath: "dir/file.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(uploadSession.Callback, "/api/v3/callback/cos/session") || credential.Callback != uploadSession.Callback {
+		t.Errorf("callback = %q, %q", uploadSession.Callback, credential.Callback)
+	}
+	if credential.SessionID != "session" || credential.Path != "dir/file.txt" || credential.AccessKey != "ak" {
+		t.Errorf("credential = %+v", credential)
+	}
+	if len(credential.UploadURLs) != 1 || credential.UploadURLs[0] != driver.Policy.Server {
+		t.Errorf("upload urls = %v", credential.UploadURLs)
+	}
+	if credential.Credential == "" || !strings.Contains(credential.KeyTime, ";") {
+		t.Errorf("missing signature, credential = %+v", credential)
+	}
+
+	policyJSON, err := base64.StdEncoding.DecodeString(credential.Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var policy UploadPolicy
+	if err := json.Unmarshal(policyJSON, &policy); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`{"$key":"dir/file.txt"}`, `{"x-cos-meta-key":"session"}`, `["content-length-range",0,1024]`} {
+		if !strings.Contains(string(policyJSON), expected) {
+			t.Errorf("upload policy %s does not contain %s", policyJSON, expected)
+		}
+	}
+}
+
+func TestDriver_Meta(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if r.URL.Path != "/file.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "5")
+		w.Header().Set("x-cos-meta-key", "session")
+		w.Header().Set("x-cos-meta-callback", "http://localhost/api/v3/callback/cos/session")
+	})
+
+	meta, err := driver.Meta(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Size != 5 || meta.CallbackKey != "session" || meta.CallbackURL != "http://localhost/api/v3/callback/cos/session" {
+		t.Errorf("meta = %+v", meta)
+	}
+
+	if _, err := driver.Meta(context.Background(), "missing.txt"); err == nil {
+		t.Error("expected error for missing object")
+	}
+}
+
+func TestDriver_Get(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/file.txt" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte("hello"))
+	})
+
+	content, err := driver.Get(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("content = %q", data)
+	}
+}
+
+func TestDriver_Put(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/dir/file.txt" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "hello" {
+			t.Errorf("uploaded content = %q", body)
+		}
+
+		// SDK 会校验响应中的 CRC64
+		w.Header().Set("x-cos-hash-crc64ecma", strconv.FormatUint(crc64.Checksum(body, crc64.MakeTable(crc64.ECMA)), 10))
+	})
+
+	err := driver.Put(context.Background(), &fsctx.FileStream{
+		File:     ioutil.NopCloser(strings.NewReader("hello")),
+		Size:     5,
+		SavePath: "dir/file.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["delete"]; r.Method != http.MethodPost || !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(string(body), "<Key>a.txt</Key>") || !strings.Contains(string(body), "<Key>b.txt</Key>") {
+			t.Errorf("unexpected delete body %s", body)
+		}
+		w.Write([]byte(`<DeleteResult><Error><Key>b.txt</Key><Code>AccessDenied</Code><Message>denied</Message></Error></DeleteResult>`))
+	})
+
+	failed, err := driver.Delete(context.Background(), []string{"a.txt", "b.txt"})
+	if err == nil {
+		t.Error("expected error for failed object")
+	}
+	if len(failed) != 1 || failed[0] != "b.txt" {
+		t.Errorf("failed = %v", failed)
+	}
+}
+
+func TestDriver_DeleteBatch(t *testing.T) {
+	var batches []int
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		batches = append(batches, strings.Count(string(body), "<Key>"))
+		w.Write([]byte(`<DeleteResult></DeleteResult>`))
+	})
+
+	files := make([]string, deleteBatchSize+1)
+	for i := range files {
+		files[i] = strconv.Itoa(i) + ".txt"
+	}
+
+	failed, err := driver.Delete(context.Background(), files)
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("failed = %v, err = %v", failed, err)
+	}
+	if len(batches) != 2 || batches[0] != deleteBatchSize || batches[1] != 1 {
+		t.Errorf("batches = %v", batches)
+	}
+}
+
+func TestDriver_Source(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {})
+	bucketURL := driver.Client.BaseURL.BucketURL.String()
+
+	// 未设置加速域名时使用存储桶域名，对象名中的特殊字符需转义
+	driver.Policy.BaseURL = ""
+	res, err := driver.Source(context.Background(), "uploads/a #1?.txt", url.URL{}, 60, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != bucketURL+"/uploads/a%20%231%3F.txt" {
+		t.Errorf("source = %s", res)
+	}
+
+	driver.Policy.IsPrivate = true
+	res, err = driver.Source(context.Background(), "uploads/a.txt", url.URL{}, 60, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res, bucketURL+"/uploads/a.txt?") {
+		t.Errorf("signed source = %s", res)
+	}
+
+	driver.Policy.BaseURL = "https://cdn.example.com"
+	res, err = driver.Source(context.Background(), "uploads/a.txt", url.URL{}, 60, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res, "https://cdn.example.com/uploads/a.txt?") {
+		t.Errorf("signed source with CDN = %s", res)
+	}
+}
@@ -0,0 +1,247 @@
+package encrypt
+
+import (
+	"context"
+	"crypto/cipher"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/local"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"io"
+	"net/url"
+)
+
+// Driver 静态加密适配器，包装任意存储适配器，写入时加密、读取时解密
+type Driver struct {
+	Handler driver.Handler
+	Policy  *models.Policy
+}
+
+// truncater 支持截断文件的存储适配器
+type truncater interface {
+	Truncate(ctx context.Context, src string, size uint64) error
+}
+
+// readCloser 读取加密后的数据，关闭时关闭原始文件
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// NewDriver 使用存储策略的数据密钥包装存储适配器
+func NewDriver(handler driver.Handler, policy *models.Policy) (*Driver, error) {
+	option := policy.OptionsSerialized.Encryption
+	if option == nil || len(option.Keys) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	// 分片上传的分片大小须与加密分片对齐
+	if size := policy.OptionsSerialized.ChunkSize; size%ChunkSize != 0 {
+		policy.OptionsSerialized.ChunkSize = (size/ChunkSize + 1) * ChunkSize
+	}
+
+	return &Driver{
+		Handler: handler,
+		Policy:  policy,
+	}, nil
+}
+
+// cipherContext 将上下文中文件记录的大小替换为密文大小，返回明文大小，
+// 上下文中没有文件记录时返回 -1
+func cipherContext(ctx context.Context) (context.Context, int64) {
+	file, ok := ctx.Value(fsctx.FileModelCtx).(models.File)
+	if !ok {
+		return ctx, -1
+	}
+
+	size := int64(file.Size)
+	file.Size = CipherSize(file.Size)
+	return context.WithValue(ctx, fsctx.FileModelCtx, file), size
+}
+
+// fileCipher 获取文件头对应的文件密钥
+func (handler *Driver) fileCipher(h *header) (cipher.AEAD, error) {
+	key, err := DataKey(handler.Policy, h.Version)
+	if err != nil {
+		return nil, err
+	}
+	return h.fileCipher(key)
+}
+
+// KeyVersion 读取文件加密所使用的密钥版本
+func (handler *Driver) KeyVersion(ctx context.Context, path string) (uint32, error) {
+	src, err := handler.Handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, nil), path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	h, err := readHeader(src)
+	if err != nil {
+		return 0, err
+	}
+	return h.Version, nil
+}
+
+// open 打开密文并返回解密后的读取器，size 为 -1 时根据密文大小计算明文大小
+func (handler *Driver) open(ctx context.Context, path string, size int64) (response.RSCloser, error) {
+	src, err := handler.Handler.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 0 {
+		total, err := src.Seek(0, io.SeekEnd)
+		if err != nil {
+			src.Close()
+			return nil, err
+		}
+		size = int64(PlainSize(uint64(total)))
+
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			src.Close()
+			return nil, err
+		}
+	}
+
+	h, err := readHeader(src)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+
+	aead, err := handler.fileCipher(h)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+
+	return newDecryptReader(src, aead, size), nil
+}
+
+func (handler *Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	objects, err := handler.Handler.List(ctx, path, recursive)
+	for i := range objects {
+		if !objects[i].IsDir {
+			objects[i].Size = PlainSize(objects[i].Size)
+		}
+	}
+	return objects, err
+}
+
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	ctx, size := cipherContext(ctx)
+	return handler.open(ctx, path, size)
+}
+
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	stream := &fsctx.FileStream{
+		Mode:            fileInfo.Mode,
+		LastModified:    fileInfo.LastModified,
+		Metadata:        fileInfo.Metadata,
+		VirtualPath:     fileInfo.VirtualPath,
+		Name:            fileInfo.FileName,
+		MIMEType:        fileInfo.MIMEType,
+		SavePath:        fileInfo.SavePath,
+		UploadSessionID: fileInfo.UploadSessionID,
+		Model:           fileInfo.Model,
+		Src:             fileInfo.Src,
+	}
+
+	var (
+		h     *header
+		err   error
+		index uint64
+		head  []byte
+	)
+
+	if fileInfo.Mode&fsctx.Append == fsctx.Append && fileInfo.AppendStart > 0 {
+		// 追加分片时沿用已上传部分的文件头
+		if fileInfo.AppendStart%ChunkSize != 0 {
+			file.Close()
+			return ErrUnalignedAppend
+		}
+
+		src, err := handler.Handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, nil), fileInfo.SavePath)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		h, err = readHeader(src)
+		src.Close()
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		index = fileInfo.AppendStart / ChunkSize
+		stream.AppendStart = cipherOffset(index)
+		stream.Size = CipherSize(fileInfo.Size) - HeaderSize
+	} else {
+		h, err = newHeader(handler.Policy.OptionsSerialized.Encryption.KeyVersion)
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		head = h.marshal()
+		stream.Size = CipherSize(fileInfo.Size)
+	}
+
+	aead, err := handler.fileCipher(h)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	stream.File = readCloser{
+		Reader: newEncryptReader(file, aead, index, head),
+		Closer: file,
+	}
+	return handler.Handler.Put(ctx, stream)
+}
+
+// Truncate 将文件截断至明文大小，仅在底层适配器支持时有效
+func (handler *Driver) Truncate(ctx context.Context, src string, size uint64) error {
+	if inner, ok := handler.Handler.(truncater); ok {
+		return inner.Truncate(ctx, src, CipherSize(size))
+	}
+	return nil
+}
+
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	return handler.Handler.Delete(ctx, files)
+}
+
+// Thumb 缩略图同样加密存储，不能由存储端处理
+func (handler *Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, nil)
+	file, err := handler.open(ctx, path+models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb"), -1)
+	if err != nil {
+		return nil, err
+	}
+	return &response.ContentResponse{
+		Redirect: false,
+		Content:  file,
+	}, nil
+}
+
+// Source 存储端只保存密文，由主机解密后中转下载
+func (handler *Driver) Source(ctx context.Context, path string, baseURL url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	return local.Driver{Policy: handler.Policy}.Source(ctx, path, baseURL, ttl, isDownload, speed)
+}
+
+// Token 加密须在主机完成，文件统一由主机中转上传
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return nil
+}
@@ -0,0 +1,150 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/conf"
+	"io"
+)
+
+const (
+	// WrappingSystem 使用站点密钥包装数据密钥
+	WrappingSystem = "system"
+	// WrappingConfig 使用配置文件中的主密钥包装数据密钥
+	WrappingConfig = "config"
+
+	keySize = 32
+)
+
+var (
+	ErrKeyNotFound         = errors.New("data key of the given version does not exist")
+	ErrMasterKeyNotSet     = errors.New("encryption_key is not set in the config file")
+	ErrUnknownWrappingType = errors.New("unknown key wrapping type")
+)
+
+// masterKey 获取用于包装数据密钥的主密钥
+func masterKey(option *models.EncryptionOption) ([]byte, error) {
+	var secret string
+	switch option.KeyWrapping {
+	case "", WrappingSystem:
+		secret = models.GetSettingByName("secret_key")
+	case WrappingConfig:
+		secret = conf.Sc.EncryptionKey
+		if secret == "" {
+			return nil, ErrMasterKeyNotSet
+		}
+	default:
+		return nil, ErrUnknownWrappingType
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:], nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// wrapKey 使用主密钥加密数据密钥
+func wrapKey(option *models.EncryptionOption, key []byte) (string, error) {
+	master, err := masterKey(option)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newGCM(master)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, key, nil)), nil
+}
+
+// unwrapKey 使用主密钥解密数据密钥
+func unwrapKey(option *models.EncryptionOption, wrapped string) ([]byte, error) {
+	master, err := masterKey(option)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) < aead.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+
+	key, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key, master key may have changed: %w", err)
+	}
+	return key, nil
+}
+
+// DataKey 获取存储策略指定版本的数据密钥
+func DataKey(policy *models.Policy, version uint32) ([]byte, error) {
+	option := policy.OptionsSerialized.Encryption
+	if option == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	wrapped, ok := option.Keys[version]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return unwrapKey(option, wrapped)
+}
+
+// NewKey 为存储策略生成新版本的数据密钥，并设为当前版本。
+// 只修改 policy 中的设置，不会保存到数据库
+func NewKey(policy *models.Policy) error {
+	option := policy.OptionsSerialized.Encryption
+	if option == nil {
+		option = &models.EncryptionOption{}
+		policy.OptionsSerialized.Encryption = option
+	}
+
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+
+	wrapped, err := wrapKey(option, key)
+	if err != nil {
+		return err
+	}
+
+	version := option.KeyVersion + 1
+	for _, ok := option.Keys[version]; ok; _, ok = option.Keys[version] {
+		version++
+	}
+
+	if option.Keys == nil {
+		option.Keys = make(map[uint32]string)
+	}
+	option.Keys[version] = wrapped
+	option.KeyVersion = version
+	return nil
+}
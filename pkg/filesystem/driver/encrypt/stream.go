@@ -0,0 +1,261 @@
+package encrypt
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"io"
+)
+
+// 加密文件格式：
+//
+//	| magic(4) | 密钥版本(4) | salt(16) | 分片 0 | 分片 1 | ... |
+//
+// 每个分片为不超过 ChunkSize 字节的明文经 AES-GCM 加密后的密文及 16 字节的认证标签，
+// nonce 由分片序号生成，文件密钥由数据密钥和 salt 派生，因此可以按分片随机读取。
+const (
+	// ChunkSize 明文分片大小
+	ChunkSize = 64 << 10
+	// HeaderSize 文件头大小
+	HeaderSize = 24
+
+	saltSize    = 16
+	tagSize     = 16
+	sealedChunk = ChunkSize + tagSize
+)
+
+var magic = [4]byte{'C', 'S', 'E', 1}
+
+var (
+	ErrInvalidHeader   = errors.New("file is not encrypted or the header is corrupted")
+	ErrChunkCorrupted  = errors.New("encrypted chunk is corrupted")
+	ErrInvalidSeek     = errors.New("invalid seek offset")
+	ErrUnalignedAppend = errors.New("append offset must be aligned to the encryption chunk size")
+)
+
+// header 加密文件头
+type header struct {
+	Version uint32
+	Salt    [saltSize]byte
+}
+
+func newHeader(version uint32) (*header, error) {
+	h := &header{Version: version}
+	if _, err := io.ReadFull(rand.Reader, h.Salt[:]); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *header) marshal() []byte {
+	buf := make([]byte, HeaderSize)
+	copy(buf, magic[:])
+	binary.BigEndian.PutUint32(buf[4:8], h.Version)
+	copy(buf[8:], h.Salt[:])
+	return buf
+}
+
+// readHeader 从 reader 中读取文件头
+func readHeader(r io.Reader) (*header, error) {
+	buf := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	if [4]byte{buf[0], buf[1], buf[2], buf[3]} != magic {
+		return nil, ErrInvalidHeader
+	}
+
+	h := &header{Version: binary.BigEndian.Uint32(buf[4:8])}
+	copy(h.Salt[:], buf[8:])
+	return h, nil
+}
+
+// fileCipher 由数据密钥和文件 salt 派生文件密钥
+func (h *header) fileCipher(dataKey []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write(h.Salt[:])
+	return newGCM(mac.Sum(nil))
+}
+
+func chunkNonce(aead cipher.AEAD, index uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], index)
+	return nonce
+}
+
+// CipherSize 计算明文加密后的大小（含文件头）
+func CipherSize(size uint64) uint64 {
+	return HeaderSize + size + tagSize*((size+ChunkSize-1)/ChunkSize)
+}
+
+// PlainSize 根据密文大小（含文件头）计算明文大小
+func PlainSize(size uint64) uint64 {
+	if size <= HeaderSize {
+		return 0
+	}
+
+	size -= HeaderSize
+	res := size / sealedChunk * ChunkSize
+	if rem := size % sealedChunk; rem > tagSize {
+		res += rem - tagSize
+	}
+	return res
+}
+
+// cipherOffset 明文分片在密文中的起始位置
+func cipherOffset(index uint64) uint64 {
+	return HeaderSize + index*sealedChunk
+}
+
+// encryptReader 读取时加密来源中的明文
+type encryptReader struct {
+	src   io.Reader
+	aead  cipher.AEAD
+	index uint64
+
+	plain  []byte
+	sealed []byte
+	out    []byte
+	eof    bool
+}
+
+func newEncryptReader(src io.Reader, aead cipher.AEAD, index uint64, head []byte) *encryptReader {
+	return &encryptReader{
+		src:    src,
+		aead:   aead,
+		index:  index,
+		plain:  make([]byte, ChunkSize),
+		sealed: make([]byte, 0, sealedChunk),
+		out:    head,
+	}
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(r.src, r.plain)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+
+		if n > 0 {
+			r.out = r.aead.Seal(r.sealed[:0], chunkNonce(r.aead, r.index), r.plain[:n], nil)
+			r.index++
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// decryptReader 解密密文，支持按分片随机读取
+type decryptReader struct {
+	src  response.RSCloser
+	aead cipher.AEAD
+	size int64
+
+	offset   int64
+	srcPos   int64
+	chunk    []byte
+	chunkIdx int64
+	sealed   []byte
+}
+
+func newDecryptReader(src response.RSCloser, aead cipher.AEAD, size int64) *decryptReader {
+	return &decryptReader{
+		src:      src,
+		aead:     aead,
+		size:     size,
+		srcPos:   HeaderSize,
+		chunkIdx: -1,
+		sealed:   make([]byte, sealedChunk),
+	}
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	index := r.offset / ChunkSize
+	if index != r.chunkIdx {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.chunk[r.offset-index*ChunkSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// load 读取并解密指定分片
+func (r *decryptReader) load(index int64) error {
+	start := int64(cipherOffset(uint64(index)))
+	if start != r.srcPos {
+		if _, err := r.src.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		r.srcPos = start
+	}
+
+	plainLen := r.size - index*ChunkSize
+	if plainLen > ChunkSize {
+		plainLen = ChunkSize
+	}
+
+	sealed := r.sealed[:plainLen+tagSize]
+	n, err := io.ReadFull(r.src, sealed)
+	r.srcPos += int64(n)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrChunkCorrupted
+		}
+		return err
+	}
+
+	r.chunk, err = r.aead.Open(r.chunk[:0], chunkNonce(r.aead, uint64(index)), sealed, nil)
+	if err != nil {
+		r.chunkIdx = -1
+		return ErrChunkCorrupted
+	}
+
+	r.chunkIdx = index
+	return nil
+}
+
+func (r *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = r.offset + offset
+	case io.SeekEnd:
+		target = r.size + offset
+	default:
+		return 0, ErrInvalidSeek
+	}
+
+	if target < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	r.offset = target
+	return target, nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.src.Close()
+}
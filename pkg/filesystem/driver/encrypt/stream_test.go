@@ -0,0 +1,131 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCipherSize(t *testing.T) {
+	testCases := []struct {
+		plain  uint64
+		cipher uint64
+	}{
+		{0, HeaderSize},
+		{1, HeaderSize + 1 + tagSize},
+		{ChunkSize - 1, HeaderSize + ChunkSize - 1 + tagSize},
+		{ChunkSize, HeaderSize + sealedChunk},
+		{ChunkSize + 1, HeaderSize + sealedChunk + 1 + tagSize},
+		{3 * ChunkSize, HeaderSize + 3*sealedChunk},
+	}
+
+	for _, testCase := range testCases {
+		if res := CipherSize(testCase.plain); res != testCase.cipher {
+			t.Errorf("CipherSize(%d) = %d, want %d", testCase.plain, res, testCase.cipher)
+		}
+		if res := PlainSize(testCase.cipher); res != testCase.plain {
+			t.Errorf("PlainSize(%d) = %d, want %d", testCase.cipher, res, testCase.plain)
+		}
+	}
+}
+
+func TestPlainSize(t *testing.T) {
+	// 不完整的密文按已有的完整明文计算
+	testCases := []struct {
+		cipher uint64
+		plain  uint64
+	}{
+		{0, 0},
+		{HeaderSize - 1, 0},
+		{HeaderSize + tagSize, 0},
+		{HeaderSize + sealedChunk + tagSize, ChunkSize},
+		{HeaderSize + sealedChunk + tagSize + 5, ChunkSize + 5},
+	}
+
+	for _, testCase := range testCases {
+		if res := PlainSize(testCase.cipher); res != testCase.plain {
+			t.Errorf("PlainSize(%d) = %d, want %d", testCase.cipher, res, testCase.plain)
+		}
+	}
+}
+
+func TestCipherOffset(t *testing.T) {
+	testCases := []struct {
+		index  uint64
+		offset uint64
+	}{
+		{0, HeaderSize},
+		{1, HeaderSize + sealedChunk},
+		{10, HeaderSize + 10*sealedChunk},
+	}
+
+	for _, testCase := range testCases {
+		if res := cipherOffset(testCase.index); res != testCase.offset {
+			t.Errorf("cipherOffset(%d) = %d, want %d", testCase.index, res, testCase.offset)
+		}
+	}
+}
+
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+// openSealed 读取文件头后创建解密 reader
+func openSealed(t *testing.T, sealed []byte, aead cipher.AEAD, size int) *decryptReader {
+	src := nopSeekCloser{bytes.NewReader(sealed)}
+	if _, err := readHeader(src); err != nil {
+		t.Fatal(err)
+	}
+	return newDecryptReader(src, aead, int64(size))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	h, err := newHeader(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := h.fileCipher(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := make([]byte, 2*ChunkSize+100)
+	for i := range plain {
+		plain[i] = byte(i % 251)
+	}
+
+	sealed, err := ioutil.ReadAll(newEncryptReader(bytes.NewReader(plain), aead, 0, h.marshal()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(sealed)) != CipherSize(uint64(len(plain))) {
+		t.Fatalf("cipher size = %d, want %d", len(sealed), CipherSize(uint64(len(plain))))
+	}
+
+	r := openSealed(t, sealed, aead, len(plain))
+	if res, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(res, plain) {
+		t.Fatalf("decrypted content mismatch, err = %v", err)
+	}
+
+	// 跨分片随机读取
+	if _, err := r.Seek(ChunkSize-10, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 20)
+	if _, err := io.ReadFull(r, buf); err != nil || !bytes.Equal(buf, plain[ChunkSize-10:ChunkSize+10]) {
+		t.Errorf("read after seek = %v, err = %v", buf, err)
+	}
+
+	// 篡改的分片无法解密
+	sealed[cipherOffset(1)] ^= 1
+	r = openSealed(t, sealed, aead, len(plain))
+	if _, err := ioutil.ReadAll(r); err != ErrChunkCorrupted {
+		t.Errorf("err = %v, want %v", err, ErrChunkCorrupted)
+	}
+}
@@ -127,17 +127,24 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 				return fmt.Errorf("an error occurred while overwriting the fragment: %w\n", err)
 			}
 
-			out, err := os.OpenFile(dst, openMode, Perm)
-			defer out.Close()
+			out, err = os.OpenFile(dst, openMode, Perm)
 			if err != nil {
 				logrus.Warningf("cannot open or create file, %s\n", err)
 				return err
 			}
+			defer out.Close()
 		}
 	}
 
-	_, err = io.Copy(out, file)
-	return err
+	// 写入的数据量与声明的大小不一致时，内容可能已被截断
+	n, err := io.Copy(out, file)
+	if err != nil {
+		return err
+	}
+	if uint64(n) != fileInfo.Size {
+		return fmt.Errorf("written size %d does not match expected size %d", n, fileInfo.Size)
+	}
+	return nil
 }
 
 func (handler Driver) Truncate(ctx context.Context, src string, size uint64) error {
@@ -0,0 +1,267 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/local"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/url"
+	"os"
+)
+
+const bufferTempPattern = "cdMirror.*.tmp"
+
+var (
+	ErrNotEnoughReplicas = errors.New("mirror policy requires at least two replica policies")
+	ErrInvalidReplica    = errors.New("replica policy cannot be the mirror policy itself or another mirror policy")
+	ErrNoHealthyReplica  = errors.New("no healthy replica available")
+	ErrAppendUnsupported = errors.New("replica policy does not support appending chunks")
+)
+
+// Replica 镜像存储策略的一个副本
+type Replica struct {
+	Policy  *models.Policy
+	Handler driver.Handler
+}
+
+// Driver 镜像存储适配器，将文件同时写入多个副本存储策略，从第一个可用的副本读取
+type Driver struct {
+	Policy   *models.Policy
+	Replicas []Replica
+}
+
+// DispatchFunc 获取副本存储策略的处理器
+type DispatchFunc func(policy *models.Policy) (driver.Handler, error)
+
+// truncater 支持截断文件的存储适配器
+type truncater interface {
+	Truncate(ctx context.Context, src string, size uint64) error
+}
+
+// nopCloser 副本写入完成后不关闭共用的缓冲文件
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// NewDriver 根据存储策略中的副本设置创建镜像存储适配器
+func NewDriver(policy *models.Policy, dispatch DispatchFunc) (*Driver, error) {
+	if len(policy.OptionsSerialized.Replicas) < 2 {
+		return nil, ErrNotEnoughReplicas
+	}
+
+	handler := &Driver{
+		Policy:   policy,
+		Replicas: make([]Replica, 0, len(policy.OptionsSerialized.Replicas)),
+	}
+	appendable := true
+	for _, id := range policy.OptionsSerialized.Replicas {
+		replica, err := models.GetPolicyByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("replica policy %d not found: %w", id, err)
+		}
+
+		if replica.ID == policy.ID || replica.Type == "mirror" {
+			return nil, ErrInvalidReplica
+		}
+
+		inner, err := dispatch(&replica)
+		if err != nil {
+			return nil, err
+		}
+
+		appendable = appendable && replica.IsAppendable()
+		handler.Replicas = append(handler.Replicas, Replica{Policy: &replica, Handler: inner})
+	}
+
+	// 存在不支持追加写入的副本时，文件须一次上传完成
+	if !appendable {
+		policy.OptionsSerialized.ChunkSize = 0
+	}
+
+	return handler, nil
+}
+
+// List 列出第一个可用副本中的文件
+func (handler *Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	err := ErrNoHealthyReplica
+	for _, replica := range handler.Replicas {
+		var objects []response.Object
+		if objects, err = replica.Handler.List(ctx, path, recursive); err == nil {
+			return objects, nil
+		}
+		logrus.Debugf("Unable to list [%s] from replica policy [%s], %s", path, replica.Policy.Name, err)
+	}
+	return nil, err
+}
+
+// Get 从第一个可用的副本读取文件
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	err := ErrNoHealthyReplica
+	for _, replica := range handler.Replicas {
+		var file response.RSCloser
+		if file, err = replica.Handler.Get(ctx, path); err == nil {
+			return file, nil
+		}
+		logrus.Debugf("Unable to get [%s] from replica policy [%s], %s", path, replica.Policy.Name, err)
+	}
+	return nil, err
+}
+
+// Put 将文件写入所有副本，任一副本写入失败时撤销已写入的副本。
+// 内容只能读取一次，有多个副本时先缓冲至临时文件
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	isAppend := fileInfo.Mode&fsctx.Append == fsctx.Append
+	if isAppend {
+		for _, replica := range handler.Replicas {
+			if !replica.Policy.IsAppendable() && fileInfo.AppendStart > 0 {
+				file.Close()
+				return ErrAppendUnsupported
+			}
+		}
+	}
+
+	buffer, err := os.CreateTemp("", bufferTempPattern)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to create buffer file: %w", err)
+	}
+	defer func() {
+		buffer.Close()
+		os.Remove(buffer.Name())
+	}()
+
+	size, err := io.Copy(buffer, file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to buffer file content: %w", err)
+	}
+
+	written := make([]Replica, 0, len(handler.Replicas))
+	for _, replica := range handler.Replicas {
+		if _, err = buffer.Seek(0, io.SeekStart); err != nil {
+			break
+		}
+
+		err = replica.Handler.Put(ctx, &fsctx.FileStream{
+			Mode:            fileInfo.Mode,
+			LastModified:    fileInfo.LastModified,
+			Metadata:        fileInfo.Metadata,
+			File:            nopCloser{Reader: buffer},
+			Seeker:          buffer,
+			Size:            uint64(size),
+			VirtualPath:     fileInfo.VirtualPath,
+			Name:            fileInfo.FileName,
+			MIMEType:        fileInfo.MIMEType,
+			SavePath:        fileInfo.SavePath,
+			UploadSessionID: fileInfo.UploadSessionID,
+			AppendStart:     fileInfo.AppendStart,
+			Model:           fileInfo.Model,
+			Src:             fileInfo.Src,
+			SHA256:          fileInfo.SHA256,
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to write replica policy [%s]: %w", replica.Policy.Name, err)
+			break
+		}
+		written = append(written, replica)
+	}
+
+	if err != nil {
+		handler.rollback(ctx, written, fileInfo.SavePath, isAppend, fileInfo.AppendStart)
+	}
+	return err
+}
+
+// rollback 撤销写入失败前已写入的副本，追加写入时截断至追加前的大小
+func (handler *Driver) rollback(ctx context.Context, written []Replica, path string, isAppend bool, size uint64) {
+	for _, replica := range written {
+		var err error
+		if !isAppend || size == 0 {
+			_, err = replica.Handler.Delete(ctx, []string{path})
+		} else if inner, ok := replica.Handler.(truncater); ok {
+			err = inner.Truncate(ctx, path, size)
+		}
+
+		if err != nil {
+			logrus.Warningf("Unable to roll back replica policy [%s] of [%s], %s", replica.Policy.Name, path, err)
+		}
+	}
+}
+
+// Truncate 截断所有支持截断的副本中的文件
+func (handler *Driver) Truncate(ctx context.Context, src string, size uint64) error {
+	var retErr error
+	for _, replica := range handler.Replicas {
+		if inner, ok := replica.Handler.(truncater); ok {
+			if err := inner.Truncate(ctx, src, size); err != nil {
+				retErr = err
+			}
+		}
+	}
+	return retErr
+}
+
+// Delete 从所有副本中删除文件，任一副本删除失败的文件均视为删除失败
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	var retErr error
+	failed := make(map[string]bool)
+	for _, replica := range handler.Replicas {
+		res, err := replica.Handler.Delete(ctx, files)
+		if err != nil {
+			logrus.Warningf("Unable to delete files from replica policy [%s], %s", replica.Policy.Name, err)
+			retErr = err
+		}
+		for _, name := range res {
+			failed[name] = true
+		}
+	}
+
+	deleteFailed := make([]string, 0, len(failed))
+	for _, name := range files {
+		if failed[name] {
+			deleteFailed = append(deleteFailed, name)
+		}
+	}
+	return deleteFailed, retErr
+}
+
+// Thumb 从第一个可用的副本读取缩略图
+func (handler *Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	err := ErrNoHealthyReplica
+	for _, replica := range handler.Replicas {
+		var thumb *response.ContentResponse
+		if thumb, err = replica.Handler.Thumb(ctx, path); err == nil {
+			return thumb, nil
+		}
+	}
+	return nil, err
+}
+
+// Source 由主机中转下载，读取时再选择可用的副本
+func (handler *Driver) Source(ctx context.Context, path string, baseURL url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	return local.Driver{Policy: handler.Policy}.Source(ctx, path, baseURL, ttl, isDownload, speed)
+}
+
+// Token 文件须写入所有副本，统一由主机中转上传
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return nil
+}
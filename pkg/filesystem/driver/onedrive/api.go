@@ -0,0 +1,427 @@
+package onedrive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/conf"
+	"github.com/jylc/cloudserver/pkg/filesystem/chunk"
+	"github.com/jylc/cloudserver/pkg/filesystem/chunk/backoff"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/request"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SmallFileSize 单文件上传接口最大尺寸
+	SmallFileSize uint64 = 4 * 1024 * 1024
+	// ChunkSize 服务端中转分片上传分片大小
+	ChunkSize uint64 = 10 * 1024 * 1024
+	// ListRetry 列目录时出错重试的次数
+	ListRetry = 1
+	// listRetrySleep 列目录失败后的重试间隔
+	listRetrySleep = time.Duration(5) * time.Second
+	// batchSize 批量操作单次请求的最大数量
+	batchSize = 20
+	// chunkRetrySleep 分片上传失败后的重试间隔
+	chunkRetrySleep = time.Duration(5) * time.Second
+)
+
+// GetSourcePath 获取文件的绝对路径
+func (info *FileInfo) GetSourcePath() string {
+	res, err := url.PathUnescape(
+		strings.TrimPrefix(
+			path.Join(
+				strings.TrimPrefix(info.ParentReference.Path, "/drive/root:"),
+				info.Name,
+			),
+			"/",
+		),
+	)
+	if err != nil {
+		return ""
+	}
+	return res
+}
+
+// getRequestURL 获取 Graph API 的完整地址
+func (client *Client) getRequestURL(api string, opts ...Option) string {
+	options := newDefaultOption()
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	base, _ := url.Parse(client.Endpoints.EndpointURL)
+	if base == nil {
+		return ""
+	}
+
+	if options.useDriverResource {
+		base.Path = path.Join(base.Path, client.Endpoints.DriverResource, api)
+	} else {
+		base.Path = path.Join(base.Path, api)
+	}
+
+	return base.String()
+}
+
+// getItemURL 获取路径对应项目的 API 地址
+func (client *Client) getItemURL(dst string, api string) string {
+	dst = strings.Trim(dst, "/")
+	if dst == "" {
+		return client.getRequestURL(path.Join("root", api))
+	}
+
+	item := "root:/" + dst + ":"
+	if api != "" {
+		item += "/" + strings.TrimPrefix(api, "/")
+	}
+	return client.getRequestURL(item)
+}
+
+// ListChildren 列取目录下的直接子项目
+func (client *Client) ListChildren(ctx context.Context, dst string) ([]FileInfo, error) {
+	requestURL := client.getItemURL(dst, "children")
+	res := make([]FileInfo, 0)
+
+	for requestURL != "" {
+		// 请求失败时只重试当前页
+		body, err := client.requestWithStr(ctx, "GET", requestURL, "", 200)
+		for retried := 0; err != nil && retried < ListRetry; retried++ {
+			logrus.Debugf("Failed to list path %q: %s, retrying in %s...", dst, err, listRetrySleep)
+			time.Sleep(listRetrySleep)
+			body, err = client.requestWithStr(ctx, "GET", requestURL, "", 200)
+		}
+		if err != nil {
+			return res, err
+		}
+
+		var page ListResponse
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			return res, err
+		}
+
+		res = append(res, page.Value...)
+		requestURL = page.NextLink
+	}
+
+	return res, nil
+}
+
+// Meta 获取文件或目录的元信息
+func (client *Client) Meta(ctx context.Context, dst string) (*FileInfo, error) {
+	body, err := client.requestWithStr(ctx, "GET", client.getItemURL(dst, ""), "", 200)
+	if err != nil {
+		return nil, err
+	}
+
+	var info FileInfo
+	if err := json.Unmarshal([]byte(body), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// CreateUploadSession 创建分片上传会话
+func (client *Client) CreateUploadSession(ctx context.Context, dst string, opts ...Option) (string, error) {
+	options := newDefaultOption()
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"item": map[string]string{
+			"@microsoft.graph.conflictBehavior": options.conflictBehavior,
+		},
+	})
+
+	body, err := client.requestWithStr(ctx, "POST", client.getItemURL(dst, "createUploadSession"), string(reqBody), 200)
+	if err != nil {
+		return "", err
+	}
+
+	var session UploadSessionResponse
+	if err := json.Unmarshal([]byte(body), &session); err != nil {
+		return "", err
+	}
+	return session.UploadURL, nil
+}
+
+// GetUploadSessionStatus 查询上传会话状态
+func (client *Client) GetUploadSessionStatus(ctx context.Context, uploadURL string) (*UploadSessionResponse, error) {
+	body, err := client.requestWithStr(ctx, "GET", uploadURL, "", 200)
+	if err != nil {
+		return nil, err
+	}
+
+	var session UploadSessionResponse
+	if err := json.Unmarshal([]byte(body), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// UploadChunk 上传分片，上传地址已包含授权信息，无需携带凭证
+func (client *Client) UploadChunk(ctx context.Context, uploadURL string, content io.Reader, current *chunk.Group) (*UploadSessionResponse, error) {
+	res := client.Request.Request(
+		"PUT",
+		uploadURL,
+		content,
+		request.WithContext(ctx),
+		request.WithContentLength(current.Length()),
+		request.WithHeader(http.Header{
+			"Content-Range": {current.RangeHeader()},
+		}),
+		request.WithTimeout(time.Duration(0)),
+	)
+
+	body, err := client.checkResponse(res, http.StatusAccepted, http.StatusOK, http.StatusCreated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload chunk #%d: %w", current.Index(), err)
+	}
+
+	// 最后一个分片返回的是文件信息
+	if current.IsLast() {
+		return nil, nil
+	}
+
+	var session UploadSessionResponse
+	if err := json.Unmarshal([]byte(body), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Upload 上传文件，小文件直接上传，大文件使用上传会话分片上传
+func (client *Client) Upload(ctx context.Context, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+
+	conflictBehavior := "fail"
+	if fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite {
+		conflictBehavior = "replace"
+	}
+
+	if fileInfo.Size <= SmallFileSize {
+		_, err := client.SimpleUpload(ctx, fileInfo.SavePath, file, int64(fileInfo.Size), WithConflictBehavior(conflictBehavior))
+		return err
+	}
+
+	uploadURL, err := client.CreateUploadSession(ctx, fileInfo.SavePath, WithConflictBehavior(conflictBehavior))
+	if err != nil {
+		return err
+	}
+
+	chunkSize := client.Policy.OptionsSerialized.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = ChunkSize
+	}
+
+	chunks := chunk.NewGroup(file, chunkSize, &backoff.ConstantBackoff{
+		Max:   models.GetIntSetting("chunk_retries", 5),
+		Sleep: chunkRetrySleep,
+	}, models.IsTrueVal(models.GetSettingByName("use_temp_chunk_buffer")))
+
+	uploadFunc := func(current *chunk.Group, content io.Reader) error {
+		_, err := client.UploadChunk(ctx, uploadURL, content, current)
+		return err
+	}
+
+	for chunks.Next() {
+		if err := chunks.Process(uploadFunc); err != nil {
+			if err := client.DeleteUploadSession(context.Background(), uploadURL); err != nil {
+				logrus.Warningf("Failed to delete OneDrive upload session: %s", err)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SimpleUpload 上传小于 4MB 的文件
+func (client *Client) SimpleUpload(ctx context.Context, dst string, body io.Reader, size int64, opts ...Option) (*FileInfo, error) {
+	options := newDefaultOption()
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	requestURL := client.getItemURL(dst, "content") +
+		"?@microsoft.graph.conflictBehavior=" + options.conflictBehavior
+	res, err := client.request(ctx, "PUT", requestURL, body,
+		request.WithContentLength(size),
+		request.WithTimeout(time.Duration(0)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := client.checkResponse(res, http.StatusOK, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	var info FileInfo
+	if err := json.Unmarshal([]byte(respBody), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// DeleteUploadSession 删除上传会话
+func (client *Client) DeleteUploadSession(ctx context.Context, uploadURL string) error {
+	_, err := client.requestWithStr(ctx, "DELETE", uploadURL, "", http.StatusNoContent)
+	return err
+}
+
+// BatchDelete 批量删除文件，返回删除失败的文件
+func (client *Client) BatchDelete(ctx context.Context, dst []string) ([]string, error) {
+	failed := make([]string, 0)
+	var lastErr error
+
+	for start := 0; start < len(dst); start += batchSize {
+		end := start + batchSize
+		if end > len(dst) {
+			end = len(dst)
+		}
+
+		res, err := client.batchDelete(ctx, dst[start:end])
+		if err != nil {
+			lastErr = err
+		}
+		failed = append(failed, res...)
+	}
+
+	if len(failed) > 0 && lastErr == nil {
+		lastErr = ErrDeleteFile
+	}
+	return failed, lastErr
+}
+
+func (client *Client) batchDelete(ctx context.Context, dst []string) ([]string, error) {
+	base, _ := url.Parse(client.Endpoints.EndpointURL)
+	if base == nil {
+		return dst, ErrAuthEndpoint
+	}
+
+	requests := make([]BatchRequest, len(dst))
+	for i, v := range dst {
+		requests[i] = BatchRequest{
+			ID:     strconv.Itoa(i),
+			Method: "DELETE",
+			URL:    "/" + path.Join(client.Endpoints.DriverResource, "root:/"+strings.Trim(v, "/")),
+		}
+	}
+
+	reqBody, _ := json.Marshal(BatchRequests{Requests: requests})
+	body, err := client.requestWithStr(ctx, "POST", client.getRequestURL("$batch", WithDriverResource(false)), string(reqBody), 200)
+	if err != nil {
+		return dst, err
+	}
+
+	var res BatchResponses
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		return dst, err
+	}
+
+	failed := make([]string, 0)
+	for _, v := range res.Responses {
+		// 文件不存在也视为删除成功
+		if v.Status < 400 || v.Status == http.StatusNotFound {
+			continue
+		}
+		if index, err := strconv.Atoi(v.ID); err == nil && index < len(dst) {
+			failed = append(failed, dst[index])
+		}
+	}
+
+	return failed, nil
+}
+
+// GetThumbURL 获取给定尺寸的缩略图地址
+func (client *Client) GetThumbURL(ctx context.Context, dst string, w, h uint) (string, error) {
+	cropOption := fmt.Sprintf("c%dx%d", w, h)
+	// 世纪互联版本不支持自定义尺寸
+	if client.Endpoints.isInChina {
+		cropOption = "large"
+	}
+
+	body, err := client.requestWithStr(ctx, "GET", client.getItemURL(dst, "thumbnails/0/"+cropOption), "", 200)
+	if err != nil {
+		return "", err
+	}
+
+	var thumb ThumbResponse
+	if err := json.Unmarshal([]byte(body), &thumb); err != nil {
+		return "", err
+	}
+
+	if thumb.URL == "" {
+		return "", ErrThumbNotExist
+	}
+	return thumb.URL, nil
+}
+
+// request 携带访问凭证发送请求
+func (client *Client) request(ctx context.Context, method string, target string, body io.Reader, opts ...request.Option) (*request.Response, error) {
+	if err := client.UpdateCredential(ctx, conf.Sc.Role == "slave"); err != nil {
+		return nil, err
+	}
+
+	opts = append([]request.Option{
+		request.WithHeader(http.Header{
+			"Authorization": {"Bearer " + client.Credential.AccessToken},
+			"Content-Type":  {"application/json"},
+		}),
+		request.WithContext(ctx),
+	}, opts...)
+
+	res := client.Request.Request(method, target, body, opts...)
+	return res, res.Err
+}
+
+// requestWithStr 发送请求并校验状态码，返回响应正文
+func (client *Client) requestWithStr(ctx context.Context, method string, target string, body string, status int) (string, error) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	res, err := client.request(ctx, method, target, reader, request.WithContentLength(int64(len(body))))
+	if err != nil {
+		return "", err
+	}
+
+	return client.checkResponse(res, status)
+}
+
+// checkResponse 读取响应正文，状态码不符合预期时解析错误信息
+func (client *Client) checkResponse(res *request.Response, status ...int) (string, error) {
+	if res.Err != nil {
+		return "", res.Err
+	}
+
+	body, err := res.GetResponse()
+	if err != nil {
+		return "", err
+	}
+
+	for _, s := range status {
+		if res.Response.StatusCode == s {
+			return body, nil
+		}
+	}
+
+	var errResp RespError
+	if err := json.Unmarshal([]byte(body), &errResp); err == nil && errResp.APIError.Message != "" {
+		return "", errResp
+	}
+	return "", fmt.Errorf("unexpected HTTP status %d from OneDrive", res.Response.StatusCode)
+}
@@ -16,6 +16,10 @@ var (
 	ErrDeleteFile = errors.New("无法删除文件")
 	// ErrClientCanceled 客户端取消操作
 	ErrClientCanceled = errors.New("客户端取消操作")
+	// ErrFileNotExist 文件不存在或无法获取下载地址
+	ErrFileNotExist = errors.New("文件不存在")
+	// ErrThumbNotExist 缩略图不存在
+	ErrThumbNotExist = errors.New("缩略图不存在")
 )
 
 type Client struct {
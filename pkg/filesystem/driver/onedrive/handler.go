@@ -0,0 +1,202 @@
+package onedrive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/cache"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/request"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Driver OneDrive 适配器
+type Driver struct {
+	Policy     *models.Policy
+	Client     *Client
+	HTTPClient request.Client
+}
+
+// NewDriver 根据存储策略创建 OneDrive 适配器
+func NewDriver(policy *models.Policy) (*Driver, error) {
+	if policy.OptionsSerialized.ChunkSize == 0 {
+		policy.OptionsSerialized.ChunkSize = 50 << 20
+	}
+
+	client, err := NewClient(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Driver{
+		Policy:     policy,
+		Client:     client,
+		HTTPClient: request.NewClient(),
+	}, nil
+}
+
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.TrimPrefix(base, "/")
+	res := make([]response.Object, 0)
+
+	queue := []string{base}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		children, err := handler.Client.ListChildren(ctx, current)
+		if err != nil {
+			return res, err
+		}
+
+		for _, child := range children {
+			source := path.Join(current, child.Name)
+			rel := strings.TrimPrefix(strings.TrimPrefix(source, base), "/")
+			res = append(res, response.Object{
+				Name:         child.Name,
+				RelativePath: rel,
+				Source:       source,
+				Size:         child.Size,
+				IsDir:        child.Folder != nil,
+				LastModify:   child.LastModifiedDateTime,
+			})
+
+			if recursive && child.Folder != nil {
+				queue = append(queue, source)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	downloadURL, err := handler.Source(ctx, path, url.URL{}, 60, false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := handler.HTTPClient.Request(
+		"GET",
+		downloadURL,
+		nil,
+		request.WithContext(ctx),
+		request.WithTimeout(time.Duration(0)),
+	).CheckHTTPResponse(200).GetRSCloser()
+	if err != nil {
+		return nil, err
+	}
+
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(models.File); ok {
+		resp.SetContentLength(int64(file.Size))
+	}
+	return resp, nil
+}
+
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+
+	return handler.Client.Upload(ctx, file)
+}
+
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	return handler.Client.BatchDelete(ctx, files)
+}
+
+func (handler *Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	thumbSize, ok := ctx.Value(fsctx.ThumbSizeCtx).([2]uint)
+	if !ok {
+		return nil, errors.New("failed to get thumbnail size")
+	}
+
+	res, err := handler.Client.GetThumbURL(ctx, path, thumbSize[0], thumbSize[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.ContentResponse{
+		Redirect: true,
+		URL:      res,
+	}, nil
+}
+
+func (handler *Driver) Source(ctx context.Context, path string, baseURL url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	// 下载与预览的地址分别缓存
+	cacheKey := fmt.Sprintf("onedrive_source_%d_%t_%s", handler.Policy.ID, isDownload, path)
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(models.File); ok {
+		cacheKey = fmt.Sprintf("onedrive_source_file_%d_%d_%t", file.UpdatedAt.Unix(), file.ID, isDownload)
+	}
+
+	// 尝试从缓存中查找
+	if cachedURL, ok := cache.Get(cacheKey); ok {
+		return handler.replaceSourceHost(cachedURL.(string))
+	}
+
+	res, err := handler.Client.Meta(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	if res.DownloadURL == "" {
+		return "", ErrFileNotExist
+	}
+
+	cacheTTL := models.GetIntSetting("onedrive_source_timeout", 1800)
+	_ = cache.Set(cacheKey, res.DownloadURL, cacheTTL)
+	return handler.replaceSourceHost(res.DownloadURL)
+}
+
+// replaceSourceHost 使用反代地址替换下载地址的域名
+func (handler *Driver) replaceSourceHost(origin string) (string, error) {
+	if handler.Policy.OptionsSerialized.OdProxy == "" {
+		return origin, nil
+	}
+
+	source, err := url.Parse(origin)
+	if err != nil {
+		return "", err
+	}
+
+	proxy, err := url.Parse(handler.Policy.OptionsSerialized.OdProxy)
+	if err != nil {
+		return "", err
+	}
+
+	source.Scheme = proxy.Scheme
+	source.Host = proxy.Host
+	return source.String(), nil
+}
+
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	fileInfo := file.Info()
+
+	uploadURL, err := handler.Client.CreateUploadSession(ctx, fileInfo.SavePath, WithConflictBehavior("fail"))
+	if err != nil {
+		return nil, err
+	}
+
+	siteURL := models.GetSiteURL()
+	apiURL := siteURL.ResolveReference(&url.URL{Path: "/api/v3/callback/onedrive/finish/" + uploadSession.Key})
+	uploadSession.UploadURL = uploadURL
+	uploadSession.Callback = apiURL.String()
+
+	return &serializer.UploadCredential{
+		SessionID:  uploadSession.Key,
+		ChunkSize:  handler.Policy.OptionsSerialized.ChunkSize,
+		UploadURLs: []string{uploadURL},
+		Callback:   uploadSession.Callback,
+	}, nil
+}
+
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	if uploadSession.UploadURL == "" {
+		return nil
+	}
+	return handler.Client.DeleteUploadSession(ctx, uploadSession.UploadURL)
+}
@@ -0,0 +1,250 @@
+package onedrive
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/cache"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDriver 创建指向测试 Graph 服务的 OneDrive 适配器，已持有有效的访问令牌
+func newTestDriver(t *testing.T, handler http.HandlerFunc) (*Driver, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" && !strings.HasPrefix(r.URL.Path, "/upload/") && !strings.HasPrefix(r.URL.Path, "/download/") {
+			t.Errorf("request %s %s without access token", r.Method, r.URL)
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	driver, err := NewDriver(&models.Policy{
+		Type:      "onedrive",
+		Server:    server.URL + "/v1.0",
+		BaseURL:   server.URL,
+		AccessKey: "refresh",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver.Client.Credential = &Credential{
+		AccessToken: "token",
+		ExpiresIn:   time.Now().Add(time.Hour).Unix(),
+	}
+	return driver, server
+}
+
+func TestDriver_Token(t *testing.T) {
+	var server *httptest.Server
+	driver, server := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1.0/me/drive/root:/dir/file.txt:/createUploadSession" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"@microsoft.graph.conflictBehavior":"fail"`) {
+			t.Errorf("unexpected request body %s", body)
+		}
+		json.NewEncoder(w).Encode(UploadSessionResponse{UploadURL: server.URL + "/upload/session"})
+	})
+
+	uploadSession := &serializer.UploadSession{Key: "session"}
+	credential, err := driver.Token(context.Background(), 3600, uploadSession, &fsctx.FileStream{
+		Size:     1,
+		SavePath: "dir/file.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uploadSession.UploadURL != server.URL+"/upload/session" || len(credential.UploadURLs) != 1 || credential.UploadURLs[0] != uploadSession.UploadURL {
+		t.Errorf("upload url = %q, %v", uploadSession.UploadURL, credential.UploadURLs)
+	}
+	if !strings.HasSuffix(uploadSession.Callback, "/api/v3/callback/onedrive/finish/session") {
+		t.Errorf("callback = %q", uploadSession.Callback)
+	}
+	if credential.ChunkSize != 50<<20 {
+		t.Errorf("chunk size = %d", credential.ChunkSize)
+	}
+}
+
+func TestDriver_CancelToken(t *testing.T) {
+	deleted := false
+	driver, server := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/upload/session" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := driver.CancelToken(context.Background(), &serializer.UploadSession{}); err != nil || deleted {
+		t.Fatalf("session without upload url should be ignored, err = %v", err)
+	}
+
+	err := driver.CancelToken(context.Background(), &serializer.UploadSession{UploadURL: server.URL + "/upload/session"})
+	if err != nil || !deleted {
+		t.Errorf("delete upload session, err = %v, deleted = %v", err, deleted)
+	}
+}
+
+func TestClient_Meta(t *testing.T) {
+	driver, _ := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.0/me/drive/root:/file.txt:" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"itemNotFound","message":"item not found"}}`))
+			return
+		}
+		w.Write([]byte(`{"name":"file.txt","size":5}`))
+	})
+
+	info, err := driver.Client.Meta(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "file.txt" || info.Size != 5 {
+		t.Errorf("info = %+v", info)
+	}
+
+	_, err = driver.Client.Meta(context.Background(), "missing.txt")
+	if respErr, ok := err.(RespError); !ok || respErr.APIError.Code != "itemNotFound" {
+		t.Errorf("err = %v, want itemNotFound", err)
+	}
+}
+
+func TestDriver_Get(t *testing.T) {
+	// 下载地址会被缓存，清除其他用例留下的记录
+	cache.Deletes([]string{"0_false_get.txt"}, "onedrive_source_")
+
+	var server *httptest.Server
+	driver, server := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.0/me/drive/root:/get.txt:":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":                         "get.txt",
+				"@microsoft.graph.downloadUrl": server.URL + "/download/get.txt",
+			})
+		case "/download/get.txt":
+			w.Write([]byte("hello"))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	content, err := driver.Get(context.Background(), "get.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("content = %q", data)
+	}
+}
+
+func TestDriver_Put(t *testing.T) {
+	driver, _ := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/v1.0/me/drive/root:/file.txt:/content" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if r.URL.Query().Get("@microsoft.graph.conflictBehavior") != "replace" {
+			t.Errorf("unexpected conflict behavior in %s", r.URL)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "hello" {
+			t.Errorf("uploaded content = %q", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"name":"file.txt","size":5}`))
+	})
+
+	err := driver.Put(context.Background(), &fsctx.FileStream{
+		File:     ioutil.NopCloser(strings.NewReader("hello")),
+		Size:     5,
+		SavePath: "file.txt",
+		Mode:     fsctx.Overwrite,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	driver, _ := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1.0/$batch" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+
+		var req BatchRequests
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Requests) != 3 {
+			t.Errorf("unexpected batch request %+v, %v", req, err)
+			return
+		}
+		if req.Requests[1].Method != "DELETE" || req.Requests[1].URL != "/me/drive/root:/b.txt" {
+			t.Errorf("unexpected batch item %+v", req.Requests[1])
+		}
+
+		json.NewEncoder(w).Encode(BatchResponses{Responses: []BatchResponse{
+			{ID: "0", Status: http.StatusNoContent},
+			{ID: "1", Status: http.StatusForbidden},
+			{ID: "2", Status: http.StatusNotFound},
+		}})
+	})
+
+	failed, err := driver.Delete(context.Background(), []string{"a.txt", "b.txt", "c.txt"})
+	if err != ErrDeleteFile {
+		t.Errorf("err = %v, want %v", err, ErrDeleteFile)
+	}
+	if len(failed) != 1 || failed[0] != "b.txt" {
+		t.Errorf("failed = %v", failed)
+	}
+}
+
+func TestClient_ListChildrenRetry(t *testing.T) {
+	requests := map[string]int{}
+	var server *httptest.Server
+	driver, server := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		requests[page]++
+		switch {
+		case page == "":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"value":           []map[string]interface{}{{"name": "a.txt"}},
+				"@odata.nextLink": server.URL + "/v1.0/me/drive/root:/dir:/children?page=2",
+			})
+		case requests[page] == 1:
+			// 第二页首次请求失败
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"code":"serviceNotAvailable","message":"unavailable"}}`))
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"value": []map[string]interface{}{{"name": "b.txt"}},
+			})
+		}
+	})
+
+	res, err := driver.Client.ListChildren(context.Background(), "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Name != "a.txt" || res[1].Name != "b.txt" {
+		t.Errorf("children = %+v", res)
+	}
+	if requests[""] != 1 || requests["2"] != 2 {
+		t.Errorf("requests = %v, only the failed page should be retried", requests)
+	}
+}
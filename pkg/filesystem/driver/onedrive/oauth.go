@@ -30,9 +30,13 @@ func (client *Client) getOAuthEndpoint() *oauthEndpoint {
 		client.Endpoints.isInChina = true
 		token, _ = url.Parse("https://login.chinacloudapi.cn/common/oauth2/v2.0/token")
 		authorize, _ = url.Parse("https://login.chinacloudapi.cn/common/oauth2/v2.0/authorize")
-	default:
+	case "", "login.microsoftonline.com":
 		token, _ = url.Parse("https://login.microsoftonline.com/common/oauth2/v2.0/token")
 		authorize, _ = url.Parse("https://login.microsoftonline.com/common/oauth2/v2.0/authorize")
+	default:
+		// 自定义授权端点，例如用于测试的本地 Graph 服务
+		token = base.ResolveReference(&url.URL{Path: "/common/oauth2/v2.0/token"})
+		authorize = base.ResolveReference(&url.URL{Path: "/common/oauth2/v2.0/authorize"})
 	}
 
 	return &oauthEndpoint{token: *token, authorize: *authorize}
@@ -60,7 +64,7 @@ func (client *Client) UpdateCredential(ctx context.Context, isSlave bool) error
 		}
 	}
 
-	if client.Credential != nil || client.Credential.RefreshToken == "" {
+	if client.Credential == nil || client.Credential.RefreshToken == "" {
 		logrus.Warningf("upload policy [%s] voucher refresh failed, please re authorize onedrive account", client.Policy.Name)
 		return ErrInvalidRefreshToken
 	}
@@ -97,7 +101,7 @@ func (client *Client) ObtainToken(ctx context.Context, opts ...Option) (*Credent
 	}
 	body := url.Values{
 		"client_id":     {client.ClientID},
-		"redirect_url":  {client.Redirect},
+		"redirect_uri":  {client.Redirect},
 		"client_secret": {client.ClientSecret},
 	}
 
@@ -151,7 +155,7 @@ func (client *Client) ObtainToken(ctx context.Context, opts ...Option) (*Credent
 func (client *Client) OAuthURL(ctx context.Context, scope []string) string {
 	query := url.Values{
 		"client_id":     {client.ClientID},
-		"scope":         {strings.Join(scope, "")},
+		"scope":         {strings.Join(scope, " ")},
 		"response_type": {"code"},
 		"redirect_uri":  {client.Redirect},
 	}
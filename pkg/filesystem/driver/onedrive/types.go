@@ -1,6 +1,9 @@
 package onedrive
 
-import "net/url"
+import (
+	"net/url"
+	"time"
+)
 
 type Credential struct {
 	TokenType    string `json:"token_type"`
@@ -25,3 +28,93 @@ type OAuthError struct {
 func (err OAuthError) Error() string {
 	return err.ErrorDescription
 }
+
+// RespError 接口返回错误
+type RespError struct {
+	APIError APIError `json:"error"`
+}
+
+// APIError 接口返回的错误内容
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err RespError) Error() string {
+	return err.APIError.Message
+}
+
+// UploadSessionResponse 分片上传会话
+type UploadSessionResponse struct {
+	DataContext        string    `json:"@odata.context"`
+	ExpirationDateTime time.Time `json:"expirationDateTime"`
+	NextExpectedRanges []string  `json:"nextExpectedRanges"`
+	UploadURL          string    `json:"uploadUrl"`
+}
+
+// FileInfo 文件元信息
+type FileInfo struct {
+	Name                 string          `json:"name"`
+	Size                 uint64          `json:"size"`
+	Image                imageInfo       `json:"image"`
+	ParentReference      parentReference `json:"parentReference"`
+	DownloadURL          string          `json:"@microsoft.graph.downloadUrl"`
+	File                 *file           `json:"file"`
+	Folder               *folder         `json:"folder"`
+	LastModifiedDateTime time.Time       `json:"lastModifiedDateTime"`
+}
+
+type file struct {
+	MimeType string `json:"mimeType"`
+}
+
+type folder struct {
+	ChildCount int `json:"childCount"`
+}
+
+type imageInfo struct {
+	Height int `json:"height"`
+	Width  int `json:"width"`
+}
+
+type parentReference struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// ListResponse 列取子项目响应
+type ListResponse struct {
+	Value    []FileInfo `json:"value"`
+	NextLink string     `json:"@odata.nextLink"`
+}
+
+// ThumbResponse 获取缩略图的响应
+type ThumbResponse struct {
+	URL string `json:"url"`
+}
+
+// BatchRequests 批量操作请求
+type BatchRequests struct {
+	Requests []BatchRequest `json:"requests"`
+}
+
+// BatchRequest 批量操作单个请求
+type BatchRequest struct {
+	ID     string            `json:"id"`
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Body   interface{}       `json:"body,omitempty"`
+	Header map[string]string `json:"headers,omitempty"`
+}
+
+// BatchResponses 批量操作响应
+type BatchResponses struct {
+	Responses []BatchResponse `json:"responses"`
+}
+
+// BatchResponse 批量操作单个响应
+type BatchResponse struct {
+	ID     string `json:"id"`
+	Status int    `json:"status"`
+}
@@ -87,7 +87,7 @@ func (c *remoteClient) Upload(ctx context.Context, file fsctx.FileHeader) error
 	}, models.IsTrueVal(models.GetSettingByName("use_temp_chunk_buffer")))
 
 	uploadFunc := func(current *chunk.Group, content io.Reader) error {
-		return c.uploadChunk(ctx, session.Key, current.Index(), content, overwrite, current.Length())
+		return c.uploadChunk(ctx, session.Key, current.Index(), content, overwrite, current.Length(), current.Digest())
 	}
 
 	for chunks.Next() {
@@ -160,7 +160,17 @@ func (c *remoteClient) GetUploadURL(ttl int64, sessionID string) (string, string
 	return req.URL.String(), req.Header["Authorization"][0], nil
 }
 
-func (c *remoteClient) uploadChunk(ctx context.Context, sessionID string, index int, chunk io.Reader, overwrite bool, size int64) error {
+func (c *remoteClient) uploadChunk(ctx context.Context, sessionID string, index int, chunk io.Reader, overwrite bool, size int64, digest string) error {
+	header := map[string][]string{
+		OverwriteHeader: {
+			fmt.Sprintf("%t", overwrite),
+		},
+	}
+	// 从机据此校验分片内容
+	if digest != "" {
+		header["Digest"] = []string{digest}
+	}
+
 	resp, err := c.httpClient.Request(
 		"POST",
 		fmt.Sprintf("upload/%s?chunk=%d", sessionID, index),
@@ -168,11 +178,7 @@ func (c *remoteClient) uploadChunk(ctx context.Context, sessionID string, index
 		request.WithContext(ctx),
 		request.WithTimeout(time.Duration(0)),
 		request.WithContentLength(size),
-		request.WithHeader(map[string][]string{
-			OverwriteHeader: {
-				fmt.Sprintf("%t", overwrite),
-			},
-		})).CheckHTTPResponse(200).DecodeResponse()
+		request.WithHeader(header)).CheckHTTPResponse(200).DecodeResponse()
 	if err != nil {
 		return err
 	}
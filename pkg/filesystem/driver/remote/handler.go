@@ -2,13 +2,21 @@ package remote
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/auth"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/filesystem/response"
 	"github.com/jylc/cloudserver/pkg/request"
 	"github.com/jylc/cloudserver/pkg/serializer"
+	"net/http"
 	"net/url"
+	"path"
+	"strings"
+	"time"
 )
 
 type Driver struct {
@@ -18,44 +26,201 @@ type Driver struct {
 	uploadClient Client
 }
 
-func (d Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
-	//TODO implement me
-	panic("implement me")
+// getAPIUrl 获取从机 API 的完整地址
+func (d *Driver) getAPIUrl(scope string, routes ...string) string {
+	serverURL, err := url.Parse(d.Policy.Server)
+	if err != nil {
+		return ""
+	}
+
+	controller, _ := url.Parse(path.Join(append([]string{basePath, scope}, routes...)...))
+	return serverURL.ResolveReference(controller).String()
 }
 
-func (d Driver) Delete(ctx context.Context, files []string) ([]string, error) {
-	//TODO implement me
-	panic("implement me")
+func (d *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+
+	return d.uploadClient.Upload(ctx, file)
 }
 
-func (d Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
-	//TODO implement me
-	panic("implement me")
+func (d *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	bodyStruct := serializer.RemoteDeleteRequest{
+		Files: files,
+	}
+	reqBody, err := json.Marshal(bodyStruct)
+	if err != nil {
+		return files, err
+	}
+
+	signTTL := models.GetIntSetting("slave_api_timeout", 60)
+	resp, err := d.Client.Request(
+		"POST",
+		d.getAPIUrl("delete"),
+		strings.NewReader(string(reqBody)),
+		request.WithContext(ctx),
+		request.WithCredential(d.AuthInstance, int64(signTTL)),
+		request.WithMasterMeta(),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return files, err
+	}
+
+	// 从机部分删除失败时，返回失败的文件列表
+	if resp.Code != 0 {
+		var failures serializer.RemoteDeleteRequest
+		dataStr, _ := resp.Data.(string)
+		if err := json.Unmarshal([]byte(dataStr), &failures); err != nil {
+			return files, fmt.Errorf("failed to decode slave delete result: %w", err)
+		}
+		return failures.Files, errors.New(resp.Msg)
+	}
+
+	return []string{}, nil
 }
 
-func (d Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
-	//TODO implement me
-	panic("implement me")
+func (d *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	downloadURL, err := d.Source(ctx, path, url.URL{}, int64(models.GetIntSetting("preview_timeout", 60)), false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.Client.Request(
+		"GET",
+		downloadURL,
+		nil,
+		request.WithContext(ctx),
+		request.WithTimeout(time.Duration(0)),
+		request.WithMasterMeta(),
+	).CheckHTTPResponse(200).GetRSCloser()
+	if err != nil {
+		return nil, err
+	}
+
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(models.File); ok {
+		resp.SetContentLength(int64(file.Size))
+	}
+	return resp, nil
 }
 
-func (d Driver) Source(ctx context.Context, path string, url url.URL, ttl int64, isDownload bool, speed int) (string, error) {
-	//TODO implement me
-	panic("implement me")
+func (d *Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	sourcePath := base64.RawURLEncoding.EncodeToString([]byte(path))
+	thumbURL := d.getAPIUrl("thumb", sourcePath)
+	ttl := models.GetIntSetting("preview_timeout", 60)
+	signedThumbURL, err := auth.SignURI(d.AuthInstance, thumbURL, int64(ttl))
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.ContentResponse{
+		Redirect: true,
+		URL:      signedThumbURL.String(),
+	}, nil
 }
 
-func (d Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
-	//TODO implement me
-	panic("implement me")
+func (d *Driver) Source(ctx context.Context, path string, baseURL url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	fileName := "file"
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(models.File); ok {
+		fileName = file.Name
+	}
+
+	serverURL, err := url.Parse(d.Policy.Server)
+	if err != nil {
+		return "", errors.New("failed to parse remote server url")
+	}
+
+	// 是否启用了 CDN
+	if d.Policy.BaseURL != "" {
+		cdnURL, err := url.Parse(d.Policy.BaseURL)
+		if err != nil {
+			return "", err
+		}
+		serverURL = cdnURL
+	}
+
+	controller := "/api/v3/slave/download"
+	if !isDownload {
+		controller = "/api/v3/slave/source"
+	}
+
+	sourcePath := base64.RawURLEncoding.EncodeToString([]byte(path))
+	signedURI, err := auth.SignURI(
+		d.AuthInstance,
+		fmt.Sprintf("%s/%d/%s/%s", controller, speed, sourcePath, url.PathEscape(fileName)),
+		ttl,
+	)
+	if err != nil {
+		return "", serializer.NewError(serializer.CodeEncryptError, "failed to sign url", err)
+	}
+
+	return serverURL.ResolveReference(signedURI).String(), nil
+}
+
+func (d *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	siteURL := models.GetSiteURL()
+	apiBaseURI, _ := url.Parse(path.Join("/api/v3/callback/remote", uploadSession.Key, uploadSession.CallbackSecret))
+	apiURL := siteURL.ResolveReference(apiBaseURI)
+
+	// 在从机端创建上传会话
+	uploadSession.Callback = apiURL.String()
+	if err := d.uploadClient.CreateUploadSession(ctx, uploadSession, ttl, false); err != nil {
+		return nil, err
+	}
+
+	uploadURL, sign, err := d.uploadClient.GetUploadURL(ttl, uploadSession.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign upload url: %w", err)
+	}
+
+	return &serializer.UploadCredential{
+		SessionID:  uploadSession.Key,
+		ChunkSize:  d.Policy.OptionsSerialized.ChunkSize,
+		UploadURLs: []string{uploadURL},
+		Credential: sign,
+	}, nil
 }
 
-func (d Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
-	//TODO implement me
-	panic("implement me")
+func (d *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return d.uploadClient.DeleteUploadSession(ctx, uploadSession.Key)
 }
 
-func (d Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
-	//TODO implement me
-	panic("implement me")
+func (d *Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	var res []response.Object
+
+	reqBody := serializer.ListRequest{
+		Path:      path,
+		Recursive: recursive,
+	}
+	reqBodyEncoded, err := json.Marshal(reqBody)
+	if err != nil {
+		return res, err
+	}
+
+	signTTL := models.GetIntSetting("slave_api_timeout", 60)
+	resp, err := d.Client.Request(
+		"POST",
+		d.getAPIUrl("list"),
+		strings.NewReader(string(reqBodyEncoded)),
+		request.WithContext(ctx),
+		request.WithCredential(d.AuthInstance, int64(signTTL)),
+		request.WithMasterMeta(),
+		request.WithHeader(http.Header{"Content-Type": {"application/json"}}),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return res, err
+	}
+
+	if resp.Code != 0 {
+		return res, errors.New(resp.Msg)
+	}
+
+	if resStr, ok := resp.Data.(string); ok {
+		err = json.Unmarshal([]byte(resStr), &res)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
 }
 
 func NewDriver(policy *models.Policy) (*Driver, error) {
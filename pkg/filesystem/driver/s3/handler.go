@@ -0,0 +1,378 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/auth"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/request"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultChunkSize 未设置分片大小时使用的默认值，S3 要求除最后一片外不小于 5MB
+	defaultChunkSize = 25 << 20
+	// maxPresignTTL S3 预签名 URL 的最长有效期
+	maxPresignTTL = 7 * 24 * 3600
+	// deleteBatchSize 单次批量删除的最大对象数
+	deleteBatchSize = 1000
+)
+
+var (
+	ErrThumbNotSupported = errors.New("thumbnails are not supported by s3 policy")
+	ErrPlaceholderExist  = errors.New("placeholder file already exist")
+)
+
+type Driver struct {
+	Policy *models.Policy
+	Client request.Client
+
+	sess *session.Session
+	svc  *s3.S3
+}
+
+// MetaData 对象元信息
+type MetaData struct {
+	Size uint64
+	Etag string
+}
+
+func NewDriver(policy *models.Policy) (*Driver, error) {
+	if policy.OptionsSerialized.ChunkSize == 0 {
+		policy.OptionsSerialized.ChunkSize = defaultChunkSize
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials(policy.AccessKey, policy.SecretKey, ""),
+		Endpoint:         aws.String(policy.Server),
+		Region:           aws.String(policy.OptionsSerialized.Region),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Driver{
+		Policy: policy,
+		Client: request.NewClient(),
+		sess:   sess,
+		svc:    s3.New(sess),
+	}, nil
+}
+
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.TrimPrefix(base, "/")
+	if base != "" && !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(handler.Policy.BucketName),
+		Prefix: aws.String(base),
+	}
+	if !recursive {
+		input.Delimiter = aws.String("/")
+	}
+
+	res := make([]response.Object, 0)
+	err := handler.svc.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, prefix := range page.CommonPrefixes {
+			rel := strings.TrimSuffix(strings.TrimPrefix(*prefix.Prefix, base), "/")
+			res = append(res, response.Object{
+				Name:         path.Base(rel),
+				RelativePath: rel,
+				Source:       *prefix.Prefix,
+				IsDir:        true,
+				LastModify:   time.Now(),
+			})
+		}
+
+		for _, object := range page.Contents {
+			// 部分客户端会创建以 / 结尾的空对象表示目录
+			if strings.HasSuffix(*object.Key, "/") {
+				continue
+			}
+
+			rel := strings.TrimPrefix(*object.Key, base)
+			res = append(res, response.Object{
+				Name:         path.Base(*object.Key),
+				RelativePath: rel,
+				Source:       *object.Key,
+				Size:         uint64(aws.Int64Value(object.Size)),
+				IsDir:        false,
+				LastModify:   aws.TimeValue(object.LastModified),
+			})
+		}
+		return true
+	})
+
+	return res, err
+}
+
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	downloadURL, err := handler.Source(
+		ctx,
+		path,
+		url.URL{},
+		int64(models.GetIntSetting("preview_timeout", 60)),
+		false,
+		0,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := handler.Client.Request(
+		"GET",
+		downloadURL,
+		nil,
+		request.WithContext(ctx),
+		request.WithTimeout(time.Duration(0)),
+		request.WithHeader(http.Header{
+			"Cache-Control": {"no-cache", "no-store", "must-revalidate"},
+		}),
+	).CheckHTTPResponse(200).GetRSCloser()
+	if err != nil {
+		return nil, err
+	}
+
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(models.File); ok {
+		resp.SetContentLength(int64(file.Size))
+	}
+	return resp, nil
+}
+
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+
+	if fileInfo.Mode&fsctx.Overwrite != fsctx.Overwrite {
+		if _, err := handler.Meta(ctx, fileInfo.SavePath); err == nil {
+			return ErrPlaceholderExist
+		}
+	}
+
+	uploader := s3manager.NewUploader(handler.sess, func(u *s3manager.Uploader) {
+		u.PartSize = int64(handler.Policy.OptionsSerialized.ChunkSize)
+	})
+
+	_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(handler.Policy.BucketName),
+		Key:    aws.String(fileInfo.SavePath),
+		Body:   io.LimitReader(file, int64(fileInfo.Size)),
+	})
+	return err
+}
+
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var lastErr error
+
+	for start := 0; start < len(files); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(files) {
+			end = len(files)
+		}
+
+		keys := make([]*s3.ObjectIdentifier, 0, end-start)
+		for _, file := range files[start:end] {
+			keys = append(keys, &s3.ObjectIdentifier{Key: aws.String(file)})
+		}
+
+		res, err := handler.svc.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(handler.Policy.BucketName),
+			Delete: &s3.Delete{
+				Objects: keys,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			lastErr = err
+			failed = append(failed, files[start:end]...)
+			continue
+		}
+
+		for _, deleteErr := range res.Errors {
+			failed = append(failed, aws.StringValue(deleteErr.Key))
+			lastErr = errors.New(aws.StringValue(deleteErr.Message))
+		}
+	}
+
+	return failed, lastErr
+}
+
+func (handler *Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	return nil, ErrThumbNotSupported
+}
+
+func (handler *Driver) Source(ctx context.Context, path string, baseURL url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	fileName := ""
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(models.File); ok {
+		fileName = file.Name
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(handler.Policy.BucketName),
+		Key:    aws.String(path),
+	}
+	if isDownload {
+		input.ResponseContentDisposition = aws.String("attachment; filename=\"" + url.PathEscape(fileName) + "\"")
+	}
+
+	if ttl <= 0 || ttl > maxPresignTTL {
+		ttl = maxPresignTTL
+	}
+
+	req, _ := handler.svc.GetObjectRequest(input)
+	signedURL, err := req.Presign(time.Duration(ttl) * time.Second)
+	if err != nil {
+		return "", err
+	}
+
+	finalURL, err := url.Parse(signedURL)
+	if err != nil {
+		return "", err
+	}
+
+	// 公有空间无需签名参数
+	if !handler.Policy.IsPrivate && !isDownload {
+		finalURL.RawQuery = ""
+	}
+
+	if handler.Policy.BaseURL != "" {
+		cdnURL, err := url.Parse(handler.Policy.BaseURL)
+		if err != nil {
+			return "", err
+		}
+		finalURL.Host = cdnURL.Host
+		finalURL.Scheme = cdnURL.Scheme
+	}
+
+	return finalURL.String(), nil
+}
+
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	fileInfo := file.Info()
+
+	if _, err := handler.Meta(ctx, fileInfo.SavePath); err == nil {
+		return nil, ErrPlaceholderExist
+	}
+
+	// 回调地址签名，防止仅凭会话 ID 伪造回调
+	siteURL := models.GetSiteURL()
+	apiURL, err := auth.SignURI(auth.General, "/api/v3/callback/s3/"+uploadSession.Key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	uploadSession.Callback = siteURL.ResolveReference(apiURL).String()
+
+	res, err := handler.svc.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(handler.Policy.BucketName),
+		Key:         aws.String(fileInfo.SavePath),
+		ContentType: aws.String("application/octet-stream"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	uploadSession.UploadID = *res.UploadId
+
+	chunkSize := handler.Policy.OptionsSerialized.ChunkSize
+	chunkNum := uint64(1)
+	if fileInfo.Size > 0 {
+		chunkNum = (fileInfo.Size + chunkSize - 1) / chunkSize
+	}
+
+	signTTL := time.Duration(ttl) * time.Second
+	if ttl <= 0 || ttl > maxPresignTTL {
+		signTTL = time.Duration(maxPresignTTL) * time.Second
+	}
+
+	urls := make([]string, chunkNum)
+	for i := uint64(0); i < chunkNum; i++ {
+		partLength := chunkSize
+		if i == chunkNum-1 {
+			partLength = fileInfo.Size - chunkSize*i
+		}
+
+		partReq, _ := handler.svc.UploadPartRequest(&s3.UploadPartInput{
+			Bucket:        aws.String(handler.Policy.BucketName),
+			Key:           aws.String(fileInfo.SavePath),
+			PartNumber:    aws.Int64(int64(i + 1)),
+			UploadId:      res.UploadId,
+			ContentLength: aws.Int64(int64(partLength)),
+		})
+
+		signedURL, err := partReq.Presign(signTTL)
+		if err != nil {
+			return nil, err
+		}
+		urls[i] = signedURL
+	}
+
+	completeReq, _ := handler.svc.CompleteMultipartUploadRequest(&s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(handler.Policy.BucketName),
+		Key:      aws.String(fileInfo.SavePath),
+		UploadId: res.UploadId,
+	})
+	completeURL, err := completeReq.Presign(signTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{
+		SessionID:   uploadSession.Key,
+		ChunkSize:   chunkSize,
+		UploadID:    *res.UploadId,
+		UploadURLs:  urls,
+		CompleteURL: completeURL,
+		Callback:    uploadSession.Callback,
+	}, nil
+}
+
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	if uploadSession.UploadID == "" {
+		return nil
+	}
+
+	_, err := handler.svc.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(handler.Policy.BucketName),
+		Key:      aws.String(uploadSession.SavePath),
+		UploadId: aws.String(uploadSession.UploadID),
+	})
+	return err
+}
+
+// Meta 获取对象的大小和 ETag，对象不存在时返回错误
+func (handler *Driver) Meta(ctx context.Context, path string) (*MetaData, error) {
+	res, err := handler.svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(handler.Policy.BucketName),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+			return nil, fmt.Errorf("object %q not found: %w", path, err)
+		}
+		return nil, err
+	}
+
+	return &MetaData{
+		Size: uint64(aws.Int64Value(res.ContentLength)),
+		Etag: aws.StringValue(res.ETag),
+	}, nil
+}
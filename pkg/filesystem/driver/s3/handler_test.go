@@ -0,0 +1,187 @@
+package s3
+
+import (
+	"context"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/auth"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTestDriver 创建指向测试服务器的 S3 适配器，存储桶为 bucket
+func newTestDriver(t *testing.T, handler http.HandlerFunc) *Driver {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	driver, err := NewDriver(&models.Policy{
+		Type:       "s3",
+		Server:     server.URL,
+		BucketName: "bucket",
+		AccessKey:  "ak",
+		SecretKey:  "sk",
+		OptionsSerialized: models.PolicyOption{
+			Region:    "us-east-1",
+			ChunkSize: 5 << 20,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return driver
+}
+
+func TestDriver_Token(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPost && r.URL.Path == "/bucket/dir/file.txt" && hasQuery(r, "uploads"):
+			w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>dir/file.txt</Key><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	auth.General = auth.HMACAuth{SecretKey: []byte("secret")}
+	uploadSession := &serializer.UploadSession{Key: "session"}
+	credential, err := driver.Token(context.Background(), 3600, uploadSession, &fsctx.FileStream{
+		Size:     11 << 20,
+		SavePath: "dir/file.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uploadSession.UploadID != "upload-id" || credential.UploadID != "upload-id" {
+		t.Errorf("upload id = %q, %q", uploadSession.UploadID, credential.UploadID)
+	}
+	callbackURL, err := url.Parse(uploadSession.Callback)
+	if err != nil || callbackURL.Path != "/api/v3/callback/s3/session" || auth.CheckURI(auth.General, callbackURL) != nil {
+		t.Errorf("callback = %q", uploadSession.Callback)
+	}
+	if len(credential.UploadURLs) != 3 {
+		t.Fatalf("got %d upload urls, want 3", len(credential.UploadURLs))
+	}
+	if !strings.Contains(credential.UploadURLs[2], "partNumber=3") || !strings.Contains(credential.CompleteURL, "uploadId=upload-id") {
+		t.Errorf("unexpected presigned urls %v, %s", credential.UploadURLs, credential.CompleteURL)
+	}
+}
+
+func TestDriver_TokenPlaceholderExist(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Header().Set("Content-Length", "1")
+	})
+
+	_, err := driver.Token(context.Background(), 3600, &serializer.UploadSession{Key: "session"}, &fsctx.FileStream{
+		Size:     1,
+		SavePath: "file.txt",
+	})
+	if err != ErrPlaceholderExist {
+		t.Errorf("err = %v, want %v", err, ErrPlaceholderExist)
+	}
+}
+
+func TestDriver_CancelToken(t *testing.T) {
+	aborted := false
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete && r.URL.Path == "/bucket/file.txt" && r.URL.Query().Get("uploadId") == "upload-id" {
+			aborted = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	})
+
+	if err := driver.CancelToken(context.Background(), &serializer.UploadSession{}); err != nil || aborted {
+		t.Fatalf("session without upload id should be ignored, err = %v", err)
+	}
+
+	err := driver.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "file.txt", UploadID: "upload-id"})
+	if err != nil || !aborted {
+		t.Errorf("abort multipart upload, err = %v, aborted = %v", err, aborted)
+	}
+}
+
+func TestDriver_Meta(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bucket/file.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "5")
+		w.Header().Set("ETag", `"etag"`)
+	})
+
+	meta, err := driver.Meta(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Size != 5 || meta.Etag != `"etag"` {
+		t.Errorf("meta = %+v", meta)
+	}
+
+	if _, err := driver.Meta(context.Background(), "missing.txt"); err == nil {
+		t.Error("expected error for missing object")
+	}
+}
+
+func TestDriver_Get(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/bucket/file.txt" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte("hello"))
+	})
+
+	content, err := driver.Get(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("content = %q", data)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	driver := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !hasQuery(r, "delete") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(string(body), "<Key>a.txt</Key>") || !strings.Contains(string(body), "<Key>b.txt</Key>") {
+			t.Errorf("unexpected delete body %s", body)
+		}
+		w.Write([]byte(`<DeleteResult><Error><Key>b.txt</Key><Code>AccessDenied</Code><Message>denied</Message></Error></DeleteResult>`))
+	})
+
+	failed, err := driver.Delete(context.Background(), []string{"a.txt", "b.txt"})
+	if err == nil {
+		t.Error("expected error for failed object")
+	}
+	if len(failed) != 1 || failed[0] != "b.txt" {
+		t.Errorf("failed = %v", failed)
+	}
+}
+
+// hasQuery 请求是否带有指定的查询参数
+func hasQuery(r *http.Request, key string) bool {
+	_, ok := r.URL.Query()[key]
+	return ok
+}
@@ -0,0 +1,342 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/auth"
+	"github.com/jylc/cloudserver/pkg/cache"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/jylc/cloudserver/pkg/utils"
+	"github.com/pkg/sftp"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// dialTimeout 建立 SSH 连接的超时时间
+	dialTimeout = 10 * time.Second
+)
+
+var (
+	ErrPlaceholderExist = errors.New("placeholder file already exist")
+	ErrFragmentMismatch = errors.New("the file fragment that has not been uploaded is inconsistent with the expected size")
+	ErrNoHostKey        = errors.New("host key of the SFTP server is not configured")
+)
+
+// Driver SFTP 适配器，Server 为 host:port，AccessKey 为用户名，
+// SecretKey 为密码或 PEM 格式私钥，BucketName 为可选的远程根目录
+type Driver struct {
+	Policy *models.Policy
+	config *ssh.ClientConfig
+}
+
+// NewDriver 根据存储策略创建 SFTP 适配器
+func NewDriver(policy *models.Policy) (*Driver, error) {
+	var authMethod ssh.AuthMethod
+	if signer, err := ssh.ParsePrivateKey([]byte(policy.SecretKey)); err == nil {
+		authMethod = ssh.PublicKeys(signer)
+	} else {
+		authMethod = ssh.Password(policy.SecretKey)
+	}
+
+	// 未配置服务器公钥时拒绝连接，避免中间人攻击
+	if policy.OptionsSerialized.HostKey == "" {
+		return nil, ErrNoHostKey
+	}
+
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(policy.OptionsSerialized.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse host key: %w", err)
+	}
+
+	return &Driver{
+		Policy: policy,
+		config: &ssh.ClientConfig{
+			User:            policy.AccessKey,
+			Auth:            []ssh.AuthMethod{authMethod},
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+			Timeout:         dialTimeout,
+		},
+	}, nil
+}
+
+// session 一次 SFTP 会话，关闭时同时断开 SSH 连接
+type session struct {
+	*sftp.Client
+	conn *ssh.Client
+}
+
+func (s *session) Close() error {
+	s.Client.Close()
+	return s.conn.Close()
+}
+
+// connect 建立新的 SFTP 会话
+func (handler *Driver) connect(ctx context.Context) (*session, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", handler.Policy.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, handler.Policy.Server, handler.config)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	conn := ssh.NewClient(sshConn, chans, reqs)
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &session{Client: client, conn: conn}, nil
+}
+
+// remotePath 将存储路径转换为服务器上的路径
+func (handler *Driver) remotePath(p string) string {
+	if handler.Policy.BucketName == "" {
+		return strings.TrimPrefix(path.Clean("/"+p), "/")
+	}
+	return path.Join(handler.Policy.BucketName, p)
+}
+
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	client, err := handler.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	base = strings.Trim(base, "/")
+	root := handler.remotePath(base)
+	if root == "" {
+		root = "."
+	}
+
+	res := make([]response.Object, 0)
+	walker := client.Walk(root)
+	for walker.Step() {
+		if walker.Path() == root {
+			continue
+		}
+
+		if err := walker.Err(); err != nil {
+			logrus.Warnf("cannot ergodic catalogue %s, %s\n", walker.Path(), err)
+			walker.SkipDir()
+			continue
+		}
+
+		info := walker.Stat()
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), root), "/")
+		res = append(res, response.Object{
+			Name:         info.Name(),
+			RelativePath: rel,
+			Source:       path.Join(base, rel),
+			Size:         uint64(info.Size()),
+			IsDir:        info.IsDir(),
+			LastModify:   info.ModTime(),
+		})
+
+		if !recursive && info.IsDir() {
+			walker.SkipDir()
+		}
+	}
+
+	return res, nil
+}
+
+// remoteFile 远程文件，关闭时同时结束会话
+type remoteFile struct {
+	*sftp.File
+	session *session
+}
+
+func (f *remoteFile) Close() error {
+	f.File.Close()
+	return f.session.Close()
+}
+
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	client, err := handler.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := client.Open(handler.remotePath(path))
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &remoteFile{File: file, session: client}, nil
+}
+
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+	dst := handler.remotePath(fileInfo.SavePath)
+
+	client, err := handler.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if fileInfo.Mode&fsctx.Overwrite != fsctx.Overwrite {
+		if _, err := client.Stat(dst); err == nil {
+			logrus.Warningf("A file with the same physical name already exists or is unavailable: %s\n", dst)
+			return ErrPlaceholderExist
+		}
+	}
+
+	if err := client.MkdirAll(path.Dir(dst)); err != nil {
+		logrus.Warningf("cannot create path, %s\n", err)
+		return err
+	}
+
+	openMode := os.O_CREATE | os.O_WRONLY
+	if fileInfo.Mode&fsctx.Append == fsctx.Append {
+		openMode |= os.O_APPEND
+	} else {
+		openMode |= os.O_TRUNC
+	}
+
+	out, err := client.OpenFile(dst, openMode)
+	if err != nil {
+		logrus.Warningf("cannot open or create file, %s\n", err)
+		return err
+	}
+	defer out.Close()
+
+	if fileInfo.Mode&fsctx.Append == fsctx.Append {
+		stat, err := out.Stat()
+		if err != nil {
+			return err
+		}
+
+		if uint64(stat.Size()) < fileInfo.AppendStart {
+			return ErrFragmentMismatch
+		} else if uint64(stat.Size()) > fileInfo.AppendStart {
+			// 覆盖已上传的分片
+			if err := out.Truncate(int64(fileInfo.AppendStart)); err != nil {
+				return fmt.Errorf("an error occurred while overwriting the fragment: %w", err)
+			}
+		}
+
+		if _, err := out.Seek(int64(fileInfo.AppendStart), io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	_, err = out.ReadFrom(file)
+	return err
+}
+
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	client, err := handler.connect(ctx)
+	if err != nil {
+		return files, err
+	}
+	defer client.Close()
+
+	deletedFailed := make([]string, 0, len(files))
+	var retErr error
+
+	thumbSuffix := models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb")
+	for _, value := range files {
+		err := client.Remove(handler.remotePath(value))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			logrus.Warningf("cannot delete file, %s\n", err)
+			retErr = err
+			deletedFailed = append(deletedFailed, value)
+		}
+		_ = client.Remove(handler.remotePath(value + thumbSuffix))
+	}
+
+	return deletedFailed, retErr
+}
+
+func (handler *Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	file, err := handler.Get(ctx, path+models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb"))
+	if err != nil {
+		return nil, err
+	}
+	return &response.ContentResponse{
+		Redirect: false,
+		Content:  file,
+	}, nil
+}
+
+// Source 文件无法被外部直接访问，由主机中转下载
+func (handler *Driver) Source(ctx context.Context, path string, baseURL url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	file, ok := ctx.Value(fsctx.FileModelCtx).(models.File)
+	if !ok {
+		return "", errors.New("unable to get file record context")
+	}
+
+	if handler.Policy.BaseURL != "" {
+		cdnURL, err := url.Parse(handler.Policy.BaseURL)
+		if err != nil {
+			return "", err
+		}
+		baseURL = *cdnURL
+	}
+
+	var (
+		signedURI *url.URL
+		err       error
+	)
+
+	if isDownload {
+		downloadSessionID := utils.RandStringRunes(16)
+		err = cache.Set("download_"+downloadSessionID, file, int(ttl))
+		if err != nil {
+			return "", serializer.NewError(serializer.CodeCacheOperation, "failed to create download session", err)
+		}
+
+		signedURI, err = auth.SignURI(auth.General, fmt.Sprintf("/api/v3/file/download/%s", downloadSessionID), ttl)
+	} else {
+		signedURI, err = auth.SignURI(auth.General, fmt.Sprintf("/api/v3/file/get/%d/%s", file.ID, file.Name), ttl)
+	}
+
+	if err != nil {
+		return "", serializer.NewError(serializer.CodeEncryptError, "unable to sign URL", err)
+	}
+
+	return baseURL.ResolveReference(signedURI).String(), nil
+}
+
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	client, err := handler.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	if _, err := client.Stat(handler.remotePath(uploadSession.SavePath)); err == nil {
+		return nil, ErrPlaceholderExist
+	}
+
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return nil
+}
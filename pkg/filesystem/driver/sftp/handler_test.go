@@ -0,0 +1,301 @@
+package sftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestSigner 生成测试用的 SSH 密钥
+func newTestSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// newTestServer 启动仅接受密码登录的进程内 SFTP 服务，返回监听地址和服务器公钥
+func newTestServer(t *testing.T) (string, ssh.PublicKey) {
+	hostKey := newTestSigner(t)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "user" && string(password) == "pass" {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+
+	return listener.Addr().String(), hostKey.PublicKey()
+}
+
+// serveConn 处理一个 SSH 连接上的 sftp 子系统请求
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func(in <-chan *ssh.Request) {
+			for req := range in {
+				// payload 为 uint32 长度前缀加子系统名
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+			}
+		}(requests)
+
+		go func() {
+			defer channel.Close()
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+		}()
+	}
+}
+
+// newTestDriver 创建连接至测试服务的适配器，远程根目录为临时目录
+func newTestDriver(t *testing.T) (*Driver, string) {
+	addr, hostKey := newTestServer(t)
+	root := t.TempDir()
+
+	driver, err := NewDriver(&models.Policy{
+		Type:       "sftp",
+		Server:     addr,
+		AccessKey:  "user",
+		SecretKey:  "pass",
+		BucketName: root,
+		OptionsSerialized: models.PolicyOption{
+			HostKey: string(ssh.MarshalAuthorizedKey(hostKey)),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return driver, root
+}
+
+func TestNewDriver_HostKey(t *testing.T) {
+	if _, err := NewDriver(&models.Policy{Type: "sftp"}); err != ErrNoHostKey {
+		t.Errorf("err = %v, want %v", err, ErrNoHostKey)
+	}
+
+	if _, err := NewDriver(&models.Policy{Type: "sftp", OptionsSerialized: models.PolicyOption{HostKey: "invalid"}}); err == nil {
+		t.Error("expected error for invalid host key")
+	}
+}
+
+func TestDriver_HostKeyMismatch(t *testing.T) {
+	addr, _ := newTestServer(t)
+	driver, err := NewDriver(&models.Policy{
+		Type:      "sftp",
+		Server:    addr,
+		AccessKey: "user",
+		SecretKey: "pass",
+		OptionsSerialized: models.PolicyOption{
+			HostKey: string(ssh.MarshalAuthorizedKey(newTestSigner(t).PublicKey())),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := driver.Get(context.Background(), "file.txt"); err == nil {
+		t.Error("expected error for mismatched host key")
+	}
+}
+
+func TestDriver_PutAndGet(t *testing.T) {
+	driver, root := newTestDriver(t)
+	ctx := context.Background()
+
+	err := driver.Put(ctx, &fsctx.FileStream{
+		File:     ioutil.NopCloser(strings.NewReader("hello")),
+		Size:     5,
+		SavePath: "dir/file.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "dir", "file.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("remote content = %q, %v", data, err)
+	}
+
+	err = driver.Put(ctx, &fsctx.FileStream{
+		File:     ioutil.NopCloser(strings.NewReader("again")),
+		Size:     5,
+		SavePath: "dir/file.txt",
+	})
+	if err != ErrPlaceholderExist {
+		t.Errorf("err = %v, want %v", err, ErrPlaceholderExist)
+	}
+
+	content, err := driver.Get(ctx, "dir/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+
+	data, err = ioutil.ReadAll(content)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("content = %q, %v", data, err)
+	}
+}
+
+func TestDriver_PutAppend(t *testing.T) {
+	driver, root := newTestDriver(t)
+	ctx := context.Background()
+	if err := ioutil.WriteFile(filepath.Join(root, "file.txt"), []byte("hello, garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已上传的内容多于起始位置时覆盖多出的部分
+	err := driver.Put(ctx, &fsctx.FileStream{
+		File:        ioutil.NopCloser(strings.NewReader(" world")),
+		Size:        6,
+		SavePath:    "file.txt",
+		Mode:        fsctx.Append | fsctx.Overwrite,
+		AppendStart: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(filepath.Join(root, "file.txt"))
+	if string(data) != "hello world" {
+		t.Errorf("remote content = %q", data)
+	}
+
+	err = driver.Put(ctx, &fsctx.FileStream{
+		File:        ioutil.NopCloser(strings.NewReader("!")),
+		Size:        1,
+		SavePath:    "file.txt",
+		Mode:        fsctx.Append | fsctx.Overwrite,
+		AppendStart: 20,
+	})
+	if err != ErrFragmentMismatch {
+		t.Errorf("err = %v, want %v", err, ErrFragmentMismatch)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	driver, root := newTestDriver(t)
+	for _, name := range []string{"a.txt", "a.txt._thumb", "b.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed, err := driver.Delete(context.Background(), []string{"a.txt", "b.txt", "missing.txt"})
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("failed = %v, err = %v", failed, err)
+	}
+
+	entries, _ := ioutil.ReadDir(root)
+	if len(entries) != 0 {
+		t.Errorf("%d files left in remote root", len(entries))
+	}
+}
+
+func TestDriver_Token(t *testing.T) {
+	driver, root := newTestDriver(t)
+	ctx := context.Background()
+
+	credential, err := driver.Token(ctx, 3600, &serializer.UploadSession{Key: "session", SavePath: "file.txt"}, &fsctx.FileStream{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credential.SessionID != "session" {
+		t.Errorf("session id = %q", credential.SessionID)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, "file.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := driver.Token(ctx, 3600, &serializer.UploadSession{Key: "session", SavePath: "file.txt"}, &fsctx.FileStream{}); err != ErrPlaceholderExist {
+		t.Errorf("err = %v, want %v", err, ErrPlaceholderExist)
+	}
+}
+
+func TestDriver_List(t *testing.T) {
+	driver, root := newTestDriver(t)
+	if err := os.MkdirAll(filepath.Join(root, "dir", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objects, err := driver.List(context.Background(), "/", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 {
+		t.Errorf("got %d objects, want 2", len(objects))
+	}
+
+	objects, err = driver.List(context.Background(), "dir", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		sources[object.Source] = object.IsDir
+	}
+	if len(sources) != 3 || !sources["dir/sub"] || sources["dir/sub/c.txt"] {
+		t.Errorf("unexpected objects %+v", objects)
+	}
+}
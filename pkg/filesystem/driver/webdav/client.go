@@ -0,0 +1,280 @@
+package webdav
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/request"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// propfindBody PROPFIND 请求需要获取的属性
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+	<d:prop>
+		<d:displayname/>
+		<d:resourcetype/>
+		<d:getcontentlength/>
+		<d:getlastmodified/>
+	</d:prop>
+</d:propfind>`
+
+// multiStatus PROPFIND 响应
+type multiStatus struct {
+	Responses []propResponse `xml:"response"`
+}
+
+type propResponse struct {
+	Href     string     `xml:"href"`
+	Propstat []propstat `xml:"propstat"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"prop"`
+	Status string `xml:"status"`
+}
+
+type prop struct {
+	DisplayName   string       `xml:"displayname"`
+	ResourceType  resourceType `xml:"resourcetype"`
+	ContentLength string       `xml:"getcontentlength"`
+	LastModified  string       `xml:"getlastmodified"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"collection"`
+}
+
+// Entry 远程服务器上的文件或目录
+type Entry struct {
+	// Path 相对于服务器根地址的路径
+	Path       string
+	Size       uint64
+	IsDir      bool
+	LastModify time.Time
+}
+
+// Client WebDAV 客户端
+type Client struct {
+	Base    *url.URL
+	Request request.Client
+
+	authorization string
+}
+
+// NewClient 根据存储策略创建 WebDAV 客户端
+func NewClient(policy *models.Policy) (*Client, error) {
+	base, err := url.Parse(policy.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	client := &Client{
+		Base:    base,
+		Request: request.NewClient(),
+	}
+
+	if policy.AccessKey != "" || policy.SecretKey != "" {
+		client.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(policy.AccessKey+":"+policy.SecretKey))
+	}
+
+	return client, nil
+}
+
+// URL 获取路径对应的完整地址
+func (client *Client) URL(p string) string {
+	target := &url.URL{Path: strings.TrimPrefix(path.Clean("/"+p), "/")}
+	if strings.HasSuffix(p, "/") && target.Path != "" {
+		target.Path += "/"
+	}
+	return client.Base.ResolveReference(target).String()
+}
+
+func (client *Client) do(ctx context.Context, method, p string, body io.Reader, header http.Header, opts ...request.Option) *request.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	if client.authorization != "" {
+		header.Set("Authorization", client.authorization)
+	}
+
+	opts = append([]request.Option{
+		request.WithContext(ctx),
+		request.WithHeader(header),
+	}, opts...)
+	return client.Request.Request(method, client.URL(p), body, opts...)
+}
+
+// checkStatus 读取并丢弃响应正文，校验状态码
+func checkStatus(resp *request.Response, status ...int) error {
+	if resp.Err != nil {
+		return resp.Err
+	}
+
+	_, _ = io.Copy(ioutil.Discard, resp.Response.Body)
+	resp.Response.Body.Close()
+
+	for _, s := range status {
+		if resp.Response.StatusCode == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("webdav server returns abnormal HTTP status %d", resp.Response.StatusCode)
+}
+
+// Stat 获取单个文件或目录的信息
+func (client *Client) Stat(ctx context.Context, p string) (*Entry, error) {
+	entries, err := client.propfind(ctx, p, "0")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrObjectNotExist
+	}
+	return &entries[0], nil
+}
+
+// ReadDir 列取目录下的直接子项目
+func (client *Client) ReadDir(ctx context.Context, p string) ([]Entry, error) {
+	entries, err := client.propfind(ctx, p, "1")
+	if err != nil {
+		return nil, err
+	}
+
+	self := strings.Trim(path.Clean("/"+p), "/")
+	res := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if strings.Trim(entry.Path, "/") == self {
+			continue
+		}
+		res = append(res, entry)
+	}
+	return res, nil
+}
+
+func (client *Client) propfind(ctx context.Context, p string, depth string) ([]Entry, error) {
+	resp := client.do(ctx, "PROPFIND", p, strings.NewReader(propfindBody), http.Header{
+		"Depth":        {depth},
+		"Content-Type": {"application/xml; charset=utf-8"},
+	})
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	defer resp.Response.Body.Close()
+
+	switch resp.Response.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusNotFound:
+		return nil, ErrObjectNotExist
+	default:
+		return nil, fmt.Errorf("webdav server returns abnormal HTTP status %d", resp.Response.StatusCode)
+	}
+
+	var ms multiStatus
+	if err := xml.NewDecoder(resp.Response.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("failed to decode PROPFIND response: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		entry, ok := client.parseResponse(r)
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// parseResponse 将 PROPFIND 响应项转换为相对于根地址的条目
+func (client *Client) parseResponse(r propResponse) (Entry, bool) {
+	href, err := url.Parse(r.Href)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	var rel string
+	switch {
+	case strings.HasPrefix(href.Path, client.Base.Path):
+		rel = strings.TrimPrefix(href.Path, client.Base.Path)
+	case href.Path+"/" == client.Base.Path:
+		rel = ""
+	default:
+		return Entry{}, false
+	}
+
+	entry := Entry{Path: strings.TrimSuffix(rel, "/")}
+	for _, ps := range r.Propstat {
+		if !strings.Contains(ps.Status, " 200") {
+			continue
+		}
+
+		entry.IsDir = ps.Prop.ResourceType.Collection != nil
+		entry.Size, _ = strconv.ParseUint(ps.Prop.ContentLength, 10, 64)
+		entry.LastModify, _ = http.ParseTime(ps.Prop.LastModified)
+	}
+
+	return entry, true
+}
+
+// MkdirAll 逐级创建目录
+func (client *Client) MkdirAll(ctx context.Context, p string) error {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+
+	current := ""
+	for _, part := range strings.Split(p, "/") {
+		current = path.Join(current, part)
+		resp := client.do(ctx, "MKCOL", current+"/", nil, nil)
+		// 405 表示目录已存在
+		if err := checkStatus(resp, http.StatusCreated, http.StatusMethodNotAllowed, http.StatusOK); err != nil {
+			return fmt.Errorf("failed to create directory %q: %w", current, err)
+		}
+	}
+	return nil
+}
+
+// Put 上传文件内容
+func (client *Client) Put(ctx context.Context, p string, body io.Reader, size int64) error {
+	resp := client.do(ctx, "PUT", p, body, http.Header{
+		"Content-Type": {"application/octet-stream"},
+	}, request.WithContentLength(size), request.WithTimeout(time.Duration(0)))
+	return checkStatus(resp, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+}
+
+// Get 获取文件内容，返回的读取器支持通过 Range 请求跳转
+func (client *Client) Get(ctx context.Context, p string) (*request.RequestSeeker, error) {
+	return client.do(ctx, "GET", p, nil, nil, request.WithTimeout(time.Duration(0))).
+		CheckHTTPResponse(http.StatusOK).
+		GetRSCloser()
+}
+
+// Move 移动文件，目标存在时覆盖
+func (client *Client) Move(ctx context.Context, src, dst string) error {
+	resp := client.do(ctx, "MOVE", src, nil, http.Header{
+		"Destination": {client.URL(dst)},
+		"Overwrite":   {"T"},
+	})
+	return checkStatus(resp, http.StatusCreated, http.StatusNoContent)
+}
+
+// Delete 删除文件或目录，目标不存在时视为成功
+func (client *Client) Delete(ctx context.Context, p string) error {
+	resp := client.do(ctx, "DELETE", p, nil, nil)
+	return checkStatus(resp, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+}
@@ -0,0 +1,187 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/local"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var (
+	ErrObjectNotExist   = errors.New("object not exist")
+	ErrPlaceholderExist = errors.New("placeholder file already exist")
+	ErrFragmentMismatch = errors.New("the file fragment that has not been uploaded is inconsistent with the expected size")
+)
+
+// Driver WebDAV 适配器，Server 为远程 WebDAV 根地址，AccessKey/SecretKey 为 Basic 认证的用户名和密码
+type Driver struct {
+	Policy *models.Policy
+	Client *Client
+}
+
+// NewDriver 根据存储策略创建 WebDAV 适配器
+func NewDriver(policy *models.Policy) (*Driver, error) {
+	client, err := NewClient(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Driver{
+		Policy: policy,
+		Client: client,
+	}, nil
+}
+
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.Trim(base, "/")
+	res := make([]response.Object, 0)
+
+	queue := []string{base}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		entries, err := handler.Client.ReadDir(ctx, current)
+		if err != nil {
+			return res, err
+		}
+
+		for _, entry := range entries {
+			rel := strings.TrimPrefix(strings.TrimPrefix(entry.Path, base), "/")
+			res = append(res, response.Object{
+				Name:         path.Base(entry.Path),
+				RelativePath: rel,
+				Source:       entry.Path,
+				Size:         entry.Size,
+				IsDir:        entry.IsDir,
+				LastModify:   entry.LastModify,
+			})
+
+			if recursive && entry.IsDir {
+				queue = append(queue, entry.Path)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	rs, err := handler.Client.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(models.File); ok {
+		rs.SetContentLength(int64(file.Size))
+	}
+	return rs, nil
+}
+
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+
+	if fileInfo.Mode&fsctx.Overwrite != fsctx.Overwrite {
+		if _, err := handler.Client.Stat(ctx, fileInfo.SavePath); err == nil {
+			logrus.Warningf("A file with the same physical name already exists or is unavailable: %s\n", fileInfo.SavePath)
+			return ErrPlaceholderExist
+		}
+	}
+
+	if err := handler.Client.MkdirAll(ctx, path.Dir(fileInfo.SavePath)); err != nil {
+		return err
+	}
+
+	if fileInfo.Mode&fsctx.Append != fsctx.Append || fileInfo.AppendStart == 0 {
+		return handler.Client.Put(ctx, fileInfo.SavePath, file, int64(fileInfo.Size))
+	}
+
+	return handler.appendChunk(ctx, file)
+}
+
+// appendChunk WebDAV 不支持追加写入，将已上传部分与新分片合并后写入临时文件，再覆盖原文件
+func (handler *Driver) appendChunk(ctx context.Context, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+
+	existing, err := handler.Client.Stat(ctx, fileInfo.SavePath)
+	if err != nil {
+		return err
+	}
+
+	if existing.Size < fileInfo.AppendStart {
+		return ErrFragmentMismatch
+	}
+
+	origin, err := handler.Client.Get(ctx, fileInfo.SavePath)
+	if err != nil {
+		return err
+	}
+	defer origin.Close()
+
+	tempPath := fileInfo.SavePath + ".part"
+	content := io.MultiReader(io.LimitReader(origin, int64(fileInfo.AppendStart)), file)
+	if err := handler.Client.Put(ctx, tempPath, content, int64(fileInfo.AppendStart+fileInfo.Size)); err != nil {
+		_ = handler.Client.Delete(context.Background(), tempPath)
+		return fmt.Errorf("failed to append chunk: %w", err)
+	}
+
+	return handler.Client.Move(ctx, tempPath, fileInfo.SavePath)
+}
+
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	deletedFailed := make([]string, 0, len(files))
+	var retErr error
+
+	thumbSuffix := models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb")
+	for _, value := range files {
+		if err := handler.Client.Delete(ctx, value); err != nil {
+			logrus.Warningf("cannot delete file, %s\n", err)
+			retErr = err
+			deletedFailed = append(deletedFailed, value)
+		}
+		_ = handler.Client.Delete(ctx, value+thumbSuffix)
+	}
+
+	return deletedFailed, retErr
+}
+
+func (handler *Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	file, err := handler.Client.Get(ctx, path+models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb"))
+	if err != nil {
+		return nil, err
+	}
+	return &response.ContentResponse{
+		Redirect: false,
+		Content:  file,
+	}, nil
+}
+
+// Source 远程服务器需要认证，由主机中转下载
+func (handler *Driver) Source(ctx context.Context, path string, baseURL url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	return local.Driver{Policy: handler.Policy}.Source(ctx, path, baseURL, ttl, isDownload, speed)
+}
+
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	if _, err := handler.Client.Stat(ctx, uploadSession.SavePath); err == nil {
+		return nil, ErrPlaceholderExist
+	}
+
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return nil
+}
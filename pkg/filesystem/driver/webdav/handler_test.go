@@ -0,0 +1,237 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memServer 内存中的简易 WebDAV 服务，记录收到的请求
+type memServer struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+	log   []string
+	// failPut 对该路径的 PUT 请求返回 507
+	failPut string
+}
+
+func (s *memServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/dav"), "/")
+	s.log = append(s.log, r.Method+" "+name)
+
+	switch r.Method {
+	case "PROPFIND":
+		content, ok := s.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><d:multistatus xmlns:d="DAV:"><d:response><d:href>/dav/%s</d:href><d:propstat><d:prop><d:resourcetype/><d:getcontentlength>%d</d:getcontentlength></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`, name, len(content))
+	case "MKCOL":
+		if s.dirs[name] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		s.dirs[name] = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		content, _ := ioutil.ReadAll(r.Body)
+		if name == s.failPut {
+			w.WriteHeader(http.StatusInsufficientStorage)
+			return
+		}
+		s.files[name] = content
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		content, ok := s.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+	case "MOVE":
+		dst, err := url.Parse(r.Header.Get("Destination"))
+		content, ok := s.files[name]
+		if err != nil || !ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		delete(s.files, name)
+		s.files[strings.Trim(strings.TrimPrefix(dst.Path, "/dav"), "/")] = content
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := s.files[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.files, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// file 获取服务中保存的文件内容
+func (s *memServer) file(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.files[name]
+	return string(content), ok
+}
+
+// newTestDriver 创建连接至内存 WebDAV 服务的适配器，files 为服务中已有的文件
+func newTestDriver(t *testing.T, files map[string]string) (*Driver, *memServer) {
+	mem := &memServer{files: make(map[string][]byte), dirs: make(map[string]bool)}
+	for name, content := range files {
+		mem.files[name] = []byte(content)
+	}
+
+	server := httptest.NewServer(mem)
+	t.Cleanup(server.Close)
+
+	driver, err := NewDriver(&models.Policy{
+		Type:   "webdav",
+		Server: server.URL + "/dav",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return driver, mem
+}
+
+func TestDriver_PutAppend(t *testing.T) {
+	driver, mem := newTestDriver(t, map[string]string{"dir/file.txt": "hello, garbage"})
+
+	// 已上传的内容多于起始位置时覆盖多出的部分
+	err := driver.Put(context.Background(), &fsctx.FileStream{
+		File:        ioutil.NopCloser(strings.NewReader(" world")),
+		Size:        6,
+		SavePath:    "dir/file.txt",
+		Mode:        fsctx.Append | fsctx.Overwrite,
+		AppendStart: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := mem.file("dir/file.txt"); content != "hello world" {
+		t.Errorf("content = %q", content)
+	}
+	if _, ok := mem.file("dir/file.txt.part"); ok {
+		t.Error("temporary file is left on server")
+	}
+
+	log := strings.Join(mem.log, "\n")
+	if !strings.Contains(log, "PUT dir/file.txt.part\nMOVE dir/file.txt.part") {
+		t.Errorf("chunk should be written to temporary file and moved, requests:\n%s", log)
+	}
+}
+
+func TestDriver_PutAppendMismatch(t *testing.T) {
+	driver, mem := newTestDriver(t, map[string]string{"file.txt": "hello"})
+
+	err := driver.Put(context.Background(), &fsctx.FileStream{
+		File:        ioutil.NopCloser(strings.NewReader("!")),
+		Size:        1,
+		SavePath:    "file.txt",
+		Mode:        fsctx.Append | fsctx.Overwrite,
+		AppendStart: 10,
+	})
+	if err != ErrFragmentMismatch {
+		t.Errorf("err = %v, want %v", err, ErrFragmentMismatch)
+	}
+	if content, _ := mem.file("file.txt"); content != "hello" {
+		t.Errorf("content = %q", content)
+	}
+}
+
+func TestDriver_PutAppendFailed(t *testing.T) {
+	driver, mem := newTestDriver(t, map[string]string{"file.txt": "hello"})
+	mem.failPut = "file.txt.part"
+
+	err := driver.Put(context.Background(), &fsctx.FileStream{
+		File:        ioutil.NopCloser(strings.NewReader(" world")),
+		Size:        6,
+		SavePath:    "file.txt",
+		Mode:        fsctx.Append | fsctx.Overwrite,
+		AppendStart: 5,
+	})
+	if err == nil {
+		t.Fatal("expected error when temporary file cannot be written")
+	}
+
+	if content, _ := mem.file("file.txt"); content != "hello" {
+		t.Errorf("origin file should be kept, content = %q", content)
+	}
+	if mem.log[len(mem.log)-1] != "DELETE file.txt.part" {
+		t.Errorf("temporary file should be cleaned up, requests: %v", mem.log)
+	}
+}
+
+func TestDriver_Put(t *testing.T) {
+	driver, mem := newTestDriver(t, nil)
+	ctx := context.Background()
+
+	err := driver.Put(ctx, &fsctx.FileStream{
+		File:     ioutil.NopCloser(strings.NewReader("hello")),
+		Size:     5,
+		SavePath: "a/b/file.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := mem.file("a/b/file.txt"); content != "hello" {
+		t.Errorf("content = %q", content)
+	}
+	if !mem.dirs["a"] || !mem.dirs["a/b"] {
+		t.Errorf("parent directories are not created, dirs = %v", mem.dirs)
+	}
+
+	err = driver.Put(ctx, &fsctx.FileStream{
+		File:     ioutil.NopCloser(strings.NewReader("again")),
+		Size:     5,
+		SavePath: "a/b/file.txt",
+	})
+	if err != ErrPlaceholderExist {
+		t.Errorf("err = %v, want %v", err, ErrPlaceholderExist)
+	}
+}
+
+func TestDriver_GetAndDelete(t *testing.T) {
+	driver, mem := newTestDriver(t, map[string]string{"file.txt": "hello", "file.txt._thumb": "thumb"})
+	ctx := context.Background()
+
+	content, err := driver.Get(ctx, "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(content)
+	content.Close()
+	if err != nil || string(data) != "hello" {
+		t.Errorf("content = %q, %v", data, err)
+	}
+
+	failed, err := driver.Delete(ctx, []string{"file.txt", "missing.txt"})
+	if err != nil || len(failed) != 0 {
+		t.Errorf("failed = %v, err = %v", failed, err)
+	}
+	if _, ok := mem.file("file.txt._thumb"); ok {
+		t.Error("thumbnail should be deleted with file")
+	}
+}
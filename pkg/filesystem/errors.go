@@ -13,6 +13,9 @@ var (
 	ErrIllegalObjectName        = errors.New("目标名称非法")
 	ErrClientCanceled           = errors.New("客户端取消操作")
 	ErrRootProtected            = errors.New("无法对根目录进行操作")
+	ErrFileSizeMismatch         = errors.New("文件大小与记录不一致")
+	ErrContentMismatch          = errors.New("uploaded content does not match the declared SHA-256")
+	ErrBlobNotExist             = errors.New("the file to be linked no longer exists, please upload again")
 	ErrInsertFileRecord         = serializer.NewError(serializer.CodeDBError, "无法插入文件记录", nil)
 	ErrFileExisted              = serializer.NewError(serializer.CodeObjectExist, "同名文件或目录已存在", nil)
 	ErrFileUploadSessionExisted = serializer.NewError(serializer.CodeObjectExist, "当前目录下已经有同名文件正在上传中，请尝试清空上传会话", nil)
@@ -22,4 +25,7 @@ var (
 	ErrIO                       = serializer.NewError(serializer.CodeIOFailed, "无法读取文件数据", nil)
 	ErrDBListObjects            = serializer.NewError(serializer.CodeDBError, "无法列取对象记录", nil)
 	ErrDBDeleteObjects          = serializer.NewError(serializer.CodeDBError, "无法删除对象记录", nil)
+	ErrDBTrashObjects           = serializer.NewError(serializer.CodeDBError, "无法将对象移入回收站", nil)
+	ErrTagNotExist              = serializer.NewError(serializer.CodeNotFound, "标签不存在", nil)
+	ErrNestedSavedSearch        = serializer.NewError(serializer.CodeParamErr, "保存的搜索中不能引用其他保存的搜索", nil)
 )
@@ -8,9 +8,12 @@ import (
 	"github.com/jylc/cloudserver/pkg/cache"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/search"
 	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/jylc/cloudserver/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"io"
+	"strings"
 )
 
 type lrs struct {
@@ -37,6 +40,8 @@ func (fs *FileSystem) GetContent(ctx context.Context, id uint) (response.RSClose
 	if err != nil {
 		return nil, ErrIO.WithError(ErrIO)
 	}
+
+	fs.FileTarget[0].Touch()
 	return rs, nil
 }
 
@@ -113,6 +118,17 @@ func (fs *FileSystem) deleteGroupedFile(ctx context.Context, files map[uint][]*m
 		}
 		failedFile, _ := fs.Handler.Delete(ctx, sourceNamesAll)
 		failed[policyID] = failedFile
+
+		// 已删除的物理文件不能再用于去重
+		deleted := make([]string, 0, len(sourceNamesAll))
+		for _, source := range sourceNamesAll {
+			if !utils.ContainsString(failedFile, source) {
+				deleted = append(deleted, source)
+			}
+		}
+		if err := models.DeleteBlobsBySource(policyID, deleted); err != nil {
+			logrus.Warningf("Unable to delete blob records of policy [%d], %s", policyID, err)
+		}
 	}
 	return failed
 }
@@ -133,6 +149,16 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *models.Folder, file f
 		PolicyID:           fs.Policy.ID,
 		MetadataSerialized: uploadInfo.Metadata,
 		UploadSessionID:    uploadInfo.UploadSessionID,
+		SHA256:             uploadInfo.SHA256,
+	}
+
+	// 已存在内容相同的物理文件时直接引用，刚写入的副本在记录创建后删除。
+	// 仅在内容实际写入、哈希由服务端计算时进行，客户端指定的哈希不能用于引用其他用户的文件
+	duplicate := ""
+	if blob, ok := fs.FindDuplicate(newFile.SHA256, newFile.Size); ok && newFile.UploadSessionID == nil &&
+		uploadInfo.Mode&fsctx.Nop != fsctx.Nop && blob.SourceName != newFile.SourceName {
+		duplicate = newFile.SourceName
+		newFile.SourceName = blob.SourceName
 	}
 
 	if fs.Policy.IsThumbExist(uploadInfo.FileName) {
@@ -147,6 +173,12 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *models.Folder, file f
 		return nil, ErrFileExisted.WithError(err)
 	}
 
+	if duplicate != "" {
+		if _, err := fs.Handler.Delete(ctx, []string{duplicate}); err != nil {
+			logrus.Warningf("Unable to delete duplicate file [%s], %s", duplicate, err)
+		}
+	}
+
 	fs.User.Storage += newFile.Size
 	return &newFile, err
 }
@@ -188,6 +220,8 @@ func (fs *FileSystem) Preview(ctx context.Context, id uint, isText bool) (*respo
 	if err != nil {
 		return nil, err
 	}
+
+	fs.FileTarget[0].Touch()
 	return &response.ContentResponse{
 		Redirect: true,
 		URL:      previewURL,
@@ -214,6 +248,7 @@ func (fs *FileSystem) GetDownloadURL(ctx context.Context, id uint, timeout strin
 		return "", err
 	}
 
+	fileTarget.Touch()
 	return source, nil
 }
 
@@ -239,10 +274,110 @@ func (fs *FileSystem) GetSource(ctx context.Context, fileID uint) (string, error
 }
 
 func (fs *FileSystem) Search(ctx context.Context, keywords ...interface{}) ([]serializer.Object, error) {
+	parents, err := fs.searchParents(fs.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	files, _ := models.GetFilesByKeywords(fs.User.ID, parents, keywords...)
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, nil, nil), nil
+}
+
+// SearchMetadata 根据元数据字段搜索文件，value 为空时匹配包含该字段的文件
+func (fs *FileSystem) SearchMetadata(ctx context.Context, key, value string) ([]serializer.Object, error) {
+	parents, err := fs.searchParents(fs.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := models.GetFilesByMetadata(fs.User.ID, parents, key, value)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, nil, nil), nil
+}
+
+// SearchTagged 搜索带有指定手动标签的目录和文件
+func (fs *FileSystem) SearchTagged(ctx context.Context, tagID uint) ([]serializer.Object, error) {
+	parents, err := fs.searchParents(fs.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	folders, files, err := models.GetTaggedObjects(tagID, fs.User.ID, parents)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, folders, nil), nil
+}
+
+// QueryFiles 按结构化搜索语句分页搜索文件，pageSize 为 0 时不分页
+func (fs *FileSystem) QueryFiles(ctx context.Context, query *search.Query, page, pageSize int, order string) ([]serializer.Object, int, error) {
+	root := fs.Root
+	if query.In != "" {
+		exist, folder := fs.IsPathExist(query.In)
+		if !exist {
+			return nil, 0, ErrPathNotExist
+		}
+		root = folder
+	}
+
+	parents, err := fs.searchParents(root)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	filter := query.Filter
+	filter.Parents = parents
+	for _, name := range query.Tags {
+		// 同名时优先匹配手动标签
+		if tag, err := models.GetTagByName(name, models.ManualTagType, fs.User.ID); err == nil {
+			filter.TagIDs = append(filter.TagIDs, tag.ID)
+			continue
+		}
+
+		tag, err := models.GetTagByName(name, models.FileTagType, fs.User.ID)
+		if err != nil {
+			return nil, 0, ErrTagNotExist.WithError(err)
+		}
+		if tag.Query {
+			return nil, 0, ErrNestedSavedSearch
+		}
+		filter.PatternGroups = append(filter.PatternGroups, TagPatterns(tag))
+	}
+
+	files, total, err := models.GetFilesByFilter(fs.User.ID, &filter, page, pageSize, order)
+	if err != nil {
+		return nil, 0, ErrDBListObjects.WithError(err)
+	}
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, nil, nil), total, nil
+}
+
+// TagPatterns 获取文件分类标签的文件名 LIKE 模式，每行一个
+func TagPatterns(tag *models.Tag) []string {
+	patterns := make([]string, 0)
+	for _, line := range strings.Split(tag.Expression, "\n") {
+		if line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns
+}
+
+// searchParents 获取 root 及其下所有目录的 ID，root 为空时返回空
+func (fs *FileSystem) searchParents(root *models.Folder) ([]uint, error) {
 	parents := make([]uint, 0)
 
-	if fs.Root != nil {
-		allFolders, err := models.GetRecursiveChildFolder([]uint{fs.Root.ID}, fs.User.ID, true)
+	if root != nil {
+		allFolders, err := models.GetRecursiveChildFolder([]uint{root.ID}, fs.User.ID, true)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list all folders: %w", err)
 		}
@@ -252,8 +387,5 @@ func (fs *FileSystem) Search(ctx context.Context, keywords ...interface{}) ([]se
 		}
 	}
 
-	files, _ := models.GetFilesByKeywords(fs.User.ID, parents, keywords...)
-	fs.SetTargetFile(&files)
-
-	return fs.listObjects(ctx, "/", files, nil, nil), nil
+	return parents, nil
 }
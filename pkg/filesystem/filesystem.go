@@ -6,10 +6,18 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/cluster"
+	"github.com/jylc/cloudserver/pkg/conf"
 	"github.com/jylc/cloudserver/pkg/filesystem/driver"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/cos"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/encrypt"
 	"github.com/jylc/cloudserver/pkg/filesystem/driver/local"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/mirror"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/onedrive"
 	"github.com/jylc/cloudserver/pkg/filesystem/driver/remote"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/s3"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/sftp"
 	"github.com/jylc/cloudserver/pkg/filesystem/driver/shadow/slaveinmaster"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/webdav"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/serializer"
 	"sync"
@@ -32,6 +40,11 @@ type FileSystem struct {
 	Hooks       map[string][]Hook
 	Handler     driver.Handler
 	recycleLock sync.Mutex
+
+	// 上传完成后在后台依次执行的任务
+	backgroundLock    sync.Mutex
+	backgroundTasks   []func()
+	backgroundRunning bool
 }
 
 func getEmptyFS() *FileSystem {
@@ -43,6 +56,37 @@ func (fs *FileSystem) Recycle() {
 	fs.recycleLock.Lock()
 }
 
+// runInBackground 将耗时任务加入队列，由同一个后台协程依次执行，执行期间持有 recycleLock。
+// 只有启动后台协程时在调用方加锁，此时锁未被占用，不会阻塞请求
+func (fs *FileSystem) runInBackground(task func()) {
+	fs.backgroundLock.Lock()
+	defer fs.backgroundLock.Unlock()
+
+	fs.backgroundTasks = append(fs.backgroundTasks, task)
+	if fs.backgroundRunning {
+		return
+	}
+
+	fs.backgroundRunning = true
+	fs.recycleLock.Lock()
+	go func() {
+		for {
+			fs.backgroundLock.Lock()
+			if len(fs.backgroundTasks) == 0 {
+				fs.backgroundRunning = false
+				fs.recycleLock.Unlock()
+				fs.backgroundLock.Unlock()
+				return
+			}
+
+			next := fs.backgroundTasks[0]
+			fs.backgroundTasks = fs.backgroundTasks[1:]
+			fs.backgroundLock.Unlock()
+			next()
+		}
+	}()
+}
+
 func (fs *FileSystem) reset() {
 	fs.User = nil
 	fs.CleanTargets()
@@ -52,6 +96,9 @@ func (fs *FileSystem) reset() {
 	fs.Root = nil
 	fs.Lock = sync.Mutex{}
 	fs.recycleLock = sync.Mutex{}
+	fs.backgroundLock = sync.Mutex{}
+	fs.backgroundTasks = nil
+	fs.backgroundRunning = false
 }
 
 func (fs *FileSystem) CleanTargets() {
@@ -131,19 +178,72 @@ func (fs *FileSystem) DispatchHandler() error {
 		fs.Handler = local.Driver{
 			Policy: currentType,
 		}
-		return nil
 	case "remote":
 		handler, err := remote.NewDriver(currentType)
 		if err != nil {
 			return err
 		}
 		fs.Handler = handler
+	case "cos":
+		handler, err := cos.NewDriver(currentType)
+		if err != nil {
+			return err
+		}
+		fs.Handler = handler
+	case "onedrive":
+		handler, err := onedrive.NewDriver(currentType)
+		if err != nil {
+			return err
+		}
+		fs.Handler = handler
+	case "sftp":
+		handler, err := sftp.NewDriver(currentType)
+		if err != nil {
+			return err
+		}
+		fs.Handler = handler
+	case "webdav":
+		handler, err := webdav.NewDriver(currentType)
+		if err != nil {
+			return err
+		}
+		fs.Handler = handler
+	case "s3":
+		handler, err := s3.NewDriver(currentType)
+		if err != nil {
+			return err
+		}
+		fs.Handler = handler
+	case "mirror":
+		handler, err := mirror.NewDriver(currentType, dispatchReplica)
+		if err != nil {
+			return err
+		}
+		fs.Handler = handler
 	default:
 		return ErrUnknownPolicyType
 	}
+
+	// 加密和解密均在主机完成，从机只存取密文
+	if currentType.IsEncrypted() && conf.Sc.Role != "slave" {
+		handler, err := encrypt.NewDriver(fs.Handler, currentType)
+		if err != nil {
+			return err
+		}
+		fs.Handler = handler
+	}
 	return nil
 }
 
+// dispatchReplica 获取镜像存储策略中副本存储策略的处理器
+func dispatchReplica(policy *models.Policy) (driver.Handler, error) {
+	fs := &FileSystem{Policy: policy}
+	if err := fs.DispatchHandler(); err != nil {
+		return nil, err
+	}
+	return fs.Handler, nil
+}
+
 func NewFileSystem(user *models.User) (*FileSystem, error) {
 	fs := getEmptyFS()
 	fs.User = user
@@ -0,0 +1,46 @@
+package filesystem
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileSystem_RunInBackground(t *testing.T) {
+	fs := &FileSystem{}
+	release := make(chan struct{})
+	done := make(chan int, 3)
+
+	for i := 0; i < 3; i++ {
+		i := i
+		fs.runInBackground(func() {
+			if i == 0 {
+				<-release
+			}
+			done <- i
+		})
+	}
+
+	// 第一个任务阻塞时，之后的任务仍可加入队列，不阻塞调用方
+	close(release)
+	for i := 0; i < 3; i++ {
+		select {
+		case got := <-done:
+			if got != i {
+				t.Fatalf("task %d finished at position %d", got, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("background tasks did not finish")
+		}
+	}
+
+	recycled := make(chan struct{})
+	go func() {
+		fs.Recycle()
+		close(recycled)
+	}()
+	select {
+	case <-recycled:
+	case <-time.After(time.Second):
+		t.Fatal("recycle lock not released after background tasks")
+	}
+}
@@ -27,6 +27,7 @@ type UploadTaskInfo struct {
 	AppendStart     uint64
 	Model           interface{}
 	Src             string
+	SHA256          string
 }
 
 type FileHeader interface {
@@ -36,6 +37,7 @@ type FileHeader interface {
 	Info() *UploadTaskInfo
 	SetSize(uint642 uint64)
 	SetModel(fileModel interface{})
+	SetSHA256(hash string)
 	Seekable() bool
 }
 
@@ -54,6 +56,10 @@ type FileStream struct {
 	AppendStart     uint64
 	Model           interface{}
 	Src             string
+	// SHA256 本次写入内容的哈希，由上传过程计算或由秒传请求指定
+	SHA256 string
+	// Digest 客户端提供的完整文件校验和，格式同 Digest 头，上传完成时校验
+	Digest string
 }
 
 func (file *FileStream) Close() error {
@@ -95,6 +101,7 @@ func (file *FileStream) Info() *UploadTaskInfo {
 		AppendStart:     file.AppendStart,
 		Model:           file.Model,
 		Src:             file.Src,
+		SHA256:          file.SHA256,
 	}
 }
 
@@ -104,3 +111,7 @@ func (file *FileStream) SetSize(size uint64) {
 func (file *FileStream) SetModel(fileModel interface{}) {
 	file.Model = fileModel
 }
+
+func (file *FileStream) SetSHA256(hash string) {
+	file.SHA256 = hash
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/cache"
 	"github.com/jylc/cloudserver/pkg/cluster"
-	"github.com/jylc/cloudserver/pkg/filesystem/driver/local"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/serializer"
 	"github.com/sirupsen/logrus"
@@ -35,9 +34,14 @@ func (fs *FileSystem) CleanHooks(name string) {
 	}
 }
 
+// truncater 支持截断文件的存储适配器
+type truncater interface {
+	Truncate(ctx context.Context, src string, size uint64) error
+}
+
 func HookTruncateFileTo(size uint64) Hook {
 	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
-		if handler, ok := fs.Handler.(local.Driver); ok {
+		if handler, ok := fs.Handler.(truncater); ok {
 			return handler.Truncate(ctx, fileHeader.Info().SavePath, size)
 		}
 		return nil
@@ -79,12 +83,10 @@ func HookPopPlaceholderToFile(picInfo string) Hook {
 func HookGenerateThumb(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
 	fileMode := fileHeader.Info().Model.(*models.File)
 	if fs.Policy.IsThumbGenerateNeeded() {
-		fs.recycleLock.Lock()
-		go func() {
-			defer fs.recycleLock.Unlock()
+		fs.runInBackground(func() {
 			_, _ = fs.Handler.Delete(ctx, []string{fileMode.SourceName + models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb")})
-			fs.GenerateThumbnail(ctx, fileMode)
-		}()
+			_ = fs.GenerateThumbnail(ctx, fileMode)
+		})
 	}
 	return nil
 }
@@ -105,7 +107,7 @@ func SlaveAfterUpload(session *serializer.UploadSession) Hook {
 			SourceName: fileInfo.SavePath,
 		}
 
-		fs.GenerateThumbnail(ctx, &file)
+		_ = fs.GenerateThumbnail(ctx, &file)
 
 		if session.Callback == "" {
 			return nil
@@ -211,7 +213,9 @@ func GenericAfterUpdate(ctx context.Context, fs *FileSystem, newFile fsctx.FileH
 	if err != nil {
 		return err
 	}
-	return nil
+
+	// 内容已改变，原有的内容哈希失效
+	return originFile.UpdateSHA256(newFile.Info().SHA256)
 }
 
 func HookDeleteTempFile(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
@@ -1,45 +1,139 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/conf"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/thumb"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path/filepath"
 )
 
-// HandledExtension 可以生成缩略图的文件扩展名
-var HandledExtension = []string{"jpg", "jpeg", "png", "gif"}
+// GenerateThumbnail 生成缩略图并写入存储策略，在缩略图任务池中排队，生成完成后返回
+func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *models.File) error {
+	mimeType := mime.TypeByExtension(filepath.Ext(file.Name))
+	if !thumb.Supported(file.Name, mimeType) {
+		return ErrObjectNotExist
+	}
+
+	var err error
+	thumb.GetPool().Submit(func() {
+		err = fs.generateThumbnail(file, mimeType)
+	})
 
-func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *models.File) {
-	if !IsInExtensionList(HandledExtension, file.Name) {
-		return
+	if err != nil {
+		logrus.Warningf("Unable to generate thumbnail of [%s], %s", file.SourceName, err)
 	}
+	return err
+}
+
+func (fs *FileSystem) generateThumbnail(file *models.File, mimeType string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fileCtx := context.WithValue(ctx, fsctx.FileModelCtx, *file)
+	image, err := thumb.Generate(ctx, file.Name, mimeType, func() (io.ReadCloser, error) {
+		return fs.Handler.Get(fileCtx, file.SourceName)
+	})
+	if err != nil {
+		return err
+	}
+
+	w, h := image.GetSize()
+	image.GetThumb(fs.GenerateThumbnailSize(w, h))
+
+	var buf bytes.Buffer
+	if err := image.Encode(&buf); err != nil {
+		return err
+	}
+
+	if err := fs.Handler.Put(ctx, &fsctx.FileStream{
+		File:     ioutil.NopCloser(&buf),
+		Size:     uint64(buf.Len()),
+		SavePath: file.SourceName + models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb"),
+		Mode:     fsctx.Overwrite,
+	}); err != nil {
+		return err
+	}
+
+	picInfo := fmt.Sprintf("%d,%d", w, h)
+	if file.ID > 0 {
+		return file.UpdatePicInfo(picInfo)
+	}
+	file.PicInfo = picInfo
+	return nil
 }
 
 func (fs *FileSystem) GetThumb(ctx context.Context, id uint) (*response.ContentResponse, error) {
 	err := fs.resetFileIDIfNotExist(ctx, id)
-	if err != nil || fs.FileTarget[0].PicInfo == "" {
+	if err != nil {
 		return &response.ContentResponse{
 			Redirect: false,
 		}, ErrObjectNotExist
 	}
+
+	// 尚未生成过缩略图的文件在可生成时即时生成
+	canGenerate := fs.Policy.IsThumbGenerateNeeded() &&
+		thumb.Supported(fs.FileTarget[0].Name, mime.TypeByExtension(filepath.Ext(fs.FileTarget[0].Name)))
+	if fs.FileTarget[0].PicInfo == "" && !canGenerate {
+		return &response.ContentResponse{
+			Redirect: false,
+		}, ErrObjectNotExist
+	}
+
 	w, h := fs.GenerateThumbnailSize(0, 0)
 	ctx = context.WithValue(ctx, fsctx.ThumbSizeCtx, [2]uint{w, h})
 	ctx = context.WithValue(ctx, fsctx.FileModelCtx, fs.FileTarget[0])
 	res, err := fs.Handler.Thumb(ctx, fs.FileTarget[0].SourceName)
 
-	if err != nil && fs.Policy.Type == "local" {
-		fs.GenerateThumbnail(ctx, &fs.FileTarget[0])
-		res, err = fs.Handler.Thumb(ctx, fs.FileTarget[0].SourceName)
+	if err != nil && canGenerate {
+		if err = fs.GenerateThumbnail(ctx, &fs.FileTarget[0]); err == nil {
+			ctx = context.WithValue(ctx, fsctx.FileModelCtx, fs.FileTarget[0])
+			res, err = fs.Handler.Thumb(ctx, fs.FileTarget[0].SourceName)
+		}
 	}
 
-	if err == nil && conf.Sc.Role == "master" {
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Sc.Role == "master" {
 		res.MaxAge = models.GetIntSetting("preview_timeout", 60)
 	}
 	return res, nil
 }
 
+// GenerateThumbnailSize 计算保持原图比例且不超过设定尺寸的缩略图尺寸，原图尺寸未知时返回设定尺寸
 func (fs *FileSystem) GenerateThumbnailSize(w, h int) (uint, uint) {
-	return uint(models.GetIntSetting("thumb_width", 400)), uint(models.GetIntSetting("thumb_width", 300))
+	maxWidth := uint(models.GetIntSetting("thumb_width", 400))
+	maxHeight := uint(models.GetIntSetting("thumb_height", 300))
+	if w <= 0 || h <= 0 {
+		return maxWidth, maxHeight
+	}
+
+	if uint(w) <= maxWidth && uint(h) <= maxHeight {
+		return uint(w), uint(h)
+	}
+
+	width, height := maxWidth, maxHeight
+	if float64(w)/float64(maxWidth) > float64(h)/float64(maxHeight) {
+		height = uint(float64(h) * float64(maxWidth) / float64(w))
+	} else {
+		width = uint(float64(w) * float64(maxHeight) / float64(h))
+	}
+
+	if width == 0 {
+		width = 1
+	}
+	if height == 0 {
+		height = 1
+	}
+	return width, height
 }
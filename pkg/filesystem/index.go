@@ -0,0 +1,141 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"github.com/jylc/cloudserver/pkg/search"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+)
+
+// IndexContent 提取文件文本并写入全文索引，不支持的文件类型会从索引中移除
+func (fs *FileSystem) IndexContent(ctx context.Context, file *models.File) error {
+	if !search.Enabled() {
+		return nil
+	}
+
+	if !search.Supported(file.Name) {
+		return search.Delete([]uint{file.ID})
+	}
+
+	// 文件不在当前存储策略下时切换策略，读取完成后恢复。
+	// 上传后的钩子中两者一致，不会影响并行使用处理器的其他钩子
+	if fs.Policy == nil || fs.Policy.ID != file.PolicyID {
+		policy, handler := fs.Policy, fs.Handler
+		defer func() {
+			fs.Policy, fs.Handler = policy, handler
+		}()
+
+		fs.Policy = file.GetPolicy()
+		if err := fs.DispatchHandler(); err != nil {
+			return err
+		}
+	}
+
+	if maxSize := uint64(models.GetIntSetting("search_content_max_size", 10<<20)); maxSize > 0 && file.Size > maxSize {
+		return search.Delete([]uint{file.ID})
+	}
+
+	fileCtx := context.WithValue(ctx, fsctx.FileModelCtx, *file)
+	rs, err := fs.Handler.Get(fileCtx, file.SourceName)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+	defer rs.Close()
+
+	content, err := ioutil.ReadAll(rs)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	text, err := search.Extract(ctx, bytes.NewReader(content), int64(len(content)), file.Name)
+	if err != nil {
+		return err
+	}
+
+	return search.Index(file, text)
+}
+
+// HookIndexContent 上传或更新完成后在后台更新全文索引
+func HookIndexContent(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	if !search.Enabled() {
+		return nil
+	}
+
+	fileModel, ok := fileHeader.Info().Model.(*models.File)
+	if !ok {
+		return nil
+	}
+
+	fs.runInBackground(func() {
+		if err := fs.IndexContent(context.Background(), fileModel); err != nil {
+			logrus.Warningf("Unable to index content of [%s], %s", fileModel.Name, err)
+		}
+	})
+	return nil
+}
+
+// SearchContent 全文搜索文件内容，结果附带高亮片段。
+// 索引不记录文件位置，命中的文件以数据库记录为准，移动、删除至回收站后的文件无需更新索引
+func (fs *FileSystem) SearchContent(ctx context.Context, keywords string) ([]serializer.Object, error) {
+	maxResults := models.GetIntSetting("search_content_max_results", 100)
+	parents, err := fs.searchParents(fs.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	inParents := make(map[uint]bool, len(parents))
+	for _, parent := range parents {
+		inParents[parent] = true
+	}
+
+	// 索引中不记录所在目录，限定搜索范围时按相关度分页读取结果，
+	// 排除不在范围内的文件直至凑满结果
+	res := make([]models.File, 0)
+	fragments := make(map[string][]string)
+	for from := 0; len(res) < maxResults; from += maxResults {
+		hits, err := search.Search(fs.User.ID, keywords, maxResults, from)
+		if err != nil {
+			return nil, err
+		}
+
+		ids := make([]uint, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.FileID)
+		}
+
+		files, err := models.GetFilesByIDs(ids, fs.User.ID)
+		if err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+
+		fileMap := make(map[uint]models.File, len(files))
+		for _, file := range files {
+			if len(parents) == 0 || inParents[file.FolderID] {
+				fileMap[file.ID] = file
+			}
+		}
+
+		for _, hit := range hits {
+			if file, ok := fileMap[hit.FileID]; ok && len(res) < maxResults {
+				res = append(res, file)
+				fragments[hashid.HashID(file.ID, hashid.FileID)] = hit.Fragments
+			}
+		}
+
+		if len(hits) < maxResults {
+			break
+		}
+	}
+
+	fs.SetTargetFile(&res)
+	objects := fs.listObjects(ctx, "/", res, nil, nil)
+	for i := range objects {
+		objects[i].Highlights = fragments[objects[i].ID]
+	}
+	return objects, nil
+}
@@ -6,16 +6,16 @@ import (
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/hashid"
+	"github.com/jylc/cloudserver/pkg/search"
 	"github.com/jylc/cloudserver/pkg/serializer"
 	"github.com/jylc/cloudserver/pkg/utils"
+	"github.com/sirupsen/logrus"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
 func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool) error {
-	var deletedFiles = make([]*models.File, 0, len(fs.FileTarget))
-	var allFiles = make([]*models.File, 0, len(fs.FileTarget))
-
 	if len(dirs) > 0 {
 		err := fs.ListDeleteDirs(ctx, dirs)
 		if err != nil {
@@ -29,6 +29,25 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool
 			return err
 		}
 	}
+	return fs.deleteTargets(ctx, force)
+}
+
+// deleteTargets 永久删除已列出的目标文件及目录
+func (fs *FileSystem) deleteTargets(ctx context.Context, force bool) error {
+	var deletedFiles = make([]*models.File, 0, len(fs.FileTarget))
+	var allFiles = make([]*models.File, 0, len(fs.FileTarget))
+
+	// 先删除历史版本，版本删除失败时其引用的物理文件不会被误删
+	fileIDs := make([]uint, 0, len(fs.FileTarget))
+	for _, file := range fs.FileTarget {
+		fileIDs = append(fileIDs, file.ID)
+	}
+	versions, err := models.GetVersionsByFiles(fileIDs)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+	fs.DeleteVersions(ctx, versions)
+
 	filesToBeDelete, err := models.RemoveFilesWithSoftLinks(fs.FileTarget)
 	if err != nil {
 		return ErrDBListObjects.WithError(err)
@@ -57,6 +76,13 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool
 	}
 
 	models.DeleteShareBySourceIDs(deletedFileIDs, false)
+	models.DeleteAlbumFilesByFileIDs(deletedFileIDs)
+	models.DeleteObjectTagsByObjects(deletedFileIDs, false)
+	models.DeleteFavoritesByObjects(deletedFileIDs, false)
+	models.DeleteRecentFilesByFileIDs(deletedFileIDs)
+	if err := search.Delete(deletedFileIDs); err != nil {
+		logrus.Warningf("Unable to remove deleted files from search index, %s", err)
+	}
 
 	if len(deletedFiles) == len(allFiles) {
 		var allFolderIDs = make([]uint, 0, len(fs.DirTarget))
@@ -69,6 +95,8 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool
 		}
 
 		models.DeleteShareBySourceIDs(allFolderIDs, true)
+		models.DeleteObjectTagsByObjects(allFolderIDs, true)
+		models.DeleteFavoritesByObjects(allFolderIDs, true)
 	}
 
 	if notDeleted := len(fs.FileTarget) - len(deletedFiles); notDeleted > 0 {
@@ -210,6 +238,20 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 
 	objects := make([]serializer.Object, 0, len(files)+len(folders))
 
+	// 手动标签仅对文件所有者可见，分享中不返回
+	var folderTags, fileTags map[uint][]models.Tag
+	if shareKey == "" && (len(files) > 0 || len(folders) > 0) {
+		folderIDs := make([]uint, 0, len(folders))
+		for _, folder := range folders {
+			folderIDs = append(folderIDs, folder.ID)
+		}
+		fileIDs := make([]uint, 0, len(files))
+		for _, file := range files {
+			fileIDs = append(fileIDs, file.ID)
+		}
+		folderTags, fileTags, _ = models.GetObjectTags(folderIDs, fileIDs)
+	}
+
 	var processedPath string
 
 	for _, subFolder := range folders {
@@ -230,6 +272,7 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 			Type:       "dir",
 			Date:       subFolder.UpdatedAt,
 			CreateDate: subFolder.CreatedAt,
+			Tags:       serializer.BuildObjectTags(folderTags[subFolder.ID]),
 		})
 	}
 
@@ -252,6 +295,7 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 				Date:          file.UpdatedAt,
 				CreateDate:    file.CreatedAt,
 				SourceEnabled: file.GetPolicy().IsOriginLinkEnable,
+				Tags:          serializer.BuildObjectTags(fileTags[file.ID]),
 			}
 			if shareKey != "" {
 				newFile.Key = shareKey
@@ -273,10 +317,19 @@ func (fs *FileSystem) Rename(ctx context.Context, dirs, files []uint, new string
 			return ErrPathNotExist
 		}
 
+		oldExt := filepath.Ext(fileObject[0].Name)
 		err = fileObject[0].Rename(new)
 		if err != nil {
 			return ErrFileExisted
 		}
+
+		// 扩展名改变后文本提取方式可能不同，需重新索引
+		if search.Enabled() && !strings.EqualFold(oldExt, filepath.Ext(new)) {
+			fileObject[0].Name = new
+			if err := fs.IndexContent(ctx, &fileObject[0]); err != nil {
+				logrus.Warningf("Unable to update search index of [%s], %s", new, err)
+			}
+		}
 		return nil
 	}
 
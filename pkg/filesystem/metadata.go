@@ -0,0 +1,45 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/metadata"
+	"github.com/sirupsen/logrus"
+)
+
+// ExtractMetadata 提取文件的 EXIF 及媒体元数据并保存至文件记录
+func (fs *FileSystem) ExtractMetadata(ctx context.Context, file *models.File) error {
+	if !metadata.Supported(file.Name) {
+		return nil
+	}
+
+	fileCtx := context.WithValue(ctx, fsctx.FileModelCtx, *file)
+	content, err := fs.Handler.Get(fileCtx, file.SourceName)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+	defer content.Close()
+
+	data, err := metadata.Extract(ctx, file.Name, content)
+	if err != nil {
+		return err
+	}
+
+	return file.UpdateMetadata(data)
+}
+
+// HookExtractMetadata 上传完成后在后台提取文件元数据
+func HookExtractMetadata(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	fileModel, ok := fileHeader.Info().Model.(*models.File)
+	if !ok || !metadata.Supported(fileModel.Name) {
+		return nil
+	}
+
+	fs.runInBackground(func() {
+		if err := fs.ExtractMetadata(context.Background(), fileModel); err != nil {
+			logrus.Warningf("Unable to extract metadata of [%s], %s", fileModel.Name, err)
+		}
+	})
+	return nil
+}
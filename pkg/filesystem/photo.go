@@ -0,0 +1,39 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/serializer"
+)
+
+// photoPatterns 时间线中包含的照片类型
+var photoPatterns = []string{"%.jpg", "%.jpeg", "%.png", "%.gif", "%.webp", "%.bmp", "%.tif", "%.tiff",
+	"%.heic", "%.heif", "%.dng", "%.cr2", "%.nef", "%.arw"}
+
+// Timeline 按拍摄时间由新到旧分页列出用户的照片，并按天分组
+func (fs *FileSystem) Timeline(ctx context.Context, page, pageSize int) ([]serializer.TimelineBucket, int, error) {
+	photos, total, err := models.GetPhotos(fs.User.ID, photoPatterns, page, pageSize)
+	if err != nil {
+		return nil, 0, ErrDBListObjects.WithError(err)
+	}
+
+	buckets := make([]serializer.TimelineBucket, 0)
+	for _, photo := range photos {
+		date := photo.TakenAt().Format("2006-01-02")
+		if len(buckets) == 0 || buckets[len(buckets)-1].Date != date {
+			buckets = append(buckets, serializer.TimelineBucket{Date: date})
+		}
+
+		bucket := &buckets[len(buckets)-1]
+		bucket.Objects = append(bucket.Objects, fs.listObjects(ctx, "/", []models.File{photo}, nil, nil)...)
+	}
+
+	return buckets, total, nil
+}
+
+// ListAlbum 分页列出相册中的文件
+func (fs *FileSystem) ListAlbum(ctx context.Context, album *models.Album, page, pageSize int) ([]serializer.Object, int) {
+	files, total := album.ListFiles(page, pageSize)
+	fs.SetTargetFile(&files)
+	return fs.listObjects(ctx, "/", files, nil, nil), total
+}
@@ -0,0 +1,50 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/sirupsen/logrus"
+)
+
+// RecordRecent 记录当前用户对文件的访问，他人的文件（如分享）不记录
+func (fs *FileSystem) RecordRecent(file *models.File, action string) {
+	if fs.User == nil || fs.User.ID == 0 || file.UserID != fs.User.ID {
+		return
+	}
+
+	if err := models.RecordRecentFile(fs.User.ID, file.ID, action); err != nil {
+		logrus.Warningf("Unable to record recent access of [%s], %s", file.Name, err)
+	}
+}
+
+// HookRecordRecent 上传或更新完成后记录最近访问
+func HookRecordRecent(action string) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		fs.RecordRecent(fileHeader.Info().Model.(*models.File), action)
+		return nil
+	}
+}
+
+// ListFavorites 列出用户收藏的目录和文件
+func (fs *FileSystem) ListFavorites(ctx context.Context) ([]serializer.Object, error) {
+	folders, files, err := models.GetFavorites(fs.User.ID)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, folders, nil), nil
+}
+
+// ListRecent 列出用户最近访问的文件
+func (fs *FileSystem) ListRecent(ctx context.Context) ([]serializer.Object, error) {
+	files, err := models.GetRecentFiles(fs.User.ID)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, nil, nil), nil
+}
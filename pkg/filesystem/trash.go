@@ -0,0 +1,184 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"path"
+	"strings"
+)
+
+// Trash 将目录及文件移入回收站，上传中的占位文件直接删除
+func (fs *FileSystem) Trash(ctx context.Context, dirs, files []uint) error {
+	if len(dirs) > 0 {
+		folders, err := models.GetFolderByIDs(dirs, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		for i := 0; i < len(folders); i++ {
+			if folders[i].ParentID == nil {
+				continue
+			}
+			if err := fs.trashFolder(&folders[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	placeholders := make([]uint, 0)
+	if len(files) > 0 {
+		fileModels, err := models.GetFilesByIDs(files, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		for i := 0; i < len(fileModels); i++ {
+			if fileModels[i].UploadSessionID != nil {
+				placeholders = append(placeholders, fileModels[i].ID)
+				continue
+			}
+			if err := fs.trashFile(&fileModels[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(placeholders) > 0 {
+		return fs.Delete(ctx, []uint{}, placeholders, false)
+	}
+	return nil
+}
+
+func (fs *FileSystem) trashFolder(folder *models.Folder) error {
+	subFolders, err := models.GetRecursiveChildFolder([]uint{folder.ID}, fs.User.ID, true)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	files, err := models.GetChildFilesOfFolders(&subFolders)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	if err := folder.TraceRoot(); err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	folderIDs := make([]uint, 0, len(subFolders))
+	for _, subFolder := range subFolders {
+		folderIDs = append(folderIDs, subFolder.ID)
+	}
+
+	var size uint64
+	fileIDs := make([]uint, 0, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+		size += file.Size
+	}
+
+	trash := &models.Trash{
+		UserID:   fs.User.ID,
+		ObjectID: folder.ID,
+		IsFolder: true,
+		Name:     folder.Name,
+		Path:     folder.Position,
+		Size:     size,
+	}
+	if err := trash.Create(folderIDs, fileIDs); err != nil {
+		return ErrDBTrashObjects.WithError(err)
+	}
+	return nil
+}
+
+func (fs *FileSystem) trashFile(file *models.File) error {
+	parents, err := models.GetFolderByIDs([]uint{file.FolderID}, fs.User.ID)
+	if err != nil || len(parents) == 0 {
+		return ErrPathNotExist.WithError(err)
+	}
+
+	parent := parents[0]
+	if err := parent.TraceRoot(); err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	trash := &models.Trash{
+		UserID:   fs.User.ID,
+		ObjectID: file.ID,
+		Name:     file.Name,
+		Path:     path.Join(parent.Position, parent.Name),
+		Size:     file.Size,
+	}
+	if err := trash.Create(nil, []uint{file.ID}); err != nil {
+		return ErrDBTrashObjects.WithError(err)
+	}
+	return nil
+}
+
+// Restore 将回收站中的对象还原至原路径，原路径不存在时重新创建，重名时自动重命名
+func (fs *FileSystem) Restore(ctx context.Context, trashes []models.Trash) error {
+	for i := 0; i < len(trashes); i++ {
+		folders, files, err := trashes[i].Objects()
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		// 对象已不存在，仅清除回收站记录
+		if len(folders)+len(files) == 0 {
+			if err := trashes[i].Delete(); err != nil {
+				return ErrDBDeleteObjects.WithError(err)
+			}
+			continue
+		}
+
+		exist, parent := fs.IsPathExist(trashes[i].Path)
+		if !exist {
+			parent, err = fs.CreateDirectory(ctx, trashes[i].Path)
+			if err != nil {
+				return err
+			}
+		}
+
+		name := fs.availableName(parent, trashes[i].Name)
+		if err := trashes[i].Restore(parent.ID, name, folders, files); err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+	}
+	return nil
+}
+
+// availableName 返回目录下不与已有对象重名的名称
+func (fs *FileSystem) availableName(parent *models.Folder, name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 1; ; i++ {
+		fileExist, _ := fs.IsChildFileExist(parent, candidate)
+		if _, err := parent.GetChild(candidate); !fileExist && err != nil {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
+
+// Purge 永久删除回收站中的对象，物理文件删除失败的记录将保留在回收站中
+func (fs *FileSystem) Purge(ctx context.Context, trashes []models.Trash) error {
+	for i := 0; i < len(trashes); i++ {
+		folders, files, err := trashes[i].Objects()
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		fs.CleanTargets()
+		fs.SetTargetDir(&folders)
+		fs.SetTargetFile(&files)
+		if err := fs.deleteTargets(ctx, false); err != nil {
+			return err
+		}
+
+		if err := trashes[i].Delete(); err != nil {
+			return ErrDBDeleteObjects.WithError(err)
+		}
+	}
+	return nil
+}
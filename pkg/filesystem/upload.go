@@ -41,6 +41,13 @@ func (fs *FileSystem) Upload(ctx context.Context, file *fsctx.FileStream) (err e
 	}
 
 	if file.Mode&fsctx.Nop != fsctx.Nop {
+		// 完整写入时同步计算内容哈希，用于去重
+		var hasher *hashReader
+		if (file.Mode&fsctx.Append != fsctx.Append || file.AppendStart == 0) && file.File != nil {
+			hasher = newHashReader(file.File)
+			file.File = hasher
+		}
+
 		go fs.CancelUpload(ctx, savePath, file)
 
 		err = fs.Handler.Put(ctx, file)
@@ -48,6 +55,10 @@ func (fs *FileSystem) Upload(ctx context.Context, file *fsctx.FileStream) (err e
 			fs.Trigger(ctx, "AfterUploadFailed", file)
 			return err
 		}
+
+		if hasher != nil {
+			file.SHA256 = hasher.Sum(file.Size)
+		}
 	}
 
 	err = fs.Trigger(ctx, "AfterUpload", file)
@@ -117,6 +128,9 @@ func (fs *FileSystem) UploadFromStream(ctx context.Context, file *fsctx.FileStre
 		fs.Use("AfterUploadCanceled", HookDeleteTempFile)
 		fs.Use("AfterUpload", GenericAfterUpload)
 		fs.Use("AfterUpload", HookGenerateThumb)
+		fs.Use("AfterUpload", HookExtractMetadata)
+		fs.Use("AfterUpload", HookIndexContent)
+		fs.Use("AfterUpload", HookRecordRecent(models.RecentActionUpload))
 		fs.Use("AfterValidateFailed", HookDeleteTempFile)
 	}
 
@@ -164,6 +178,20 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 	fs.Use("BeforeUpload", HookValidateFile)
 	fs.Use("BeforeUpload", HookValidateCapacity)
 
+	// 秒传：存储策略下已有内容相同的文件时直接引用，跳过数据传输。
+	// 客户端无法证明持有文件内容，仅允许引用用户自己的文件，避免通过哈希获取他人的文件
+	// 他人的文件须经主机中转完整内容，校验哈希一致后再引用，不重复写入
+	var linkHash string
+	if blob, ok := fs.FindDuplicate(file.SHA256, fileSize); ok {
+		if blob.IsOwnedBy(fs.User.ID) {
+			return fs.instantUpload(ctx, file, blob)
+		}
+		if fs.Policy.IsTransitUpload(fileSize) {
+			linkHash = file.SHA256
+		}
+	}
+	file.SHA256 = ""
+
 	if err := fs.Upload(ctx, file); err != nil {
 		return nil, err
 	}
@@ -176,6 +204,8 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 		Size:           fileSize,
 		SavePath:       file.SavePath,
 		LastModified:   file.LastModified,
+		Digest:         file.Digest,
+		SHA256:         linkHash,
 		Policy:         *fs.Policy,
 		CallbackSecret: utils.RandStringRunes(32),
 	}
@@ -207,3 +237,16 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 	credential.Expires = time.Now().Add(time.Duration(callBackSessionTTL) * time.Second).Unix()
 	return credential, nil
 }
+
+// instantUpload 引用已有的物理文件创建文件记录
+func (fs *FileSystem) instantUpload(ctx context.Context, file *fsctx.FileStream, blob *models.Blob) (*serializer.UploadCredential, error) {
+	file.UploadSessionID = nil
+	file.SavePath = blob.SourceName
+
+	fs.Use("AfterUpload", GenericAfterUpload)
+	if err := fs.Upload(ctx, file); err != nil {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{Instant: true}, nil
+}
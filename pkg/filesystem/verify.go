@@ -0,0 +1,85 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/checksum"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"hash"
+	"io"
+)
+
+// VerifyContent 使用当前的存储处理器读取已保存的文件内容，校验大小与 expected 校验和，
+// 返回内容的 SHA-256 哈希。expected 为空时仅校验大小
+func (fs *FileSystem) VerifyContent(ctx context.Context, file *models.File, expected *checksum.Checksum) (string, error) {
+	rs, err := fs.Handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, *file), file.SourceName)
+	if err != nil {
+		return "", ErrIO.WithError(err)
+	}
+	defer rs.Close()
+
+	sha := sha256.New()
+	writers := []io.Writer{sha}
+	var expectedHash hash.Hash
+	if expected != nil {
+		expectedHash = expected.Hash()
+		writers = append(writers, expectedHash)
+	}
+
+	n, err := io.Copy(io.MultiWriter(writers...), rs)
+	if err != nil {
+		return "", ErrIO.WithError(err)
+	}
+
+	if uint64(n) != file.Size {
+		return "", ErrFileSizeMismatch
+	}
+
+	if expected != nil {
+		if err := expected.Verify(expectedHash); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(sha.Sum(nil)), nil
+}
+
+// HookVerifyChecksum 校验本次写入内容的校验和，须在更新文件大小的钩子之前执行
+func HookVerifyChecksum(reader *checksum.Reader) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		return reader.Verify()
+	}
+}
+
+// HookVerifyFileDigest 上传会话的最后一个分片完成后，读取完整文件校验客户端提供的校验和，
+// 须在 HookPopPlaceholderToFile 之前执行
+func HookVerifyFileDigest(digest string) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		expected, err := checksum.Parse(digest)
+		if err != nil {
+			return err
+		}
+
+		fileInfo := fileHeader.Info()
+		file, ok := fileInfo.Model.(*models.File)
+		if !ok {
+			// 从机上传没有文件记录
+			file = &models.File{
+				Name:       fileInfo.FileName,
+				SourceName: fileInfo.SavePath,
+				Size:       fileInfo.AppendStart + fileInfo.Size,
+			}
+		}
+
+		hash, err := fs.VerifyContent(ctx, file, expected)
+		if err != nil {
+			return err
+		}
+
+		// 后续去重无需再次读取文件
+		fileHeader.SetSHA256(hash)
+		return nil
+	}
+}
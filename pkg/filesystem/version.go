@@ -0,0 +1,139 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/jylc/cloudserver/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// HookKeepVersion 覆盖文件后将原有内容保留为历史版本，origin 为覆盖前的文件记录
+func HookKeepVersion(origin models.File) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		if err := origin.KeepVersion(fs.User.Group.OptionsSerialized.VersionCapacity); err != nil {
+			return err
+		}
+
+		fs.PruneVersions(ctx, origin.ID)
+		return nil
+	}
+}
+
+// HookValidateVersionCapacity 保留历史版本时验证容量，历史版本计入容量时新内容需完整计入
+func HookValidateVersionCapacity(ctx context.Context, fs *FileSystem, newFile fsctx.FileHeader) error {
+	if fs.User.Group.OptionsSerialized.VersionCapacity {
+		return HookValidateCapacity(ctx, fs, newFile)
+	}
+	return HookValidateCapacityDiff(ctx, fs, newFile)
+}
+
+// PruneVersions 删除超出用户组保留数量的历史版本
+func (fs *FileSystem) PruneVersions(ctx context.Context, fileID uint) {
+	versions, err := models.GetVersionsByFile(fileID, fs.User.ID)
+	if err != nil {
+		logrus.Warningf("Unable to list versions of file [%d], %s", fileID, err)
+		return
+	}
+
+	limit := fs.User.Group.OptionsSerialized.MaxVersions
+	if limit < 0 {
+		limit = 0
+	}
+	if len(versions) > limit {
+		fs.DeleteVersions(ctx, versions[limit:])
+	}
+}
+
+// DeleteVersions 删除历史版本及未被其他对象引用的物理文件，返回删除失败的版本数量
+func (fs *FileSystem) DeleteVersions(ctx context.Context, versions []models.FileVersion) int {
+	if len(versions) == 0 {
+		return 0
+	}
+
+	// 删除物理文件会切换存储策略，完成后恢复
+	policy, handler := fs.Policy, fs.Handler
+	defer func() {
+		fs.Policy, fs.Handler = policy, handler
+	}()
+
+	toBeDeleted, err := models.RemoveVersionsWithSoftLinks(versions)
+	if err != nil {
+		logrus.Warningf("Unable to check version references, %s", err)
+		return len(versions)
+	}
+
+	policyGroup := make(map[uint][]*models.File)
+	for _, version := range toBeDeleted {
+		policyGroup[version.PolicyID] = append(policyGroup[version.PolicyID], &models.File{
+			PolicyID:   version.PolicyID,
+			SourceName: version.SourceName,
+		})
+	}
+
+	failed := 0
+	failedSources := fs.deleteGroupedFile(ctx, policyGroup)
+	for i := 0; i < len(versions); i++ {
+		if utils.ContainsString(failedSources[versions[i].PolicyID], versions[i].SourceName) {
+			failed++
+			continue
+		}
+
+		if err := versions[i].Delete(); err != nil {
+			logrus.Warningf("Unable to delete version record [%d], %s", versions[i].ID, err)
+			failed++
+		}
+	}
+	return failed
+}
+
+// GetVersionContent 获取历史版本的内容
+func (fs *FileSystem) GetVersionContent(ctx context.Context, file *models.File, version *models.FileVersion) (response.RSCloser, error) {
+	versionFile := *file
+	versionFile.SourceName = version.SourceName
+	versionFile.PolicyID = version.PolicyID
+	versionFile.Size = version.Size
+	versionFile.UpdatedAt = version.CreatedAt
+	fs.FileTarget = []models.File{versionFile}
+	if err := fs.resetPolicyToFirstFile(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, versionFile)
+	rs, err := fs.Handler.Get(ctx, versionFile.SourceName)
+	if err != nil {
+		return nil, ErrIO.WithError(err)
+	}
+	return fs.withSpeedLimit(rs), nil
+}
+
+// RestoreVersion 将文件恢复至历史版本，当前内容保留为新的历史版本
+func (fs *FileSystem) RestoreVersion(ctx context.Context, file *models.File, version *models.FileVersion) error {
+	if file.UploadSessionID != nil {
+		return ErrObjectNotExist
+	}
+
+	// 恢复后当前内容转为历史版本，需检查容量的净增量
+	counted := fs.User.Group.OptionsSerialized.VersionCapacity
+	increase, released := version.Size, file.Size
+	if counted {
+		increase += file.Size
+	}
+	if version.Counted {
+		released += version.Size
+	}
+	if increase > released && fs.User.GetRemainingCapacity() < increase-released {
+		return ErrInsufficientCapacity
+	}
+
+	if err := file.RestoreVersion(version, counted); err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	fs.PruneVersions(ctx, file.ID)
+	if err := fs.IndexContent(ctx, file); err != nil {
+		logrus.Warningf("Unable to update search index of [%s], %s", file.Name, err)
+	}
+	return nil
+}
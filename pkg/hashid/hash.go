@@ -13,6 +13,9 @@ const (
 	FolderID
 	TagID
 	PolicyID
+	TrashID
+	VersionID
+	AlbumID
 )
 
 var ErrTypeNotMatch = errors.New("ID type not match")
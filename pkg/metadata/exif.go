@@ -0,0 +1,55 @@
+package metadata
+
+import (
+	"context"
+	"github.com/jylc/cloudserver/models"
+	"github.com/rwcarlsen/goexif/exif"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// exifExtractor 提取图像的 EXIF 信息
+type exifExtractor struct{}
+
+func (exifExtractor) Extract(ctx context.Context, file io.ReadSeeker, name string) (map[string]string, error) {
+	x, err := exif.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string)
+	for key, field := range map[string]exif.FieldName{
+		"camera_make":  exif.Make,
+		"camera_model": exif.Model,
+		"lens_model":   exif.LensModel,
+	} {
+		if tag, err := x.Get(field); err == nil {
+			if value, err := tag.StringVal(); err == nil {
+				res[key] = strings.TrimSpace(strings.Trim(value, "\x00"))
+			}
+		}
+	}
+
+	if tag, err := x.Get(exif.Orientation); err == nil {
+		if orientation, err := tag.Int(0); err == nil {
+			res["orientation"] = strconv.Itoa(orientation)
+		}
+	}
+
+	// EXIF 拍摄时间通常不含时区，按拍摄地的本地时间保存
+	if takenAt, err := x.DateTime(); err == nil {
+		res["taken_at"] = takenAt.Format(models.TakenAtLayout)
+	}
+
+	if lat, long, err := x.LatLong(); err == nil {
+		res["gps_latitude"] = strconv.FormatFloat(lat, 'f', 6, 64)
+		res["gps_longitude"] = strconv.FormatFloat(long, 'f', 6, 64)
+	}
+
+	return res, nil
+}
+
+func (exifExtractor) Enabled() bool {
+	return true
+}
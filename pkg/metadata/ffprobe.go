@@ -0,0 +1,92 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/utils"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// ffprobeExtractor 使用 ffprobe 提取音视频的时长、编码及分辨率
+type ffprobeExtractor struct{}
+
+type ffprobeResult struct {
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		// Disposition.AttachedPic 为 1 时表示音频文件内嵌的封面
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+}
+
+func (e *ffprobeExtractor) Extract(ctx context.Context, file io.ReadSeeker, name string) (map[string]string, error) {
+	tempPath := filepath.Join(utils.RelativePath(models.GetSettingByName("temp_path")), "metadata")
+	input, err := utils.CreateTempFile(tempPath, "metadata_*"+filepath.Ext(name), file)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(input)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx,
+		models.GetSettingByNameWithDefault("metadata_ffprobe_path", "ffprobe"),
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		input,
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to execute ffprobe: %w, %s", err, stderr.String())
+	}
+
+	var probe ffprobeResult
+	if err := json.Unmarshal(stdout.Bytes(), &probe); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string)
+	if duration, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		res["duration"] = strconv.FormatFloat(duration, 'f', 3, 64)
+	}
+	res["bitrate"] = probe.Format.BitRate
+
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			if _, ok := res["video_codec"]; !ok && stream.Disposition.AttachedPic == 0 {
+				res["video_codec"] = stream.CodecName
+				if stream.Width > 0 && stream.Height > 0 {
+					res["resolution"] = fmt.Sprintf("%dx%d", stream.Width, stream.Height)
+				}
+			}
+		case "audio":
+			if _, ok := res["audio_codec"]; !ok {
+				res["audio_codec"] = stream.CodecName
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (e *ffprobeExtractor) Enabled() bool {
+	return models.IsTrueVal(models.GetSettingByName("metadata_ffprobe_enabled"))
+}
@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNotAvailable 没有可处理该文件的元数据提取器
+	ErrNotAvailable = errors.New("no available metadata extractor")
+)
+
+// Extractor 元数据提取器
+type Extractor interface {
+	// Extract 读取文件内容并返回提取到的元数据
+	Extract(ctx context.Context, file io.ReadSeeker, name string) (map[string]string, error)
+	// Enabled 提取器是否已启用
+	Enabled() bool
+}
+
+var (
+	mu         sync.RWMutex
+	extractors = make(map[string][]Extractor)
+)
+
+// Register 注册元数据提取器，扩展名不含点号
+func Register(extractor Extractor, exts []string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, ext := range exts {
+		ext = strings.ToLower(ext)
+		extractors[ext] = append(extractors[ext], extractor)
+	}
+}
+
+// GetExtractors 获取可处理该文件且已启用的提取器
+func GetExtractors(name string) []Extractor {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return nil
+	}
+
+	res := make([]Extractor, 0)
+	for _, extractor := range extractors[ext[1:]] {
+		if extractor.Enabled() {
+			res = append(res, extractor)
+		}
+	}
+	return res
+}
+
+// Supported 是否有提取器可处理该文件
+func Supported(name string) bool {
+	return len(GetExtractors(name)) > 0
+}
+
+// Extract 使用所有可处理该文件的提取器提取元数据，后执行的提取器覆盖同名字段
+func Extract(ctx context.Context, name string, file io.ReadSeeker) (map[string]string, error) {
+	candidates := GetExtractors(name)
+	if len(candidates) == 0 {
+		return nil, ErrNotAvailable
+	}
+
+	res := make(map[string]string)
+	var errs []string
+	for _, extractor := range candidates {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return res, err
+		}
+
+		data, err := extractor.Extract(ctx, file, name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		for k, v := range data {
+			if v != "" {
+				res[k] = v
+			}
+		}
+	}
+
+	if len(res) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("failed to extract metadata: %s", strings.Join(errs, "; "))
+	}
+	return res, nil
+}
+
+func init() {
+	Register(exifExtractor{}, []string{"jpg", "jpeg", "tif", "tiff", "dng", "cr2", "nef", "arw"})
+	Register(tagExtractor{}, []string{"mp3", "flac", "m4a", "m4b", "ogg", "aac"})
+	Register(&ffprobeExtractor{}, []string{"3g2", "3gp", "asf", "avi", "divx", "flv", "m2ts", "m2v", "m4v", "mkv",
+		"mov", "mp4", "mpeg", "mpg", "mts", "mxf", "ogv", "rm", "rmvb", "webm", "wmv",
+		"mp3", "flac", "m4a", "m4b", "ogg", "aac", "wav", "ape", "wma", "opus"})
+}
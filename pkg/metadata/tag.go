@@ -0,0 +1,41 @@
+package metadata
+
+import (
+	"context"
+	"github.com/dhowden/tag"
+	"io"
+	"strconv"
+)
+
+// tagExtractor 提取音频文件的 ID3 等标签信息
+type tagExtractor struct{}
+
+func (tagExtractor) Extract(ctx context.Context, file io.ReadSeeker, name string) (map[string]string, error) {
+	m, err := tag.ReadFrom(file)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]string{
+		"title":        m.Title(),
+		"artist":       m.Artist(),
+		"album":        m.Album(),
+		"album_artist": m.AlbumArtist(),
+		"composer":     m.Composer(),
+		"genre":        m.Genre(),
+	}
+
+	if year := m.Year(); year > 0 {
+		res["year"] = strconv.Itoa(year)
+	}
+
+	if track, _ := m.Track(); track > 0 {
+		res["track"] = strconv.Itoa(track)
+	}
+
+	return res, nil
+}
+
+func (tagExtractor) Enabled() bool {
+	return true
+}
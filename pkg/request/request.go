@@ -76,6 +76,17 @@ func (c *HTTPClient) Request(method, target string, body io.Reader, opts ...Opti
 		err error
 	)
 
+	if options.ctx != nil {
+		req, err = http.NewRequestWithContext(options.ctx, method, target, body)
+	} else {
+		req, err = http.NewRequest(method, target, body)
+	}
+	if err != nil {
+		return &Response{
+			Err: err,
+		}
+	}
+
 	if options.header != nil {
 		for k, v := range options.header {
 			req.Header.Add(k, strings.Join(v, " "))
@@ -101,7 +112,7 @@ func (c *HTTPClient) Request(method, target string, body io.Reader, opts ...Opti
 		case "PUT", "POST", "PATCH":
 			auth.SignRequest(options.sign, req, options.signTTL)
 		default:
-			if resURL, err := auth.SignURI(options.sign, req.URL.String(), options.signTTL); err != nil {
+			if resURL, err := auth.SignURI(options.sign, req.URL.String(), options.signTTL); err == nil {
 				req.URL = resURL
 			}
 		}
@@ -135,11 +146,19 @@ func (resp *Response) CheckHTTPResponse(status int) *Response {
 		return resp
 	}
 	if resp.Response.StatusCode != status {
-		resp.Err = fmt.Errorf("server returns abnormal HTTP status%s", resp.Response.StatusCode)
+		resp.Err = fmt.Errorf("server returns abnormal HTTP status %d", resp.Response.StatusCode)
 	}
 	return resp
 }
 
+func (resp *Response) GetRSCloser() (*RequestSeeker, error) {
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+
+	return newRequestSeeker(resp.Response), nil
+}
+
 func (resp *Response) DecodeResponse() (*serializer.Response, error) {
 	if resp.Err != nil {
 		return nil, resp.Err
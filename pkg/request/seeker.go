@@ -0,0 +1,153 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidSeekOffset = errors.New("invalid seek offset")
+	ErrRangeNotSupported = errors.New("remote server does not support range requests")
+)
+
+// RequestSeeker 将 HTTP GET 响应包装为 RSCloser，Seek 后通过 Range 请求重新读取
+type RequestSeeker struct {
+	client *http.Client
+	req    *http.Request
+	body   io.ReadCloser
+	offset int64
+	size   int64
+}
+
+func newRequestSeeker(resp *http.Response) *RequestSeeker {
+	seeker := &RequestSeeker{
+		client: &http.Client{},
+		req:    resp.Request,
+		body:   resp.Body,
+		size:   -1,
+	}
+
+	if resp.StatusCode == http.StatusPartialContent {
+		if start, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok {
+			seeker.offset = start
+			seeker.size = total
+		}
+	} else if resp.ContentLength >= 0 {
+		seeker.size = resp.ContentLength
+	}
+	return seeker
+}
+
+// SetContentLength 在远端未返回长度时手动指定文件大小
+func (r *RequestSeeker) SetContentLength(size int64) {
+	r.size = size
+}
+
+func (r *RequestSeeker) Read(p []byte) (int, error) {
+	if r.size >= 0 && r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *RequestSeeker) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = r.offset + offset
+	case io.SeekEnd:
+		if r.size < 0 {
+			return 0, ErrRangeNotSupported
+		}
+		target = r.size + offset
+	default:
+		return 0, ErrInvalidSeekOffset
+	}
+
+	if target < 0 {
+		return 0, ErrInvalidSeekOffset
+	}
+
+	if target != r.offset {
+		r.closeBody()
+		r.offset = target
+	}
+	return target, nil
+}
+
+func (r *RequestSeeker) Close() error {
+	r.closeBody()
+	return nil
+}
+
+func (r *RequestSeeker) closeBody() {
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+}
+
+// open 从当前偏移处发起新的 Range 请求
+func (r *RequestSeeker) open() error {
+	if r.req == nil {
+		return ErrRangeNotSupported
+	}
+
+	req := r.req.Clone(r.req.Context())
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if r.offset != 0 {
+			resp.Body.Close()
+			return ErrRangeNotSupported
+		}
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("server returns abnormal HTTP status %d", resp.StatusCode)
+	}
+
+	r.body = resp.Body
+	return nil
+}
+
+// parseContentRange 解析形如 bytes 0-99/1000 的 Content-Range 头
+func parseContentRange(header string) (int64, int64, bool) {
+	header = strings.TrimPrefix(header, "bytes ")
+	parts := strings.SplitN(header, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	startEnd := strings.SplitN(parts[0], "-", 2)
+	start, err := strconv.ParseInt(startEnd[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	total, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return start, -1, true
+	}
+	return start, total, true
+}
@@ -0,0 +1,179 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/utils"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrNotSupported 文件类型不支持提取文本
+	ErrNotSupported = errors.New("file type is not supported for text extraction")
+)
+
+// extractor 从文件内容中提取纯文本
+type extractor func(ctx context.Context, file io.ReaderAt, size int64, name string) (string, error)
+
+// textExtensions 可直接作为纯文本索引的文件类型
+var textExtensions = []string{"txt", "md", "markdown", "csv", "log", "json", "xml", "yaml", "yml", "toml", "ini",
+	"conf", "html", "htm", "css", "sql", "sh", "bat", "ps1", "go", "py", "js", "ts", "jsx", "tsx", "java", "kt",
+	"c", "h", "cpp", "hpp", "cc", "cs", "rb", "php", "rs", "swift", "lua", "vue", "scala", "pl"}
+
+var extractors = map[string]extractor{
+	"pdf":  extractPDF,
+	"docx": extractOffice("word/document.xml", "word/header", "word/footer"),
+	"xlsx": extractOffice("xl/sharedStrings.xml"),
+	"pptx": extractOffice("ppt/slides/slide"),
+}
+
+func init() {
+	for _, ext := range textExtensions {
+		extractors[ext] = extractText
+	}
+}
+
+// Supported 文件类型是否支持提取文本
+func Supported(name string) bool {
+	_, ok := getExtractor(name)
+	return ok
+}
+
+func getExtractor(name string) (extractor, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return nil, false
+	}
+
+	res, ok := extractors[ext[1:]]
+	if ok && ext == ".pdf" && !models.IsTrueVal(models.GetSettingByName("search_pdf_enabled")) {
+		return nil, false
+	}
+	return res, ok
+}
+
+// Extract 提取文件中的纯文本，超出大小限制的文件不会被处理
+func Extract(ctx context.Context, file io.ReaderAt, size int64, name string) (string, error) {
+	extract, ok := getExtractor(name)
+	if !ok {
+		return "", ErrNotSupported
+	}
+
+	if maxSize := int64(models.GetIntSetting("search_content_max_size", 10<<20)); maxSize > 0 && size > maxSize {
+		return "", fmt.Errorf("file size %d exceeds the limit %d", size, maxSize)
+	}
+
+	return extract(ctx, file, size, name)
+}
+
+// extractText 读取纯文本文件，非 UTF-8 内容视为二进制文件
+func extractText(ctx context.Context, file io.ReaderAt, size int64, name string) (string, error) {
+	content, err := ioutil.ReadAll(io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return "", err
+	}
+
+	if !utf8.Valid(content) {
+		return "", errors.New("content is not valid UTF-8 text")
+	}
+	return string(content), nil
+}
+
+// extractPDF 使用 pdftotext 提取 PDF 中的文本
+func extractPDF(ctx context.Context, file io.ReaderAt, size int64, name string) (string, error) {
+	tempPath := filepath.Join(utils.RelativePath(models.GetSettingByName("temp_path")), "search")
+	input, err := utils.CreateTempFile(tempPath, "search_*.pdf", io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(input)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, models.GetSettingByNameWithDefault("search_pdf_path", "pdftotext"),
+		"-enc", "UTF-8", "-q", input, "-")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to execute pdftotext: %w, %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// extractOffice 提取 Office Open XML 文档中以 prefixes 开头的 XML 部件内的文本
+func extractOffice(prefixes ...string) extractor {
+	return func(ctx context.Context, file io.ReaderAt, size int64, name string) (string, error) {
+		reader, err := zip.NewReader(file, size)
+		if err != nil {
+			return "", err
+		}
+
+		parts := make([]*zip.File, 0)
+		for _, f := range reader.File {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(f.Name, prefix) && strings.HasSuffix(f.Name, ".xml") {
+					parts = append(parts, f)
+					break
+				}
+			}
+		}
+
+		// 幻灯片等部件按文件名顺序排列
+		sort.Slice(parts, func(i, j int) bool {
+			return parts[i].Name < parts[j].Name
+		})
+
+		var res strings.Builder
+		for _, part := range parts {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+
+			if err := extractXMLText(part, &res); err != nil {
+				return "", err
+			}
+		}
+		return res.String(), nil
+	}
+}
+
+// extractXMLText 读取 XML 部件中的字符数据，段落之间以换行分隔
+func extractXMLText(part *zip.File, res *strings.Builder) error {
+	content, err := part.Open()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	decoder := xml.NewDecoder(content)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			res.Write(t)
+		case xml.EndElement:
+			// 段落（Word、PowerPoint）及共享字符串（Excel）结束时换行
+			if t.Name.Local == "p" || t.Name.Local == "si" {
+				res.WriteString("\n")
+			}
+		}
+	}
+}
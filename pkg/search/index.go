@@ -0,0 +1,169 @@
+package search
+
+import (
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// document 索引中的文档，文档 ID 为文件 ID
+type document struct {
+	User    string `json:"user"`
+	Content string `json:"content"`
+}
+
+// Hit 搜索结果
+type Hit struct {
+	FileID    uint
+	Fragments []string
+}
+
+var (
+	mu    sync.Mutex
+	index bleve.Index
+)
+
+// Enabled 是否启用了全文搜索
+func Enabled() bool {
+	return models.IsTrueVal(models.GetSettingByName("search_content_enabled"))
+}
+
+func indexPath() string {
+	return utils.RelativePath(models.GetSettingByNameWithDefault("search_index_path", "search.bleve"))
+}
+
+func newMapping() mapping.IndexMapping {
+	userField := bleve.NewTextFieldMapping()
+	userField.Analyzer = keyword.Name
+	userField.Store = false
+	userField.IncludeInAll = false
+
+	// 内容需保存以生成高亮片段，使用 CJK 分词以支持中日韩文本
+	contentField := bleve.NewTextFieldMapping()
+	contentField.Analyzer = cjk.AnalyzerName
+	contentField.Store = true
+	contentField.IncludeTermVectors = true
+
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt("user", userField)
+	doc.AddFieldMappingsAt("content", contentField)
+
+	res := bleve.NewIndexMapping()
+	res.DefaultMapping = doc
+	return res
+}
+
+// getIndex 打开索引，索引不存在时新建
+func getIndex() (bleve.Index, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if index != nil {
+		return index, nil
+	}
+
+	path := indexPath()
+	res, err := bleve.Open(path)
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		res, err = bleve.New(path, newMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index = res
+	return index, nil
+}
+
+// Index 写入或更新文件的索引
+func Index(file *models.File, content string) error {
+	idx, err := getIndex()
+	if err != nil {
+		return err
+	}
+
+	return idx.Index(docID(file.ID), document{
+		User:    strconv.FormatUint(uint64(file.UserID), 10),
+		Content: content,
+	})
+}
+
+// Delete 删除文件的索引
+func Delete(fileIDs []uint) error {
+	if !Enabled() || len(fileIDs) == 0 {
+		return nil
+	}
+
+	idx, err := getIndex()
+	if err != nil {
+		return err
+	}
+
+	batch := idx.NewBatch()
+	for _, id := range fileIDs {
+		batch.Delete(docID(id))
+	}
+	return idx.Batch(batch)
+}
+
+// Search 在用户的文件中搜索内容，返回按相关度排列的文件及高亮片段，from 为跳过的结果数
+func Search(uid uint, keywords string, size, from int) ([]Hit, error) {
+	idx, err := getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	userQuery := bleve.NewTermQuery(strconv.FormatUint(uint64(uid), 10))
+	userQuery.SetField("user")
+	contentQuery := bleve.NewMatchQuery(keywords)
+	contentQuery.SetField("content")
+
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(userQuery, contentQuery), size, from, false)
+	req.Highlight = bleve.NewHighlightWithStyle(html.Name)
+	req.Highlight.AddField("content")
+
+	result, err := idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(result.Hits))
+	for _, match := range result.Hits {
+		id, err := strconv.ParseUint(match.ID, 10, 64)
+		if err != nil {
+			continue
+		}
+		hits = append(hits, Hit{
+			FileID:    uint(id),
+			Fragments: match.Fragments["content"],
+		})
+	}
+	return hits, nil
+}
+
+// Reset 清空索引，用于重建索引
+func Reset() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if index != nil {
+		if err := index.Close(); err != nil {
+			logrus.Warningf("Unable to close search index, %s", err)
+		}
+		index = nil
+	}
+
+	return os.RemoveAll(indexPath())
+}
+
+func docID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"github.com/blevesearch/bleve/v2"
+	"github.com/jylc/cloudserver/models"
+	"testing"
+)
+
+// useMemIndex 使用内存中的索引，测试结束后恢复
+func useMemIndex(t *testing.T) {
+	idx, err := bleve.NewMemOnly(newMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	origin := index
+	index = idx
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		index = origin
+		mu.Unlock()
+		idx.Close()
+	})
+}
+
+func TestSearch(t *testing.T) {
+	useMemIndex(t)
+	for id := uint(1); id <= 5; id++ {
+		file := &models.File{UserID: 1, Name: "a.txt"}
+		file.ID = id
+		if err := Index(file, "hello world"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := &models.File{UserID: 2}
+	other.ID = 6
+	if err := Index(other, "hello world"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 分页读取覆盖用户的全部结果，且不包含其他用户的文件
+	seen := make(map[uint]bool)
+	for from := 0; from < 6; from += 2 {
+		hits, err := Search(1, "hello", 2, from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, hit := range hits {
+			if seen[hit.FileID] {
+				t.Errorf("file %d returned twice", hit.FileID)
+			}
+			seen[hit.FileID] = true
+		}
+	}
+	if len(seen) != 5 || seen[6] {
+		t.Errorf("hits = %v", seen)
+	}
+
+	hits, err := Search(1, "hello", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 5 || len(hits[0].Fragments) == 0 {
+		t.Errorf("hits = %+v", hits)
+	}
+}
@@ -0,0 +1,222 @@
+package search
+
+import (
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query 解析后的结构化搜索语句，如
+// name:*.psd size:>100MB modified:<2024-01-01 in:/Projects tag:urgent
+type Query struct {
+	Filter models.FileFilter
+	// In 限定搜索的目录路径，包含子目录
+	In string
+	// Tags 需满足的标签名称
+	Tags []string
+}
+
+// sizeUnits 文件大小单位
+var sizeUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+const dateLayout = "2006-01-02"
+
+// ParseQuery 解析结构化搜索语句，不带字段名的词作为文件名关键字
+func ParseQuery(raw string) (*Query, error) {
+	tokens, err := tokenize(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &Query{}
+	for _, token := range tokens {
+		key, value := "", token
+		if i := strings.Index(token, ":"); i > 0 {
+			key, value = strings.ToLower(token[:i]), token[i+1:]
+		}
+
+		if value == "" {
+			return nil, fmt.Errorf("empty value for %q", key)
+		}
+
+		switch key {
+		case "":
+			query.Filter.Keywords = append(query.Filter.Keywords, value)
+		case "name":
+			query.Filter.Names = append(query.Filter.Names, GlobToLike(value))
+		case "ext":
+			query.Filter.Names = append(query.Filter.Names, "%."+strings.TrimPrefix(value, "."))
+		case "size":
+			if err := parseSize(value, &query.Filter.MinSize, &query.Filter.MaxSize); err != nil {
+				return nil, err
+			}
+		case "modified":
+			if err := parseDate(value, &query.Filter.UpdatedAfter, &query.Filter.UpdatedBefore); err != nil {
+				return nil, err
+			}
+		case "created":
+			if err := parseDate(value, &query.Filter.CreatedAfter, &query.Filter.CreatedBefore); err != nil {
+				return nil, err
+			}
+		case "in":
+			query.In = value
+		case "tag":
+			query.Tags = append(query.Tags, value)
+		default:
+			// 未知字段视为包含冒号的关键字
+			query.Filter.Keywords = append(query.Filter.Keywords, token)
+		}
+	}
+
+	return query, nil
+}
+
+// GlobToLike 将通配符 * 与 ? 转换为 LIKE 模式
+func GlobToLike(glob string) string {
+	return strings.NewReplacer("*", "%", "?", "_").Replace(glob)
+}
+
+// tokenize 按空白切分语句，双引号内的空白不切分
+func tokenize(raw string) ([]string, error) {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+	)
+
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	return tokens, nil
+}
+
+// splitRange 拆分比较运算符或 a..b 形式的范围
+func splitRange(value string) (op, from, to string) {
+	if i := strings.Index(value, ".."); i >= 0 {
+		return "..", value[:i], value[i+2:]
+	}
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, candidate) {
+			return candidate, value[len(candidate):], ""
+		}
+	}
+	return "=", value, ""
+}
+
+// parseSize 解析大小条件，如 >100MB、<=1G、1MB..10MB
+func parseSize(value string, min, max **uint64) error {
+	op, from, to := splitRange(value)
+	a, err := parseSizeValue(from)
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case ">":
+		a++
+		*min = &a
+	case ">=":
+		*min = &a
+	case "<":
+		if a == 0 {
+			return fmt.Errorf("invalid size condition %q", value)
+		}
+		a--
+		*max = &a
+	case "<=":
+		*max = &a
+	case "=":
+		b := a
+		*min, *max = &a, &b
+	case "..":
+		b, err := parseSizeValue(to)
+		if err != nil {
+			return err
+		}
+		*min, *max = &a, &b
+	}
+	return nil
+}
+
+func parseSizeValue(value string) (uint64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	unit := uint64(1)
+	for _, candidate := range sizeUnits {
+		if strings.HasSuffix(value, candidate.suffix) {
+			value, unit = strings.TrimSuffix(value, candidate.suffix), candidate.size
+			break
+		}
+	}
+
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return uint64(size * float64(unit)), nil
+}
+
+// parseDate 解析日期条件，日期按天计算，如 <2024-01-01、>=2023-06-01、2024-01-01..2024-01-31
+func parseDate(value string, after, before **time.Time) error {
+	op, from, to := splitRange(value)
+	start, err := time.ParseInLocation(dateLayout, from, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid date %q", from)
+	}
+	nextDay := start.AddDate(0, 0, 1)
+
+	switch op {
+	case ">":
+		*after = &nextDay
+	case ">=":
+		*after = &start
+	case "<":
+		*before = &start
+	case "<=":
+		*before = &nextDay
+	case "=":
+		*after, *before = &start, &nextDay
+	case "..":
+		end, err := time.ParseInLocation(dateLayout, to, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid date %q", to)
+		}
+		end = end.AddDate(0, 0, 1)
+		*after, *before = &start, &end
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	query, err := ParseQuery(`report name:*.psd ext:.png "in:/My Projects" tag:urgent foo:bar`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(query.Filter.Keywords, []string{"report", "foo:bar"}) {
+		t.Errorf("keywords = %q", query.Filter.Keywords)
+	}
+	if !reflect.DeepEqual(query.Filter.Names, []string{"%.psd", "%.png"}) {
+		t.Errorf("names = %q", query.Filter.Names)
+	}
+	if query.In != "/My Projects" || !reflect.DeepEqual(query.Tags, []string{"urgent"}) {
+		t.Errorf("in = %q, tags = %q", query.In, query.Tags)
+	}
+
+	for _, raw := range []string{"", "   ", `"unterminated`, "name:", "size:abc", "modified:2024-13-01"} {
+		if _, err := ParseQuery(raw); err == nil {
+			t.Errorf("ParseQuery(%q) should fail", raw)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	size := func(v uint64) *uint64 { return &v }
+	testCases := []struct {
+		value    string
+		min, max *uint64
+		err      bool
+	}{
+		{">100MB", size(100<<20 + 1), nil, false},
+		{">=1k", size(1 << 10), nil, false},
+		{"<1G", nil, size(1<<30 - 1), false},
+		{"<=1.5KB", nil, size(1536), false},
+		{"=10", size(10), size(10), false},
+		{"2B", size(2), size(2), false},
+		{"1MB..10MB", size(1 << 20), size(10 << 20), false},
+		{"<0", nil, nil, true},
+		{">-1", nil, nil, true},
+		{"1MB..x", nil, nil, true},
+		{"MB", nil, nil, true},
+	}
+
+	for _, testCase := range testCases {
+		var min, max *uint64
+		err := parseSize(testCase.value, &min, &max)
+		if (err != nil) != testCase.err {
+			t.Errorf("parseSize(%q) err = %v", testCase.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(min, testCase.min) || !reflect.DeepEqual(max, testCase.max) {
+			t.Errorf("parseSize(%q) = %v, %v", testCase.value, min, max)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	day := func(y int, m time.Month, d int) *time.Time {
+		res := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+		return &res
+	}
+	testCases := []struct {
+		value         string
+		after, before *time.Time
+		err           bool
+	}{
+		{">2024-01-01", day(2024, 1, 2), nil, false},
+		{">=2024-01-01", day(2024, 1, 1), nil, false},
+		{"<2024-01-01", nil, day(2024, 1, 1), false},
+		{"<=2024-01-31", nil, day(2024, 2, 1), false},
+		{"2024-02-29", day(2024, 2, 29), day(2024, 3, 1), false},
+		{"2024-01-01..2024-01-31", day(2024, 1, 1), day(2024, 2, 1), false},
+		{"2024/01/01", nil, nil, true},
+		{"2024-01-01..", nil, nil, true},
+	}
+
+	for _, testCase := range testCases {
+		var after, before *time.Time
+		err := parseDate(testCase.value, &after, &before)
+		if (err != nil) != testCase.err {
+			t.Errorf("parseDate(%q) err = %v", testCase.value, err)
+			continue
+		}
+		if !equalTime(after, testCase.after) || !equalTime(before, testCase.before) {
+			t.Errorf("parseDate(%q) = %v, %v", testCase.value, after, before)
+		}
+	}
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
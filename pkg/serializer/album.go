@@ -0,0 +1,58 @@
+package serializer
+
+import (
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"time"
+)
+
+type albumItem struct {
+	ID   string    `json:"id"`
+	Name string    `json:"name"`
+	Date time.Time `json:"date"`
+}
+
+// TimelineBucket 时间线中同一天的照片
+type TimelineBucket struct {
+	Date    string   `json:"date"`
+	Objects []Object `json:"objects"`
+}
+
+// BuildAlbum 构建相册信息
+func BuildAlbum(album *models.Album) albumItem {
+	return albumItem{
+		ID:   hashid.HashID(album.ID, hashid.AlbumID),
+		Name: album.Name,
+		Date: album.CreatedAt,
+	}
+}
+
+// BuildAlbumList 构建相册列表响应
+func BuildAlbumList(albums []models.Album) Response {
+	res := make([]albumItem, 0, len(albums))
+	for i := range albums {
+		res = append(res, BuildAlbum(&albums[i]))
+	}
+	return Response{Data: res}
+}
+
+// BuildAlbumObjects 构建相册文件列表响应
+func BuildAlbumObjects(album *models.Album, objects []Object, total int) Response {
+	return Response{
+		Data: map[string]interface{}{
+			"album":   BuildAlbum(album),
+			"total":   total,
+			"objects": objects,
+		},
+	}
+}
+
+// BuildTimeline 构建照片时间线响应
+func BuildTimeline(buckets []TimelineBucket, total int) Response {
+	return Response{
+		Data: map[string]interface{}{
+			"total":   total,
+			"buckets": buckets,
+		},
+	}
+}
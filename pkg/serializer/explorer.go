@@ -17,6 +17,34 @@ type Object struct {
 	CreateDate    time.Time `json:"create_date"`
 	Key           string    `json:"key,omitempty"`
 	SourceEnabled bool      `json:"source_enabled"`
+	// Highlights 全文搜索命中的内容片段
+	Highlights []string `json:"highlights,omitempty"`
+	// Tags 对象的手动标签
+	Tags []ObjectTag `json:"tags,omitempty"`
+}
+
+// ObjectTag 对象的手动标签
+type ObjectTag struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+// BuildObjectTags 构建对象的手动标签列表
+func BuildObjectTags(tags []models.Tag) []ObjectTag {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	res := make([]ObjectTag, 0, len(tags))
+	for _, tag := range tags {
+		res = append(res, ObjectTag{
+			ID:    hashid.HashID(tag.ID, hashid.TagID),
+			Name:  tag.Name,
+			Color: tag.Color,
+		})
+	}
+	return res
 }
 
 type ObjectList struct {
@@ -41,6 +69,8 @@ type ObjectProps struct {
 	ChildFolderNum int       `json:"child_folder_num"`
 	ChildFileNum   int       `json:"child_file_num"`
 	Path           string    `json:"path"`
+	// Metadata 文件的 EXIF 及媒体元数据
+	Metadata map[string]string `json:"metadata,omitempty"`
 
 	QueryDate time.Time `json:"query_date"`
 }
@@ -10,6 +10,7 @@ type Share struct {
 	Key        string        `json:"key"`
 	Locked     bool          `json:"locked"`
 	IsDir      bool          `json:"is_dir"`
+	IsAlbum    bool          `json:"is_album"`
 	CreateData time.Time     `json:"create_data,omitempty"`
 	Downloads  int           `json:"downloads"`
 	Views      int           `json:"views"`
@@ -33,6 +34,7 @@ type shareSource struct {
 type myShareItem struct {
 	Key             string       `json:"key"`
 	IsDir           bool         `json:"is_dir"`
+	IsAlbum         bool         `json:"is_album"`
 	Password        string       `json:"password"`
 	CreateDate      time.Time    `json:"create_date,omitempty"`
 	Downloads       int          `json:"downloads"`
@@ -50,6 +52,7 @@ func BuildShareList(shares []models.Share, total int) Response {
 		item := myShareItem{
 			Key:             hashid.HashID(shares[i].ID, hashid.ShareID),
 			IsDir:           shares[i].IsDir,
+			IsAlbum:         shares[i].IsAlbum,
 			Password:        shares[i].Password,
 			CreateDate:      shares[i].CreatedAt,
 			Downloads:       shares[i].Downloads,
@@ -66,7 +69,11 @@ func BuildShareList(shares []models.Share, total int) Response {
 			}
 		}
 
-		if shares[i].File.ID != 0 {
+		if shares[i].Album.ID != 0 {
+			item.Source = &shareSource{
+				Name: shares[i].Album.Name,
+			}
+		} else if shares[i].File.ID != 0 {
 			item.Source = &shareSource{
 				Name: shares[i].File.Name,
 				Size: shares[i].File.Size,
@@ -104,6 +111,7 @@ func BuildShareResponse(share *models.Share, unlocked bool) Share {
 	}
 
 	resp.IsDir = share.IsDir
+	resp.IsAlbum = share.IsAlbum
 	resp.Downloads = share.Downloads
 	resp.Views = share.Views
 	resp.Preview = share.PreviewEnabled
@@ -111,7 +119,13 @@ func BuildShareResponse(share *models.Share, unlocked bool) Share {
 		resp.Expire = share.Expires.Unix() - time.Now().Unix()
 	}
 
-	if share.IsDir {
+	if share.IsAlbum {
+		source := share.SourceAlbum()
+		resp.Source = &shareSource{
+			Name: source.Name,
+			Size: 0,
+		}
+	} else if share.IsDir {
 		source := share.SourceFolder()
 		resp.Source = &shareSource{
 			Name: source.Name,
@@ -31,6 +31,17 @@ func (s *SlaveTransferReq) Hash(id string) string {
 	return fmt.Sprintf("%x", bs)
 }
 
+// RemoteDeleteRequest 主机请求从机删除文件
+type RemoteDeleteRequest struct {
+	Files []string `json:"files"`
+}
+
+// ListRequest 主机请求从机列取文件
+type ListRequest struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive"`
+}
+
 const (
 	SlaveTransferSuccess = "success"
 	SlaveTransferFailed  = "failed"
@@ -0,0 +1,42 @@
+package serializer
+
+import (
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"time"
+)
+
+type trashItem struct {
+	ID   string    `json:"id"`
+	Name string    `json:"name"`
+	Path string    `json:"path"`
+	Type string    `json:"type"`
+	Size uint64    `json:"size"`
+	Date time.Time `json:"date"`
+}
+
+// BuildTrashList 构建回收站列表响应
+func BuildTrashList(trashes []models.Trash, total int) Response {
+	res := make([]trashItem, 0, len(trashes))
+	for _, trash := range trashes {
+		item := trashItem{
+			ID:   hashid.HashID(trash.ID, hashid.TrashID),
+			Name: trash.Name,
+			Path: trash.Path,
+			Type: "file",
+			Size: trash.Size,
+			Date: trash.CreatedAt,
+		}
+		if trash.IsFolder {
+			item.Type = "dir"
+		}
+		res = append(res, item)
+	}
+
+	return Response{
+		Data: map[string]interface{}{
+			"total": total,
+			"items": res,
+		},
+	}
+}
@@ -20,6 +20,14 @@ type UploadSession struct {
 	UploadURL      string
 	UploadID       string
 	Credential     string
+	// Digest 完整文件的校验和，最后一个分片上传完成后校验
+	Digest string
+	// SHA256 客户端声明的内容哈希，非空时分片只计算哈希不写入，完成后引用已有的相同内容物理文件
+	SHA256 string
+	// HashState 已按顺序上传的分片的 SHA-256 中间状态
+	HashState []byte
+	// HashedSize 已计算哈希的内容长度
+	HashedSize uint64
 }
 
 type UploadCredential struct {
@@ -35,6 +43,8 @@ type UploadCredential struct {
 	KeyTime     string   `json:"keyTime,omitempty"`
 	Policy      string   `json:"policy,omitempty"`
 	CompleteURL string   `json:"completeURL,omitempty"`
+	// Instant 已通过秒传完成上传，无需传输文件内容
+	Instant bool `json:"instant,omitempty"`
 }
 
 type UploadCallback struct {
@@ -0,0 +1,26 @@
+package serializer
+
+import (
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"time"
+)
+
+type versionItem struct {
+	ID   string    `json:"id"`
+	Size uint64    `json:"size"`
+	Date time.Time `json:"date"`
+}
+
+// BuildVersionList 构建文件历史版本列表响应
+func BuildVersionList(versions []models.FileVersion) Response {
+	res := make([]versionItem, 0, len(versions))
+	for _, version := range versions {
+		res = append(res, versionItem{
+			ID:   hashid.HashID(version.ID, hashid.VersionID),
+			Size: version.Size,
+			Date: version.CreatedAt,
+		})
+	}
+	return Response{Data: res}
+}
@@ -0,0 +1,150 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/sirupsen/logrus"
+	"sort"
+)
+
+// FileDeleteTask 在后台永久删除多个用户的文件
+type FileDeleteTask struct {
+	User      *models.User
+	TaskModel *models.Task
+	TaskProps FileDeleteProps
+	Err       *JobError
+}
+
+// FileDeleteProps 文件删除任务属性
+type FileDeleteProps struct {
+	// Files 各用户待删除的文件 ID
+	Files map[uint][]uint `json:"files"`
+	// LastUser 已处理的最后一个用户 ID，用于恢复任务
+	LastUser uint `json:"last_user"`
+	// Failed 删除失败的用户数量
+	Failed int `json:"failed"`
+}
+
+func (job *FileDeleteTask) Type() int {
+	return FileDeleteTaskType
+}
+
+func (job *FileDeleteTask) Creator() uint {
+	return job.User.ID
+}
+
+func (job *FileDeleteTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+func (job *FileDeleteTask) Model() *models.Task {
+	return job.TaskModel
+}
+
+func (job *FileDeleteTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+func (job *FileDeleteTask) Do() {
+	ctx := context.Background()
+
+	uids := make([]uint, 0, len(job.TaskProps.Files))
+	for uid := range job.TaskProps.Files {
+		if uid > job.TaskProps.LastUser {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	job.TaskModel.SetProgress(DeletingProgress)
+	for _, uid := range uids {
+		if err := job.delete(ctx, uid); err != nil {
+			logrus.Warningf("Unable to delete files of user [%d],%s", uid, err)
+			job.TaskProps.Failed++
+		}
+
+		job.TaskProps.LastUser = uid
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg("Some files failed to be deleted, please check the log", nil)
+	}
+}
+
+// delete 删除单个用户的文件，已不存在的文件会被忽略
+func (job *FileDeleteTask) delete(ctx context.Context, uid uint) error {
+	user, err := models.GetUserByID(uid)
+	if err != nil {
+		return err
+	}
+
+	fs, err := filesystem.NewFileSystem(&user)
+	if err != nil {
+		return err
+	}
+	defer fs.Recycle()
+
+	return fs.Delete(ctx, []uint{}, job.TaskProps.Files[uid], false)
+}
+
+func (job *FileDeleteTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+func (job *FileDeleteTask) GetError() *JobError {
+	return job.Err
+}
+
+func (job *FileDeleteTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// NewFileDeleteTask 新建文件删除任务，files 为各用户待删除的文件 ID
+func NewFileDeleteTask(user uint, files map[uint][]uint) (Job, error) {
+	creator, err := models.GetActivateUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &FileDeleteTask{
+		User: &creator,
+		TaskProps: FileDeleteProps{
+			Files: files,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+	return newTask, nil
+}
+
+// NewFileDeleteTaskFromModel 从数据库记录中恢复文件删除任务
+func NewFileDeleteTaskFromModel(task *models.Task) (Job, error) {
+	user, err := models.GetActivateUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &FileDeleteTask{
+		User:      &user,
+		TaskModel: task,
+	}
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+	return newTask, nil
+}
@@ -0,0 +1,164 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/search"
+	"github.com/sirupsen/logrus"
+)
+
+// indexBatchSize 每批处理的文件数量
+const indexBatchSize = 100
+
+// IndexRebuildTask 清空全文索引并为所有已有文件重新建立索引
+type IndexRebuildTask struct {
+	User      *models.User
+	TaskModel *models.Task
+	TaskProps IndexRebuildProps
+	Err       *JobError
+}
+
+// IndexRebuildProps 全文索引重建任务属性
+type IndexRebuildProps struct {
+	// LastID 已处理的最后一个文件 ID，用于恢复任务
+	LastID uint `json:"last_id"`
+	// Indexed 已建立索引的文件数量
+	Indexed int `json:"indexed"`
+	// Failed 建立索引失败的文件数量
+	Failed int `json:"failed"`
+}
+
+func (job *IndexRebuildTask) Type() int {
+	return IndexRebuildTaskType
+}
+
+func (job *IndexRebuildTask) Creator() uint {
+	return job.User.ID
+}
+
+func (job *IndexRebuildTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+func (job *IndexRebuildTask) Model() *models.Task {
+	return job.TaskModel
+}
+
+func (job *IndexRebuildTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+func (job *IndexRebuildTask) Do() {
+	ctx := context.Background()
+
+	if !search.Enabled() {
+		job.SetErrorMsg("Full-text search is not enabled", nil)
+		return
+	}
+
+	// 恢复的任务沿用已建立的部分索引
+	if job.TaskProps.LastID == 0 {
+		if err := search.Reset(); err != nil {
+			job.SetErrorMsg("Unable to clear search index", err)
+			return
+		}
+	}
+
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg(err.Error(), nil)
+		return
+	}
+	defer fs.Recycle()
+
+	job.TaskModel.SetProgress(IndexingProgress)
+	for {
+		files, err := models.GetFilesAfter(job.TaskProps.LastID, indexBatchSize)
+		if err != nil {
+			job.SetErrorMsg("Unable to list files", err)
+			return
+		}
+
+		if len(files) == 0 {
+			break
+		}
+
+		for i := range files {
+			if !search.Supported(files[i].Name) {
+				continue
+			}
+
+			if err := fs.IndexContent(ctx, &files[i]); err != nil {
+				logrus.Warningf("Unable to index content of [%s], %s", files[i].Name, err)
+				job.TaskProps.Failed++
+				continue
+			}
+			job.TaskProps.Indexed++
+		}
+
+		job.TaskProps.LastID = files[len(files)-1].ID
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg("Some files failed to be indexed, please check the log", nil)
+	}
+}
+
+func (job *IndexRebuildTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+func (job *IndexRebuildTask) GetError() *JobError {
+	return job.Err
+}
+
+func (job *IndexRebuildTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// NewIndexRebuildTask 新建全文索引重建任务
+func NewIndexRebuildTask(user uint) (Job, error) {
+	creator, err := models.GetActivateUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &IndexRebuildTask{
+		User: &creator,
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+	return newTask, nil
+}
+
+// NewIndexRebuildTaskFromModel 从数据库记录中恢复全文索引重建任务
+func NewIndexRebuildTaskFromModel(task *models.Task) (Job, error) {
+	user, err := models.GetActivateUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &IndexRebuildTask{
+		User:      &user,
+		TaskModel: task,
+	}
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+	return newTask, nil
+}
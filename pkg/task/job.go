@@ -15,6 +15,22 @@ const (
 	TransferTaskType
 	// ImportTaskType 导入任务
 	ImportTaskType
+	// KeyRotateTaskType 加密密钥轮换任务
+	KeyRotateTaskType
+	// IndexRebuildTaskType 全文索引重建任务
+	IndexRebuildTaskType
+	// ScrubTaskType 存储巡检任务
+	ScrubTaskType
+	// ReconcileTaskType 存储对账任务
+	ReconcileTaskType
+	// StorageRecalcTaskType 容量重新计算任务
+	StorageRecalcTaskType
+	// PolicyMigrateTaskType 存储策略迁移任务
+	PolicyMigrateTaskType
+	// FileDeleteTaskType 文件删除任务
+	FileDeleteTaskType
+	// MirrorRepairTaskType 镜像副本修复任务
+	MirrorRepairTaskType
 )
 
 // 任务状态
@@ -47,6 +63,22 @@ const (
 	ListingProgress
 	// InsertingProgress 插入中
 	InsertingProgress
+	// EncryptingProgress 重新加密中
+	EncryptingProgress
+	// IndexingProgress 建立全文索引中
+	IndexingProgress
+	// ScrubbingProgress 巡检中
+	ScrubbingProgress
+	// ReconcilingProgress 对账中
+	ReconcilingProgress
+	// RecalculatingProgress 重新计算容量中
+	RecalculatingProgress
+	// MigratingProgress 迁移中
+	MigratingProgress
+	// DeletingProgress 删除中
+	DeletingProgress
+	// ReplicatingProgress 复制副本中
+	ReplicatingProgress
 )
 
 type Job interface {
@@ -108,6 +140,22 @@ func GetJobFromModel(task *models.Task) (Job, error) {
 		return NewTransferTaskFromModel(task)
 	case ImportTaskType:
 		return NewImportTaskFromModel(task)
+	case KeyRotateTaskType:
+		return NewKeyRotateTaskFromModel(task)
+	case IndexRebuildTaskType:
+		return NewIndexRebuildTaskFromModel(task)
+	case ScrubTaskType:
+		return NewScrubTaskFromModel(task)
+	case ReconcileTaskType:
+		return NewReconcileTaskFromModel(task)
+	case StorageRecalcTaskType:
+		return NewStorageRecalcTaskFromModel(task)
+	case PolicyMigrateTaskType:
+		return NewPolicyMigrateTaskFromModel(task)
+	case FileDeleteTaskType:
+		return NewFileDeleteTaskFromModel(task)
+	case MirrorRepairTaskType:
+		return NewMirrorRepairTaskFromModel(task)
 	default:
 		return nil, ErrUnknownTaskType
 	}
@@ -0,0 +1,353 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"io"
+	"path"
+)
+
+// migrateBatchSize 每批迁移的记录数量
+const migrateBatchSize = 100
+
+// 迁移阶段
+const (
+	// migrateFiles 迁移文件记录引用的物理文件
+	migrateFiles = iota
+	// migrateVersions 迁移仅被历史版本引用的物理文件
+	migrateVersions
+)
+
+// PolicyMigrateTask 将存储策略下的物理文件逐个复制至另一存储策略，并更新引用它的文件记录及历史版本
+type PolicyMigrateTask struct {
+	User      *models.User
+	TaskModel *models.Task
+	TaskProps PolicyMigrateProps
+	Err       *JobError
+
+	src, dst driver.Handler
+}
+
+// PolicyMigrateProps 存储策略迁移任务属性
+type PolicyMigrateProps struct {
+	SrcPolicyID uint `json:"src_policy_id"`
+	DstPolicyID uint `json:"dst_policy_id"`
+	// FileIDs 仅迁移其中的文件，为空时迁移存储策略下的所有文件及历史版本
+	FileIDs []uint `json:"file_ids,omitempty"`
+	// Stage 当前迁移阶段，用于恢复任务
+	Stage int `json:"stage"`
+	// LastID 当前阶段已处理的最后一个记录 ID，用于恢复任务
+	LastID uint `json:"last_id"`
+	// Current 正在迁移的物理文件
+	Current  string `json:"current"`
+	Migrated int    `json:"migrated"`
+	// Skipped 因物理文件被其他记录共用而跳过的文件数量
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+func (job *PolicyMigrateTask) Type() int {
+	return PolicyMigrateTaskType
+}
+
+func (job *PolicyMigrateTask) Creator() uint {
+	return job.User.ID
+}
+
+func (job *PolicyMigrateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+func (job *PolicyMigrateTask) Model() *models.Task {
+	return job.TaskModel
+}
+
+func (job *PolicyMigrateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+func (job *PolicyMigrateTask) Do() {
+	ctx := context.Background()
+
+	var err error
+	if job.src, err = job.dispatch(job.TaskProps.SrcPolicyID); err != nil {
+		job.SetErrorMsg("Unable to initialize source storage policy", err)
+		return
+	}
+	if job.dst, err = job.dispatch(job.TaskProps.DstPolicyID); err != nil {
+		job.SetErrorMsg("Unable to initialize destination storage policy", err)
+		return
+	}
+
+	job.TaskModel.SetProgress(MigratingProgress)
+	for job.TaskProps.Stage <= migrateVersions {
+		sources, last, err := job.next()
+		if err != nil {
+			job.SetErrorMsg("Unable to list files", err)
+			return
+		}
+
+		if len(sources) == 0 {
+			job.TaskProps.Stage++
+			job.TaskProps.LastID = 0
+			job.TaskModel.SetProps(job.Props())
+			continue
+		}
+
+		for _, source := range sources {
+			job.TaskProps.Current = source.SourceName
+			job.TaskModel.SetProps(job.Props())
+
+			if len(job.TaskProps.FileIDs) > 0 {
+				// 仅迁移部分文件时，被其他文件或其他文件的历史版本共用的物理文件须保留在原存储策略；
+				// 仅被待迁移文件及其历史版本引用时，所有引用会一同更新
+				refs, err := models.CountSourceReferences(job.TaskProps.SrcPolicyID, source.SourceName, job.TaskProps.FileIDs)
+				if err != nil || refs > 0 {
+					job.TaskProps.Skipped++
+					continue
+				}
+			}
+
+			if err := job.migrate(ctx, source); err != nil {
+				logrus.Warningf("Unable to migrate file [%s], %s", source.SourceName, err)
+				job.TaskProps.Failed++
+				continue
+			}
+			job.TaskProps.Migrated++
+		}
+
+		job.TaskProps.LastID = last
+		job.TaskProps.Current = ""
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg("Some files failed to be migrated, please check the log", nil)
+	}
+}
+
+// dispatch 获取存储策略的处理器
+func (job *PolicyMigrateTask) dispatch(policyID uint) (driver.Handler, error) {
+	policy, err := models.GetPolicyByID(policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Recycle()
+
+	fs.Policy = &policy
+	if err := fs.DispatchHandler(); err != nil {
+		return nil, err
+	}
+	return fs.Handler, nil
+}
+
+// next 列出当前阶段下一批待迁移的物理文件，引用同一物理文件的记录只返回一次，
+// 第二个返回值为本批最后一个记录的 ID
+func (job *PolicyMigrateTask) next() ([]models.File, uint, error) {
+	var (
+		files []models.File
+		last  uint
+	)
+
+	if job.TaskProps.Stage == migrateFiles {
+		batch, err := models.GetAllFilesByPolicy(job.TaskProps.SrcPolicyID, job.TaskProps.FileIDs, job.TaskProps.LastID, migrateBatchSize)
+		if err != nil || len(batch) == 0 {
+			return nil, 0, err
+		}
+		files, last = batch, batch[len(batch)-1].ID
+	} else if len(job.TaskProps.FileIDs) == 0 {
+		versions, err := models.GetVersionsByPolicy(job.TaskProps.SrcPolicyID, job.TaskProps.LastID, migrateBatchSize)
+		if err != nil || len(versions) == 0 {
+			return nil, 0, err
+		}
+
+		for _, version := range versions {
+			file := models.File{
+				UserID:     version.UserID,
+				Size:       version.Size,
+				SourceName: version.SourceName,
+				PolicyID:   version.PolicyID,
+			}
+			files = append(files, file)
+		}
+		last = versions[len(versions)-1].ID
+	}
+
+	res := make([]models.File, 0, len(files))
+	processed := make(map[string]bool, len(files))
+	for _, file := range files {
+		if !processed[file.SourceName] {
+			processed[file.SourceName] = true
+			res = append(res, file)
+		}
+	}
+	return res, last, nil
+}
+
+// migrate 复制物理文件及其缩略图至目标存储策略，更新所有引用记录后删除原物理文件
+func (job *PolicyMigrateTask) migrate(ctx context.Context, file models.File) error {
+	dstPolicy, err := models.GetPolicyByID(job.TaskProps.DstPolicyID)
+	if err != nil {
+		return err
+	}
+
+	name := file.Name
+	if name == "" {
+		name = path.Base(file.SourceName)
+	}
+	// 未开启自动重命名时，加入随机前缀避免与目标存储策略下的已有文件冲突
+	fileName := dstPolicy.GenerateFileName(file.UserID, name)
+	if !dstPolicy.AutoRename {
+		fileName = utils.RandStringRunes(8) + "_" + fileName
+	}
+	dst := path.Join(dstPolicy.GeneratePath(file.UserID, virtualDir(&file)), fileName)
+
+	fileCtx := context.WithValue(ctx, fsctx.FileModelCtx, file)
+	content, err := job.src.Get(fileCtx, file.SourceName)
+	if err != nil {
+		return err
+	}
+
+	if err := job.dst.Put(ctx, &fsctx.FileStream{
+		File:     content,
+		Size:     file.Size,
+		Name:     name,
+		SavePath: dst,
+	}); err != nil {
+		return err
+	}
+
+	if dstPolicy.IsThumbGenerateNeeded() {
+		job.migrateThumb(ctx, fileCtx, file.SourceName, dst)
+	}
+
+	if err := models.MigrateSource(job.TaskProps.SrcPolicyID, file.SourceName, dstPolicy.ID, dst); err != nil {
+		_, _ = job.dst.Delete(ctx, []string{dst})
+		return err
+	}
+
+	// 迁移期间新建的记录仍引用原物理文件时保留
+	referenced, err := models.GetReferencedSourceNames([]uint{job.TaskProps.SrcPolicyID}, []string{file.SourceName})
+	if err != nil || referenced[file.SourceName] {
+		return err
+	}
+
+	failed, err := job.src.Delete(ctx, []string{file.SourceName})
+	if err == nil && len(failed) > 0 {
+		err = fmt.Errorf("failed to delete %q", file.SourceName)
+	}
+	if err != nil {
+		logrus.Warningf("Unable to delete migrated file [%s], %s", file.SourceName, err)
+	}
+	return nil
+}
+
+// migrateThumb 复制已生成的缩略图，失败时由目标存储策略重新生成
+func (job *PolicyMigrateTask) migrateThumb(ctx, fileCtx context.Context, source, dst string) {
+	thumb, err := job.src.Thumb(fileCtx, source)
+	if err != nil || thumb.Content == nil {
+		return
+	}
+
+	size, err := thumb.Content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = thumb.Content.Seek(0, io.SeekStart)
+	}
+
+	if err == nil {
+		err = job.dst.Put(ctx, &fsctx.FileStream{
+			File:     thumb.Content,
+			Size:     uint64(size),
+			SavePath: dst + models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb"),
+			Mode:     fsctx.Overwrite,
+		})
+	} else {
+		thumb.Content.Close()
+	}
+
+	if err != nil {
+		logrus.Debugf("Unable to migrate thumbnail of [%s], %s", source, err)
+	}
+}
+
+// virtualDir 文件所在目录的路径，用于生成目标存储路径，无法确定时为根目录
+func virtualDir(file *models.File) string {
+	folders, err := models.GetFoldersByIDs([]uint{file.FolderID}, file.UserID)
+	if err != nil || len(folders) == 0 || folders[0].TraceRoot() != nil {
+		return "/"
+	}
+	return path.Join(folders[0].Position, folders[0].Name)
+}
+
+func (job *PolicyMigrateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+func (job *PolicyMigrateTask) GetError() *JobError {
+	return job.Err
+}
+
+func (job *PolicyMigrateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// NewPolicyMigrateTask 新建存储策略迁移任务，files 为空时迁移存储策略下的所有文件
+func NewPolicyMigrateTask(user, src, dst uint, files []uint) (Job, error) {
+	creator, err := models.GetActivateUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &PolicyMigrateTask{
+		User: &creator,
+		TaskProps: PolicyMigrateProps{
+			SrcPolicyID: src,
+			DstPolicyID: dst,
+			FileIDs:     files,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+	return newTask, nil
+}
+
+// NewPolicyMigrateTaskFromModel 从数据库记录中恢复存储策略迁移任务
+func NewPolicyMigrateTaskFromModel(task *models.Task) (Job, error) {
+	user, err := models.GetActivateUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &PolicyMigrateTask{
+		User:      &user,
+		TaskModel: task,
+	}
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+	return newTask, nil
+}
@@ -0,0 +1,339 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/encrypt"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/mirror"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/sirupsen/logrus"
+	"io"
+)
+
+// repairBatchSize 每批检查的记录数量
+const repairBatchSize = 100
+
+// MirrorRepairTask 检查镜像存储策略下的物理文件，将某些副本中缺失的文件从可用的副本重新复制
+type MirrorRepairTask struct {
+	User      *models.User
+	TaskModel *models.Task
+	TaskProps MirrorRepairProps
+	Err       *JobError
+}
+
+// MirrorRepairProps 镜像副本修复任务属性
+type MirrorRepairProps struct {
+	PolicyIDs []uint `json:"policy_ids"`
+	// Current 正在修复的存储策略在 PolicyIDs 中的序号，用于恢复任务
+	Current int `json:"current"`
+	// Stage 当前检查阶段，同存储策略迁移任务，用于恢复任务
+	Stage int `json:"stage"`
+	// LastID 当前阶段已处理的最后一个记录 ID，用于恢复任务
+	LastID  uint `json:"last_id"`
+	Checked int  `json:"checked"`
+	// Repaired 重新复制的副本数量
+	Repaired int `json:"repaired"`
+	// Failed 复制失败或所有副本均缺失的文件数量
+	Failed int `json:"failed"`
+}
+
+func (job *MirrorRepairTask) Type() int {
+	return MirrorRepairTaskType
+}
+
+func (job *MirrorRepairTask) Creator() uint {
+	return job.User.ID
+}
+
+func (job *MirrorRepairTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+func (job *MirrorRepairTask) Model() *models.Task {
+	return job.TaskModel
+}
+
+func (job *MirrorRepairTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+func (job *MirrorRepairTask) Do() {
+	ctx := context.Background()
+
+	job.TaskModel.SetProgress(ReplicatingProgress)
+	for ; job.TaskProps.Current < len(job.TaskProps.PolicyIDs); job.TaskProps.Current++ {
+		policy, err := models.GetPolicyByID(job.TaskProps.PolicyIDs[job.TaskProps.Current])
+		if err != nil {
+			logrus.Warningf("Storage policy [%d] not found, skipping", job.TaskProps.PolicyIDs[job.TaskProps.Current])
+			continue
+		}
+
+		if policy.Type != "mirror" {
+			logrus.Warningf("Storage policy [%s] is not a mirror policy, skipping", policy.Name)
+			continue
+		}
+
+		if err := job.repairPolicy(ctx, &policy); err != nil {
+			job.SetErrorMsg("Unable to repair storage policy "+policy.Name, err)
+			return
+		}
+
+		job.TaskProps.Stage = migrateFiles
+		job.TaskProps.LastID = 0
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg("Some files failed to be replicated, please check the log", nil)
+	}
+}
+
+// repairPolicy 逐批检查镜像存储策略下的物理文件在各副本中是否存在
+func (job *MirrorRepairTask) repairPolicy(ctx context.Context, policy *models.Policy) error {
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		return err
+	}
+	defer fs.Recycle()
+
+	fs.Policy = policy
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+
+	// 镜像存储策略开启加密时，副本中保存的是密文，直接在副本间复制即可
+	inner := fs.Handler
+	if encrypted, ok := inner.(*encrypt.Driver); ok {
+		inner = encrypted.Handler
+	}
+
+	handler, ok := inner.(*mirror.Driver)
+	if !ok {
+		return errors.New("storage policy handler is not a mirror")
+	}
+
+	indexes := make([]*sourceIndex, len(handler.Replicas))
+	for i, replica := range handler.Replicas {
+		indexes[i] = newSourceIndex(replica.Handler)
+	}
+
+	for job.TaskProps.Stage <= migrateVersions {
+		sources, last, err := job.next(policy.ID)
+		if err != nil {
+			return err
+		}
+
+		if len(sources) == 0 {
+			job.TaskProps.Stage++
+			job.TaskProps.LastID = 0
+			job.TaskModel.SetProps(job.Props())
+			continue
+		}
+
+		for _, source := range sources {
+			job.TaskProps.Checked++
+			job.repair(ctx, handler, indexes, source)
+		}
+
+		job.TaskProps.LastID = last
+		job.TaskModel.SetProps(job.Props())
+	}
+	return nil
+}
+
+// next 列出当前阶段下一批待检查的物理文件，第二个返回值为本批最后一个记录的 ID
+func (job *MirrorRepairTask) next(policyID uint) ([]string, uint, error) {
+	var (
+		sources []string
+		last    uint
+	)
+
+	if job.TaskProps.Stage == migrateFiles {
+		files, err := models.GetAllFilesByPolicy(policyID, nil, job.TaskProps.LastID, repairBatchSize)
+		if err != nil || len(files) == 0 {
+			return nil, 0, err
+		}
+
+		for _, file := range files {
+			sources = append(sources, file.SourceName)
+		}
+		last = files[len(files)-1].ID
+	} else {
+		versions, err := models.GetVersionsByPolicy(policyID, job.TaskProps.LastID, repairBatchSize)
+		if err != nil || len(versions) == 0 {
+			return nil, 0, err
+		}
+
+		for _, version := range versions {
+			sources = append(sources, version.SourceName)
+		}
+		last = versions[len(versions)-1].ID
+	}
+
+	res := make([]string, 0, len(sources))
+	processed := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		if !processed[source] {
+			processed[source] = true
+			res = append(res, source)
+		}
+	}
+	return res, last, nil
+}
+
+// repair 从第一个存在该文件的副本复制至缺失的副本，无法确认是否存在的副本不做处理
+func (job *MirrorRepairTask) repair(ctx context.Context, handler *mirror.Driver, indexes []*sourceIndex, source string) {
+	healthy := -1
+	var (
+		size    uint64
+		missing []int
+	)
+	for i, index := range indexes {
+		object, exist, err := index.Stat(ctx, source)
+		if err != nil {
+			logrus.Warningf("Unable to list [%s] from replica policy [%s], %s", source, handler.Replicas[i].Policy.Name, err)
+			continue
+		}
+
+		if !exist {
+			missing = append(missing, i)
+		} else if healthy < 0 {
+			healthy, size = i, object.Size
+		}
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+
+	if healthy < 0 {
+		logrus.Warningf("File [%s] is missing from all replicas", source)
+		job.TaskProps.Failed++
+		return
+	}
+
+	src := handler.Replicas[healthy]
+	for _, i := range missing {
+		dst := handler.Replicas[i]
+		if err := job.replicate(ctx, src, dst, source, size); err != nil {
+			logrus.Warningf("Unable to replicate [%s] to replica policy [%s], %s", source, dst.Policy.Name, err)
+			job.TaskProps.Failed++
+			continue
+		}
+
+		job.copyThumb(ctx, src, dst, source)
+		job.TaskProps.Repaired++
+	}
+}
+
+// replicate 复制副本中的物理文件
+func (job *MirrorRepairTask) replicate(ctx context.Context, src, dst mirror.Replica, source string, size uint64) error {
+	content, err := src.Handler.Get(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	return dst.Handler.Put(ctx, &fsctx.FileStream{
+		File:     content,
+		Seeker:   content,
+		Size:     size,
+		SavePath: source,
+		Mode:     fsctx.Overwrite,
+	})
+}
+
+// copyThumb 复制已生成的缩略图，源副本中没有缩略图时忽略
+func (job *MirrorRepairTask) copyThumb(ctx context.Context, src, dst mirror.Replica, source string) {
+	thumb, err := src.Handler.Thumb(ctx, source)
+	if err != nil || thumb.Content == nil {
+		return
+	}
+
+	size, err := thumb.Content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = thumb.Content.Seek(0, io.SeekStart)
+	}
+
+	if err == nil {
+		err = dst.Handler.Put(ctx, &fsctx.FileStream{
+			File:     thumb.Content,
+			Size:     uint64(size),
+			SavePath: source + models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb"),
+			Mode:     fsctx.Overwrite,
+		})
+	} else {
+		thumb.Content.Close()
+	}
+
+	if err != nil {
+		logrus.Debugf("Unable to replicate thumbnail of [%s], %s", source, err)
+	}
+}
+
+func (job *MirrorRepairTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+func (job *MirrorRepairTask) GetError() *JobError {
+	return job.Err
+}
+
+func (job *MirrorRepairTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// NewMirrorRepairTask 新建镜像副本修复任务，policies 为空时修复所有镜像存储策略
+func NewMirrorRepairTask(user uint, policies []uint) (Job, error) {
+	creator, err := models.GetActivateUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(policies) == 0 {
+		if err := models.Db.Model(&models.Policy{}).Where("type = ?", "mirror").Pluck("id", &policies).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	newTask := &MirrorRepairTask{
+		User: &creator,
+		TaskProps: MirrorRepairProps{
+			PolicyIDs: policies,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+	return newTask, nil
+}
+
+// NewMirrorRepairTaskFromModel 从数据库记录中恢复镜像副本修复任务
+func NewMirrorRepairTaskFromModel(task *models.Task) (Job, error) {
+	user, err := models.GetActivateUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &MirrorRepairTask{
+		User:      &user,
+		TaskModel: task,
+	}
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+	return newTask, nil
+}
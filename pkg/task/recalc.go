@@ -0,0 +1,172 @@
+package task
+
+import (
+	"encoding/json"
+	"github.com/jylc/cloudserver/models"
+	"github.com/sirupsen/logrus"
+	"sort"
+)
+
+// recalcBatchSize 每批处理的用户数量
+const recalcBatchSize = 100
+
+// StorageRecalcTask 根据文件记录重新计算用户的已用容量，修正增量更新产生的偏差
+type StorageRecalcTask struct {
+	User      *models.User
+	TaskModel *models.Task
+	TaskProps StorageRecalcProps
+	Err       *JobError
+}
+
+// StorageRecalcProps 容量重新计算任务属性
+type StorageRecalcProps struct {
+	// UserIDs 待重新计算的用户，为空时处理所有用户
+	UserIDs []uint `json:"user_ids,omitempty"`
+	// LastID 已处理的最后一个用户 ID，用于恢复任务
+	LastID  uint `json:"last_id"`
+	Checked int  `json:"checked"`
+	Failed  int  `json:"failed"`
+	// Drifts 已修正的容量偏差
+	Drifts []StorageDrift `json:"drifts"`
+}
+
+// StorageDrift 用户已用容量的修正记录
+type StorageDrift struct {
+	UserID uint   `json:"user_id"`
+	Before uint64 `json:"before"`
+	After  uint64 `json:"after"`
+}
+
+func (job *StorageRecalcTask) Type() int {
+	return StorageRecalcTaskType
+}
+
+func (job *StorageRecalcTask) Creator() uint {
+	return job.User.ID
+}
+
+func (job *StorageRecalcTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+func (job *StorageRecalcTask) Model() *models.Task {
+	return job.TaskModel
+}
+
+func (job *StorageRecalcTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+func (job *StorageRecalcTask) Do() {
+	job.TaskModel.SetProgress(RecalculatingProgress)
+	for {
+		ids, err := job.next()
+		if err != nil {
+			job.SetErrorMsg("Unable to list users", err)
+			return
+		}
+
+		if len(ids) == 0 {
+			break
+		}
+
+		for _, uid := range ids {
+			before, after, err := models.RecalculateStorage(uid)
+			if err != nil {
+				logrus.Warningf("Unable to recalculate storage of user [%d], %s", uid, err)
+				job.TaskProps.Failed++
+				continue
+			}
+
+			job.TaskProps.Checked++
+			if before != after {
+				logrus.Infof("Storage of user [%d] corrected from %d to %d", uid, before, after)
+				job.TaskProps.Drifts = append(job.TaskProps.Drifts, StorageDrift{UserID: uid, Before: before, After: after})
+			}
+		}
+
+		job.TaskProps.LastID = ids[len(ids)-1]
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg("Failed to recalculate storage of some users, please check the log", nil)
+	}
+}
+
+// next 列出下一批待处理的用户
+func (job *StorageRecalcTask) next() ([]uint, error) {
+	if len(job.TaskProps.UserIDs) == 0 {
+		return models.GetUserIDsAfter(job.TaskProps.LastID, recalcBatchSize)
+	}
+
+	ids := make([]uint, 0, recalcBatchSize)
+	for _, id := range job.TaskProps.UserIDs {
+		if id > job.TaskProps.LastID && len(ids) < recalcBatchSize {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (job *StorageRecalcTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+func (job *StorageRecalcTask) GetError() *JobError {
+	return job.Err
+}
+
+func (job *StorageRecalcTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// NewStorageRecalcTask 新建容量重新计算任务，users 为空时处理所有用户
+func NewStorageRecalcTask(user uint, users []uint) (Job, error) {
+	creator, err := models.GetActivateUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按 ID 顺序处理，以便通过 LastID 恢复任务
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+
+	newTask := &StorageRecalcTask{
+		User: &creator,
+		TaskProps: StorageRecalcProps{
+			UserIDs: users,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+	return newTask, nil
+}
+
+// NewStorageRecalcTaskFromModel 从数据库记录中恢复容量重新计算任务
+func NewStorageRecalcTaskFromModel(task *models.Task) (Job, error) {
+	user, err := models.GetActivateUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &StorageRecalcTask{
+		User:      &user,
+		TaskModel: task,
+	}
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+	return newTask, nil
+}
@@ -0,0 +1,335 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/sirupsen/logrus"
+	"path"
+	"strconv"
+)
+
+// 对账问题的处理方式，为空时仅记录报告
+const (
+	// ReconcileQuarantine 将孤立文件移至隔离目录
+	ReconcileQuarantine = "quarantine"
+	// ReconcileDelete 删除孤立文件
+	ReconcileDelete = "delete"
+	// ReconcileMark 在文件元数据中标记物理文件已丢失
+	ReconcileMark = "mark"
+	// ReconcileRemove 删除物理文件已丢失的文件记录
+	ReconcileRemove = "remove"
+)
+
+// ReconcileTask 比对存储策略下的物理文件与文件记录，找出没有文件记录的孤立文件，
+// 以及物理文件已丢失的文件记录
+type ReconcileTask struct {
+	User      *models.User
+	TaskModel *models.Task
+	TaskProps ReconcileProps
+	Err       *JobError
+}
+
+// ReconcileProps 对账任务属性
+type ReconcileProps struct {
+	PolicyIDs []uint `json:"policy_ids"`
+	// OrphanAction 孤立文件的处理方式，可选 quarantine、delete
+	OrphanAction string `json:"orphan_action"`
+	// DanglingAction 物理文件已丢失的文件记录的处理方式，可选 mark、remove
+	DanglingAction string `json:"dangling_action"`
+	// Current 正在对账的存储策略在 PolicyIDs 中的序号，用于恢复任务
+	Current int `json:"current"`
+	// LastID 已对账的最后一个文件 ID，用于恢复任务
+	LastID   uint `json:"last_id"`
+	Checked  int  `json:"checked"`
+	Dangling int  `json:"dangling"`
+	Orphaned int  `json:"orphaned"`
+}
+
+func (job *ReconcileTask) Type() int {
+	return ReconcileTaskType
+}
+
+func (job *ReconcileTask) Creator() uint {
+	return job.User.ID
+}
+
+func (job *ReconcileTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+func (job *ReconcileTask) Model() *models.Task {
+	return job.TaskModel
+}
+
+func (job *ReconcileTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+func (job *ReconcileTask) Do() {
+	ctx := context.Background()
+
+	job.TaskModel.SetProgress(ReconcilingProgress)
+	for ; job.TaskProps.Current < len(job.TaskProps.PolicyIDs); job.TaskProps.Current++ {
+		policy, err := models.GetPolicyByID(job.TaskProps.PolicyIDs[job.TaskProps.Current])
+		if err != nil {
+			logrus.Warningf("Storage policy [%d] not found, skipping", job.TaskProps.PolicyIDs[job.TaskProps.Current])
+			continue
+		}
+
+		if err := job.reconcile(ctx, &policy); err != nil {
+			job.SetErrorMsg(fmt.Sprintf("Unable to reconcile storage policy [%s]", policy.Name), err)
+			return
+		}
+
+		job.TaskProps.LastID = 0
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Dangling+job.TaskProps.Orphaned > 0 {
+		job.SetErrorMsg(fmt.Sprintf("Found %d dangling files and %d orphaned objects, please check the report",
+			job.TaskProps.Dangling, job.TaskProps.Orphaned), nil)
+	}
+}
+
+// reconcile 对账单个存储策略
+func (job *ReconcileTask) reconcile(ctx context.Context, policy *models.Policy) error {
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		return err
+	}
+	defer fs.Recycle()
+
+	fs.Policy = policy
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+
+	// 上传占位文件的物理文件可能尚未写入，不参与丢失检查
+	for {
+		files, err := models.GetFilesByPolicy(policy.ID, job.TaskProps.LastID, scrubBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			break
+		}
+
+		index := newSourceIndex(fs.Handler)
+		dangling := make(map[uint][]uint)
+		for i := range files {
+			job.TaskProps.Checked++
+
+			_, exist, err := index.Stat(ctx, files[i].SourceName)
+			if err != nil {
+				logrus.Warningf("Unable to list directory of [%s], %s", files[i].SourceName, err)
+				continue
+			}
+
+			marked := files[i].MetadataSerialized[models.DanglingMetadataKey] != ""
+			if exist {
+				// 物理文件已恢复
+				if marked {
+					if err := files[i].MarkDangling(false); err != nil {
+						logrus.Warningf("Unable to unmark file [%d], %s", files[i].ID, err)
+					}
+				}
+				continue
+			}
+
+			issue := &models.ScrubIssue{
+				Type:       models.ScrubMissing,
+				SourceName: files[i].SourceName,
+				FileID:     files[i].ID,
+			}
+			switch job.TaskProps.DanglingAction {
+			case ReconcileMark:
+				issue.Repaired = marked || files[i].MarkDangling(true) == nil
+			case ReconcileRemove:
+				dangling[files[i].UserID] = append(dangling[files[i].UserID], files[i].ID)
+				issue.Repaired = true
+			}
+			job.report(policy, issue)
+		}
+
+		deleteUserFiles(ctx, dangling)
+
+		job.TaskProps.LastID = files[len(files)-1].ID
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	// 隔离目录位于某个存储策略的存储目录下时，隔离的文件会再次被识别为孤立文件
+	orphanAction := job.TaskProps.OrphanAction
+	if orphanAction == ReconcileQuarantine {
+		overlapped, err := quarantineOverlapped(policy)
+		if err != nil {
+			return err
+		}
+		if overlapped {
+			logrus.Warningf("Quarantine directory overlaps the storage root of policy [%s], orphans will only be reported", policy.Name)
+			orphanAction = ""
+		}
+	}
+
+	return findOrphans(ctx, fs.Handler, policy, func(orphans []response.Object) error {
+		for _, orphan := range orphans {
+			issue := &models.ScrubIssue{Type: models.ScrubOrphaned, SourceName: orphan.Source}
+			switch orphanAction {
+			case ReconcileQuarantine:
+				if err := job.quarantine(ctx, fs.Handler, orphan); err != nil {
+					logrus.Warningf("Unable to quarantine [%s], %s", orphan.Source, err)
+				} else {
+					issue.Repaired = true
+				}
+			case ReconcileDelete:
+				failed, err := fs.Handler.Delete(ctx, []string{orphan.Source})
+				issue.Repaired = err == nil && len(failed) == 0
+			}
+			if issue.Repaired {
+				_ = models.DeleteBlobsBySource(policy.ID, []string{orphan.Source})
+			}
+			job.report(policy, issue)
+		}
+		return nil
+	})
+}
+
+// quarantineOverlapped 隔离目录是否与同一存储端上任一存储策略的存储目录重叠
+func quarantineOverlapped(policy *models.Policy) (bool, error) {
+	quarantine := *policy
+	quarantine.DirNameRule = models.GetSettingByNameWithDefault("reconcile_quarantine_path", "quarantine")
+	if quarantine.StaticRoot() == "" {
+		return true, nil
+	}
+
+	policies, err := models.GetPolicies()
+	if err != nil {
+		return false, err
+	}
+
+	for i := range policies {
+		if quarantine.SharesRootWith(&policies[i]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// quarantine 将孤立文件移至隔离目录下以任务 ID 命名的子目录，保留原有的存储路径
+func (job *ReconcileTask) quarantine(ctx context.Context, handler driver.Handler, orphan response.Object) error {
+	dst := path.Join(
+		models.GetSettingByNameWithDefault("reconcile_quarantine_path", "quarantine"),
+		strconv.FormatUint(uint64(job.TaskModel.ID), 10),
+		orphan.Source,
+	)
+
+	fileCtx := context.WithValue(ctx, fsctx.FileModelCtx, models.File{SourceName: orphan.Source, Size: orphan.Size})
+	content, err := handler.Get(fileCtx, orphan.Source)
+	if err != nil {
+		return err
+	}
+
+	if err := handler.Put(ctx, &fsctx.FileStream{
+		File:     content,
+		Size:     orphan.Size,
+		Name:     path.Base(orphan.Source),
+		SavePath: dst,
+		Mode:     fsctx.Overwrite,
+	}); err != nil {
+		return err
+	}
+
+	failed, err := handler.Delete(ctx, []string{orphan.Source})
+	if err == nil && len(failed) > 0 {
+		err = fmt.Errorf("failed to delete %q", orphan.Source)
+	}
+	return err
+}
+
+// report 记录对账发现的问题
+func (job *ReconcileTask) report(policy *models.Policy, issue *models.ScrubIssue) {
+	if issue.Type == models.ScrubOrphaned {
+		job.TaskProps.Orphaned++
+	} else {
+		job.TaskProps.Dangling++
+	}
+
+	issue.TaskID = job.TaskModel.ID
+	issue.PolicyID = policy.ID
+	if err := issue.Create(); err != nil {
+		logrus.Warningf("Unable to save reconciliation report of [%s], %s", issue.SourceName, err)
+	}
+}
+
+func (job *ReconcileTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+func (job *ReconcileTask) GetError() *JobError {
+	return job.Err
+}
+
+func (job *ReconcileTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// NewReconcileTask 新建对账任务，policies 为空时对账所有存储策略
+func NewReconcileTask(user uint, policies []uint, orphanAction, danglingAction string) (Job, error) {
+	creator, err := models.GetActivateUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(policies) == 0 {
+		if err := models.Db.Model(&models.Policy{}).Pluck("id", &policies).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	newTask := &ReconcileTask{
+		User: &creator,
+		TaskProps: ReconcileProps{
+			PolicyIDs:      policies,
+			OrphanAction:   orphanAction,
+			DanglingAction: danglingAction,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+	return newTask, nil
+}
+
+// NewReconcileTaskFromModel 从数据库记录中恢复对账任务
+func NewReconcileTaskFromModel(task *models.Task) (Job, error) {
+	user, err := models.GetActivateUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &ReconcileTask{
+		User:      &user,
+		TaskModel: task,
+	}
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+	return newTask, nil
+}
@@ -0,0 +1,311 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/encrypt"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"io"
+	"path"
+)
+
+// rotateBatchSize 每批处理的文件数量
+const rotateBatchSize = 100
+
+// KeyRotateTask 为存储策略生成新的数据密钥，并用新密钥重新加密已有文件
+type KeyRotateTask struct {
+	User      *models.User
+	TaskModel *models.Task
+	TaskProps KeyRotateProps
+	Err       *JobError
+}
+
+// KeyRotateProps 密钥轮换任务属性
+type KeyRotateProps struct {
+	PolicyID uint `json:"policy_id"`
+	// KeyVersion 新密钥的版本，为 0 时表示尚未生成
+	KeyVersion uint32 `json:"key_version"`
+	// Stage 当前处理阶段，与迁移任务相同，先处理文件记录再处理历史版本
+	Stage int `json:"stage"`
+	// LastID 当前阶段已处理的最后一个记录 ID，用于恢复任务
+	LastID uint `json:"last_id"`
+	// Failed 重新加密失败的文件数量
+	Failed int `json:"failed"`
+}
+
+func (job *KeyRotateTask) Type() int {
+	return KeyRotateTaskType
+}
+
+func (job *KeyRotateTask) Creator() uint {
+	return job.User.ID
+}
+
+func (job *KeyRotateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+func (job *KeyRotateTask) Model() *models.Task {
+	return job.TaskModel
+}
+
+func (job *KeyRotateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+func (job *KeyRotateTask) Do() {
+	ctx := context.Background()
+
+	policy, err := models.GetPolicyByID(job.TaskProps.PolicyID)
+	if err != nil {
+		job.SetErrorMsg("Storage policy not found", err)
+		return
+	}
+
+	if !policy.IsEncrypted() {
+		job.SetErrorMsg("Encryption is not enabled for this storage policy", nil)
+		return
+	}
+
+	// 首次执行时生成新密钥，恢复的任务沿用已生成的版本
+	if job.TaskProps.KeyVersion == 0 {
+		if err := encrypt.NewKey(&policy); err != nil {
+			job.SetErrorMsg("Unable to generate new data key", err)
+			return
+		}
+
+		if err := policy.UpdateOptions(); err != nil {
+			job.SetErrorMsg("Unable to save storage policy", err)
+			return
+		}
+
+		job.TaskProps.KeyVersion = policy.OptionsSerialized.Encryption.KeyVersion
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg(err.Error(), nil)
+		return
+	}
+	defer fs.Recycle()
+
+	fs.Policy = &policy
+	if err := fs.DispatchHandler(); err != nil {
+		job.SetErrorMsg("Unable to distribute storage policy", err)
+		return
+	}
+
+	handler, ok := fs.Handler.(*encrypt.Driver)
+	if !ok {
+		job.SetErrorMsg("Storage policy handler is not encrypted", nil)
+		return
+	}
+
+	job.TaskModel.SetProgress(EncryptingProgress)
+	for job.TaskProps.Stage <= migrateVersions {
+		files, last, err := job.next()
+		if err != nil {
+			job.SetErrorMsg("Unable to list files", err)
+			return
+		}
+
+		if len(files) == 0 {
+			job.TaskProps.Stage++
+			job.TaskProps.LastID = 0
+			job.TaskModel.SetProps(job.Props())
+			continue
+		}
+
+		for _, file := range files {
+			if err := job.rotate(ctx, handler, file); err != nil {
+				logrus.Warningf("Unable to re-encrypt file [%s], %s", file.SourceName, err)
+				job.TaskProps.Failed++
+			}
+		}
+
+		job.TaskProps.LastID = last
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg("Some files failed to be re-encrypted, please check the log", nil)
+	}
+}
+
+// next 列出当前阶段下一批待处理的物理文件，包括回收站中的文件及仅被历史版本引用的物理文件，
+// 引用同一物理文件的记录只返回一次，第二个返回值为本批最后一个记录的 ID
+func (job *KeyRotateTask) next() ([]models.File, uint, error) {
+	var (
+		files []models.File
+		last  uint
+	)
+
+	if job.TaskProps.Stage == migrateFiles {
+		batch, err := models.GetAllFilesByPolicy(job.TaskProps.PolicyID, nil, job.TaskProps.LastID, rotateBatchSize)
+		if err != nil || len(batch) == 0 {
+			return nil, 0, err
+		}
+		files, last = batch, batch[len(batch)-1].ID
+	} else {
+		versions, err := models.GetVersionsByPolicy(job.TaskProps.PolicyID, job.TaskProps.LastID, rotateBatchSize)
+		if err != nil || len(versions) == 0 {
+			return nil, 0, err
+		}
+
+		for _, version := range versions {
+			files = append(files, models.File{
+				UserID:     version.UserID,
+				Size:       version.Size,
+				SourceName: version.SourceName,
+				PolicyID:   version.PolicyID,
+			})
+		}
+		last = versions[len(versions)-1].ID
+	}
+
+	res := make([]models.File, 0, len(files))
+	processed := make(map[string]bool, len(files))
+	for _, file := range files {
+		if !processed[file.SourceName] {
+			processed[file.SourceName] = true
+			res = append(res, file)
+		}
+	}
+	return res, last, nil
+}
+
+// rotate 使用当前密钥将文件写入新路径，再替换文件记录并删除旧文件
+func (job *KeyRotateTask) rotate(ctx context.Context, handler *encrypt.Driver, file models.File) error {
+	version, err := handler.KeyVersion(ctx, file.SourceName)
+	if err != nil {
+		return err
+	}
+
+	if version == job.TaskProps.KeyVersion {
+		return nil
+	}
+
+	dst := path.Join(path.Dir(file.SourceName), utils.RandStringRunes(16)+path.Ext(file.SourceName))
+	fileCtx := context.WithValue(ctx, fsctx.FileModelCtx, file)
+	content, err := handler.Get(fileCtx, file.SourceName)
+	if err != nil {
+		return err
+	}
+
+	name := file.Name
+	if name == "" {
+		name = path.Base(file.SourceName)
+	}
+
+	if err := handler.Put(ctx, &fsctx.FileStream{
+		File:     content,
+		Size:     file.Size,
+		Name:     name,
+		SavePath: dst,
+		Mode:     fsctx.Overwrite,
+	}); err != nil {
+		return err
+	}
+
+	// 缩略图同样需要重新加密
+	thumbSuffix := models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb")
+	if thumb, err := handler.Thumb(ctx, file.SourceName); err == nil {
+		size, err := thumb.Content.Seek(0, io.SeekEnd)
+		if err == nil {
+			_, err = thumb.Content.Seek(0, io.SeekStart)
+		}
+
+		if err == nil {
+			err = handler.Put(ctx, &fsctx.FileStream{
+				File:     thumb.Content,
+				Size:     uint64(size),
+				SavePath: dst + thumbSuffix,
+				Mode:     fsctx.Overwrite,
+			})
+		} else {
+			thumb.Content.Close()
+		}
+
+		if err != nil {
+			logrus.Warningf("Unable to re-encrypt thumbnail of [%s], %s", file.SourceName, err)
+		}
+	}
+
+	if err := models.ReplaceSourceName(file.PolicyID, file.SourceName, dst); err != nil {
+		_, _ = handler.Delete(ctx, []string{dst})
+		return err
+	}
+
+	// 替换期间新建的记录仍引用原物理文件时保留
+	referenced, err := models.GetReferencedSourceNames([]uint{file.PolicyID}, []string{file.SourceName})
+	if err != nil || referenced[file.SourceName] {
+		return err
+	}
+
+	_, err = handler.Delete(ctx, []string{file.SourceName})
+	return err
+}
+
+func (job *KeyRotateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+func (job *KeyRotateTask) GetError() *JobError {
+	return job.Err
+}
+
+func (job *KeyRotateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// NewKeyRotateTask 新建密钥轮换任务
+func NewKeyRotateTask(user, policy uint) (Job, error) {
+	creator, err := models.GetActivateUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &KeyRotateTask{
+		User: &creator,
+		TaskProps: KeyRotateProps{
+			PolicyID: policy,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+	return newTask, nil
+}
+
+// NewKeyRotateTaskFromModel 从数据库记录中恢复密钥轮换任务
+func NewKeyRotateTaskFromModel(task *models.Task) (Job, error) {
+	user, err := models.GetActivateUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &KeyRotateTask{
+		User:      &user,
+		TaskModel: task,
+	}
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+	return newTask, nil
+}
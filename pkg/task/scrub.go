@@ -0,0 +1,492 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/checksum"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver"
+	"github.com/jylc/cloudserver/pkg/filesystem/response"
+	"github.com/sirupsen/logrus"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scrubBatchSize 每批巡检的文件数量
+const scrubBatchSize = 100
+
+// scrubOrphanGrace 最近修改的物理文件可能属于进行中的上传，不视为孤立文件
+const scrubOrphanGrace = 24 * time.Hour
+
+// ScrubTask 巡检存储策略下的物理文件，检查文件记录对应的物理文件是否存在、大小及内容是否一致，
+// 并找出未被引用的孤立文件，发现的问题记录为巡检报告
+type ScrubTask struct {
+	User      *models.User
+	TaskModel *models.Task
+	TaskProps ScrubProps
+	Err       *JobError
+}
+
+// ScrubProps 存储巡检任务属性
+type ScrubProps struct {
+	PolicyIDs []uint `json:"policy_ids"`
+	// Rehash 是否重新计算已记录哈希的文件内容
+	Rehash bool `json:"rehash"`
+	// Repair 是否修复发现的问题
+	Repair bool `json:"repair"`
+	// Current 正在巡检的存储策略在 PolicyIDs 中的序号，用于恢复任务
+	Current int `json:"current"`
+	// LastID 已巡检的最后一个文件 ID，用于恢复任务
+	LastID     uint `json:"last_id"`
+	Checked    int  `json:"checked"`
+	Missing    int  `json:"missing"`
+	Mismatched int  `json:"mismatched"`
+	Orphaned   int  `json:"orphaned"`
+}
+
+func (job *ScrubTask) Type() int {
+	return ScrubTaskType
+}
+
+func (job *ScrubTask) Creator() uint {
+	return job.User.ID
+}
+
+func (job *ScrubTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+func (job *ScrubTask) Model() *models.Task {
+	return job.TaskModel
+}
+
+func (job *ScrubTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+func (job *ScrubTask) Do() {
+	ctx := context.Background()
+
+	job.TaskModel.SetProgress(ScrubbingProgress)
+	for ; job.TaskProps.Current < len(job.TaskProps.PolicyIDs); job.TaskProps.Current++ {
+		policy, err := models.GetPolicyByID(job.TaskProps.PolicyIDs[job.TaskProps.Current])
+		if err != nil {
+			logrus.Warningf("Storage policy [%d] not found, skipping", job.TaskProps.PolicyIDs[job.TaskProps.Current])
+			continue
+		}
+
+		if err := job.scrub(ctx, &policy); err != nil {
+			job.SetErrorMsg(fmt.Sprintf("Unable to scrub storage policy [%s]", policy.Name), err)
+			return
+		}
+
+		job.TaskProps.LastID = 0
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Missing+job.TaskProps.Mismatched+job.TaskProps.Orphaned > 0 {
+		job.SetErrorMsg(fmt.Sprintf("Found %d missing, %d mismatched and %d orphaned objects, please check the scrub report",
+			job.TaskProps.Missing, job.TaskProps.Mismatched, job.TaskProps.Orphaned), nil)
+	}
+}
+
+// scrub 巡检单个存储策略
+func (job *ScrubTask) scrub(ctx context.Context, policy *models.Policy) error {
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		return err
+	}
+	defer fs.Recycle()
+
+	fs.Policy = policy
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+
+	for {
+		files, err := models.GetFilesByPolicy(policy.ID, job.TaskProps.LastID, scrubBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			break
+		}
+
+		// 同一批次中的文件共用目录列表，引用同一物理文件的记录只检查一次
+		index := newSourceIndex(fs.Handler)
+		results := make(map[string]*models.ScrubIssue)
+		missing := make(map[uint][]uint)
+		for i := range files {
+			job.TaskProps.Checked++
+
+			issue, checked := results[files[i].SourceName]
+			if !checked {
+				issue = job.check(ctx, fs, &files[i], index)
+				results[files[i].SourceName] = issue
+
+				// 缺失的物理文件不能再用于去重，无论是否修复
+				if issue != nil && issue.Type == models.ScrubMissing {
+					if err := models.DeleteBlobsBySource(policy.ID, []string{files[i].SourceName}); err != nil {
+						logrus.Warningf("Unable to delete blob record of [%s], %s", files[i].SourceName, err)
+					}
+				}
+			}
+
+			if issue == nil {
+				continue
+			}
+
+			record := *issue
+			record.FileID = files[i].ID
+			if issue.Type == models.ScrubMissing {
+				missing[files[i].UserID] = append(missing[files[i].UserID], files[i].ID)
+				record.Repaired = job.TaskProps.Repair
+			} else if issue.Type == models.ScrubHashMismatch && job.TaskProps.Repair {
+				// 清除错误的哈希，避免新上传的文件被链接至已损坏的物理文件
+				record.Repaired = files[i].UpdateSHA256("") == nil
+			}
+			job.report(policy, &record)
+		}
+
+		if job.TaskProps.Repair {
+			deleteUserFiles(ctx, missing)
+		}
+
+		job.TaskProps.LastID = files[len(files)-1].ID
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	return job.scrubOrphans(ctx, fs, policy)
+}
+
+// check 检查文件记录对应的物理文件，无法确定结果时不视为问题
+func (job *ScrubTask) check(ctx context.Context, fs *filesystem.FileSystem, file *models.File, index *sourceIndex) *models.ScrubIssue {
+	object, exist, err := index.Stat(ctx, file.SourceName)
+	if err != nil {
+		logrus.Warningf("Unable to list directory of [%s], %s", file.SourceName, err)
+		return nil
+	}
+
+	if !exist {
+		return &models.ScrubIssue{Type: models.ScrubMissing, SourceName: file.SourceName}
+	}
+
+	if object.Size != file.Size {
+		return &models.ScrubIssue{
+			Type:       models.ScrubSizeMismatch,
+			SourceName: file.SourceName,
+			Expected:   strconv.FormatUint(file.Size, 10),
+			Actual:     strconv.FormatUint(object.Size, 10),
+		}
+	}
+
+	if !job.TaskProps.Rehash || file.SHA256 == "" {
+		return nil
+	}
+
+	expected, err := checksum.FromSHA256(file.SHA256)
+	if err != nil {
+		logrus.Warningf("Invalid stored hash of [%s], %s", file.SourceName, err)
+		return nil
+	}
+
+	_, err = fs.VerifyContent(ctx, file, expected)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, checksum.ErrMismatch):
+		return &models.ScrubIssue{
+			Type:       models.ScrubHashMismatch,
+			SourceName: file.SourceName,
+			Expected:   file.SHA256,
+		}
+	case errors.Is(err, filesystem.ErrFileSizeMismatch):
+		return &models.ScrubIssue{
+			Type:       models.ScrubSizeMismatch,
+			SourceName: file.SourceName,
+			Expected:   strconv.FormatUint(file.Size, 10),
+		}
+	default:
+		logrus.Warningf("Unable to read [%s], %s", file.SourceName, err)
+		return nil
+	}
+}
+
+// scrubOrphans 找出存储策略下未被引用的孤立文件
+func (job *ScrubTask) scrubOrphans(ctx context.Context, fs *filesystem.FileSystem, policy *models.Policy) error {
+	return findOrphans(ctx, fs.Handler, policy, func(orphans []response.Object) error {
+		for _, orphan := range orphans {
+			issue := &models.ScrubIssue{Type: models.ScrubOrphaned, SourceName: orphan.Source}
+			if job.TaskProps.Repair {
+				failed, err := fs.Handler.Delete(ctx, []string{orphan.Source})
+				issue.Repaired = err == nil && len(failed) == 0
+				if issue.Repaired {
+					_ = models.DeleteBlobsBySource(policy.ID, []string{orphan.Source})
+				}
+			}
+			job.report(policy, issue)
+		}
+		return nil
+	})
+}
+
+// report 记录巡检发现的问题
+func (job *ScrubTask) report(policy *models.Policy, issue *models.ScrubIssue) {
+	switch issue.Type {
+	case models.ScrubMissing:
+		job.TaskProps.Missing++
+	case models.ScrubOrphaned:
+		job.TaskProps.Orphaned++
+	default:
+		job.TaskProps.Mismatched++
+	}
+
+	issue.TaskID = job.TaskModel.ID
+	issue.PolicyID = policy.ID
+	if err := issue.Create(); err != nil {
+		logrus.Warningf("Unable to save scrub report of [%s], %s", issue.SourceName, err)
+	}
+}
+
+func (job *ScrubTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+func (job *ScrubTask) GetError() *JobError {
+	return job.Err
+}
+
+func (job *ScrubTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// NewScrubTask 新建存储巡检任务，policies 为空时巡检所有存储策略
+func NewScrubTask(user uint, policies []uint, rehash, repair bool) (Job, error) {
+	creator, err := models.GetActivateUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(policies) == 0 {
+		if err := models.Db.Model(&models.Policy{}).Pluck("id", &policies).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	newTask := &ScrubTask{
+		User: &creator,
+		TaskProps: ScrubProps{
+			PolicyIDs: policies,
+			Rehash:    rehash,
+			Repair:    repair,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+	return newTask, nil
+}
+
+// NewScrubTaskFromModel 从数据库记录中恢复存储巡检任务
+func NewScrubTaskFromModel(task *models.Task) (Job, error) {
+	user, err := models.GetActivateUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &ScrubTask{
+		User:      &user,
+		TaskModel: task,
+	}
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+	return newTask, nil
+}
+
+// sourceIndex 按目录缓存存储策略下的物理文件列表
+type sourceIndex struct {
+	handler driver.Handler
+	dirs    map[string]map[string]response.Object
+}
+
+func newSourceIndex(handler driver.Handler) *sourceIndex {
+	return &sourceIndex{
+		handler: handler,
+		dirs:    make(map[string]map[string]response.Object),
+	}
+}
+
+// Stat 查找物理文件，目录视为不存在
+func (index *sourceIndex) Stat(ctx context.Context, source string) (response.Object, bool, error) {
+	dir := path.Dir(source)
+	objects, ok := index.dirs[dir]
+	if !ok {
+		list, err := index.handler.List(ctx, dir, false)
+		if err != nil {
+			return response.Object{}, false, err
+		}
+
+		objects = make(map[string]response.Object, len(list))
+		for _, object := range list {
+			objects[path.Base(object.RelativePath)] = object
+		}
+		index.dirs[dir] = objects
+	}
+
+	object, exist := objects[path.Base(source)]
+	return object, exist && !object.IsDir, nil
+}
+
+// findOrphans 列出存储策略根目录下的物理文件，分批找出未被文件记录（含上传占位文件及回收站中的文件）
+// 或历史版本引用的孤立文件。缩略图随原文件一同判断，最近修改的文件不视为孤立文件。
+// 传入 fn 的对象中 Source 为物理文件的存储路径
+func findOrphans(ctx context.Context, handler driver.Handler, policy *models.Policy, fn func(orphans []response.Object) error) error {
+	root := policy.StaticRoot()
+	if root == "" {
+		logrus.Warningf("Unable to determine storage root of policy [%s], skipping orphan detection", policy.Name)
+		return nil
+	}
+
+	owners, err := referencingPolicies(policy)
+	if err != nil {
+		return err
+	}
+
+	objects, err := handler.List(ctx, root, true)
+	if err != nil {
+		return err
+	}
+
+	thumbSuffix := models.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb")
+	candidates := make([]response.Object, 0, scrubBatchSize)
+	flush := func() error {
+		sources := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			sources[i] = strings.TrimSuffix(candidate.Source, thumbSuffix)
+		}
+
+		referenced, err := models.GetReferencedSourceNames(owners, sources)
+		if err != nil {
+			return err
+		}
+
+		orphans := make([]response.Object, 0, len(candidates))
+		for i, candidate := range candidates {
+			if !referenced[sources[i]] {
+				orphans = append(orphans, candidate)
+			}
+		}
+
+		candidates = candidates[:0]
+		if len(orphans) == 0 {
+			return nil
+		}
+		return fn(orphans)
+	}
+
+	for _, object := range objects {
+		if object.IsDir || time.Since(object.LastModify) < scrubOrphanGrace {
+			continue
+		}
+
+		object.Source = path.Join(root, object.RelativePath)
+		candidates = append(candidates, object)
+		if len(candidates) >= scrubBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// referencingPolicies 列出记录可能引用存储策略下物理文件的存储策略，包括自身、
+// 使用同一存储端且存储目录重叠的其他存储策略，以及将其用作副本的镜像存储策略。
+// 镜像存储策略的物理文件位于各副本中，副本相关的存储策略同样计入
+func referencingPolicies(policy *models.Policy) ([]uint, error) {
+	policies, err := models.GetPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	storages := []uint{policy.ID}
+	if policy.Type == "mirror" {
+		storages = append(storages, policy.OptionsSerialized.Replicas...)
+	}
+
+	res := make([]uint, 0, len(storages))
+	added := make(map[uint]bool)
+	add := func(id uint) {
+		if !added[id] {
+			added[id] = true
+			res = append(res, id)
+		}
+	}
+
+	byID := make(map[uint]*models.Policy, len(policies))
+	for i := range policies {
+		byID[policies[i].ID] = &policies[i]
+	}
+
+	for _, id := range storages {
+		add(id)
+		if storage, ok := byID[id]; ok {
+			for i := range policies {
+				if policies[i].ID != id && storage.SharesRootWith(&policies[i]) {
+					add(policies[i].ID)
+				}
+			}
+		}
+
+		// 镜像存储策略的文件记录使用镜像自身的存储策略 ID
+		mirrors, err := models.GetMirrorsByReplica(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, mirror := range mirrors {
+			add(mirror.ID)
+		}
+	}
+	return res, nil
+}
+
+// deleteUserFiles 删除各用户的文件记录，物理文件删除失败时同样删除记录
+func deleteUserFiles(ctx context.Context, userFiles map[uint][]uint) {
+	for uid, fileIDs := range userFiles {
+		user, err := models.GetUserByID(uid)
+		if err != nil {
+			logrus.Warningf("The owner of files to be deleted does not exist,%s", err)
+			continue
+		}
+
+		fs, err := filesystem.NewFileSystem(&user)
+		if err != nil {
+			logrus.Warningf("Unable to initialize file system,%s", err)
+			continue
+		}
+
+		if err := fs.Delete(ctx, []uint{}, fileIDs, true); err != nil {
+			logrus.Warningf("Unable to delete files of user [%d],%s", uid, err)
+		}
+		fs.Recycle()
+	}
+}
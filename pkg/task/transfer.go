@@ -6,6 +6,7 @@ import (
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/cluster"
 	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/checksum"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/utils"
 	"github.com/sirupsen/logrus"
@@ -84,8 +85,52 @@ func (job *TransferTask) Do() {
 
 		if err != nil {
 			job.SetErrorMsg("File transfer failed", err)
+			continue
 		}
+
+		if err := job.verify(fs, dst); err != nil {
+			job.SetErrorMsg("File integrity verification failed", err)
+		}
+	}
+}
+
+// verify 读取已转存的文件，校验其内容与大小，校验失败时删除该文件
+func (job *TransferTask) verify(fs *filesystem.FileSystem, dst string) error {
+	exist, file := fs.IsFileExist(dst)
+	if !exist {
+		return filesystem.ErrObjectNotExist
+	}
+
+	var (
+		expected *checksum.Checksum
+		err      error
+	)
+	if job.TaskProps.NodeID > 1 {
+		// 从机转存的内容未经过主机，只能校验大小，并补充内容哈希
+		fs.Policy = file.GetPolicy()
+		if err = fs.DispatchHandler(); err != nil {
+			return err
+		}
+	} else if file.SHA256 != "" {
+		// 主机转存时已根据源文件计算哈希
+		if expected, err = checksum.FromSHA256(file.SHA256); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	hash, err := fs.VerifyContent(ctx, file, expected)
+	if err != nil {
+		if deleteErr := fs.Delete(ctx, []uint{}, []uint{file.ID}, false); deleteErr != nil {
+			logrus.Warningf("Failed to delete corrupted file %q: %s", dst, deleteErr)
+		}
+		return err
+	}
+
+	if file.SHA256 == "" {
+		return file.UpdateSHA256(hash)
 	}
+	return nil
 }
 
 func (job *TransferTask) SetError(err *JobError) {
@@ -0,0 +1,123 @@
+package thumb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/utils"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// saveTempFile 将文件内容写入临时目录，外部程序只能处理本地文件
+func saveTempFile(file io.Reader, name string) (string, error) {
+	tempPath := filepath.Join(utils.RelativePath(models.GetSettingByName("temp_path")), "thumb")
+	return utils.CreateTempFile(tempPath, "thumb_*"+filepath.Ext(name), file)
+}
+
+// runCommand 执行外部命令并在出错时附带错误输出
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to execute %s: %w, %s", name, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// ffmpegGenerator 使用 ffmpeg 截取视频帧作为缩略图
+type ffmpegGenerator struct{}
+
+func (g *ffmpegGenerator) Generate(ctx context.Context, file io.Reader, name string) (*Thumb, error) {
+	input, err := saveTempFile(file, name)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(input)
+
+	output, err := g.capture(ctx, input, models.GetSettingByNameWithDefault("thumb_ffmpeg_seek", "00:00:01.00"))
+	if err != nil || len(output) == 0 {
+		// 视频短于截取位置时 ffmpeg 没有输出，改为截取第一帧
+		output, err = g.capture(ctx, input, "0")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := decodeImage(bytes.NewReader(output))
+	if err != nil {
+		return nil, err
+	}
+	return NewThumbFromImage(img), nil
+}
+
+// capture 截取视频中 seek 位置的一帧，输出为 PNG
+func (g *ffmpegGenerator) capture(ctx context.Context, input, seek string) ([]byte, error) {
+	return runCommand(ctx,
+		models.GetSettingByNameWithDefault("thumb_ffmpeg_path", "ffmpeg"),
+		"-ss", seek,
+		"-i", input,
+		"-vframes", "1",
+		"-f", "image2pipe",
+		"-vcodec", "png",
+		"-",
+	)
+}
+
+func (g *ffmpegGenerator) Enabled() bool {
+	return models.IsTrueVal(models.GetSettingByName("thumb_ffmpeg_enabled"))
+}
+
+// pdfGenerator 使用 pdftoppm 将 PDF 首页渲染为缩略图
+type pdfGenerator struct{}
+
+func (g *pdfGenerator) Generate(ctx context.Context, file io.Reader, name string) (*Thumb, error) {
+	input, err := saveTempFile(file, name)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(input)
+
+	// 渲染尺寸不超过缩略图尺寸，避免大页面占用过多内存
+	scale := models.GetIntSetting("thumb_width", 400)
+	if height := models.GetIntSetting("thumb_height", 300); height > scale {
+		scale = height
+	}
+
+	outputPrefix := input + "_page"
+	if _, err := runCommand(ctx,
+		models.GetSettingByNameWithDefault("thumb_pdf_path", "pdftoppm"),
+		"-png",
+		"-singlefile",
+		"-f", "1",
+		"-l", "1",
+		"-scale-to", strconv.Itoa(scale),
+		input,
+		outputPrefix,
+	); err != nil {
+		return nil, err
+	}
+	defer os.Remove(outputPrefix + ".png")
+
+	output, err := os.Open(outputPrefix + ".png")
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+
+	img, err := decodeImage(output)
+	if err != nil {
+		return nil, err
+	}
+	return NewThumbFromImage(img), nil
+}
+
+func (g *pdfGenerator) Enabled() bool {
+	return models.IsTrueVal(models.GetSettingByName("thumb_pdf_enabled"))
+}
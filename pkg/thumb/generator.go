@@ -0,0 +1,171 @@
+package thumb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jylc/cloudserver/models"
+	"image"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNotAvailable 没有可处理该文件的缩略图生成器
+	ErrNotAvailable = errors.New("no available thumbnail generator")
+	// ErrImageTooLarge 图像像素数超过限制
+	ErrImageTooLarge = errors.New("image is too large to generate thumbnail")
+)
+
+// Generator 缩略图生成器
+type Generator interface {
+	// Generate 读取文件内容并生成缩略图原图
+	Generate(ctx context.Context, file io.Reader, name string) (*Thumb, error)
+	// Enabled 生成器是否已启用
+	Enabled() bool
+}
+
+// registry 按扩展名及 MIME 类型索引的生成器
+type registry struct {
+	mu    sync.RWMutex
+	exts  map[string][]Generator
+	mimes map[string][]Generator
+}
+
+var generators = &registry{
+	exts:  make(map[string][]Generator),
+	mimes: make(map[string][]Generator),
+}
+
+// Register 注册缩略图生成器，扩展名不含点号，MIME 类型支持 video/* 形式的通配
+func Register(generator Generator, exts []string, mimes []string) {
+	generators.mu.Lock()
+	defer generators.mu.Unlock()
+
+	for _, ext := range exts {
+		ext = strings.ToLower(ext)
+		generators.exts[ext] = append(generators.exts[ext], generator)
+	}
+	for _, mime := range mimes {
+		mime = strings.ToLower(mime)
+		generators.mimes[mime] = append(generators.mimes[mime], generator)
+	}
+}
+
+// GetGenerators 获取可处理该文件且已启用的生成器，扩展名匹配的生成器优先
+func GetGenerators(name, mimeType string) []Generator {
+	generators.mu.RLock()
+	defer generators.mu.RUnlock()
+
+	candidates := make([]Generator, 0)
+	if ext := strings.ToLower(filepath.Ext(name)); ext != "" {
+		candidates = append(candidates, generators.exts[ext[1:]]...)
+	}
+
+	if mimeType = strings.ToLower(mimeType); mimeType != "" {
+		if i := strings.Index(mimeType, ";"); i >= 0 {
+			mimeType = strings.TrimSpace(mimeType[:i])
+		}
+		candidates = append(candidates, generators.mimes[mimeType]...)
+		if i := strings.Index(mimeType, "/"); i >= 0 {
+			candidates = append(candidates, generators.mimes[mimeType[:i]+"/*"]...)
+		}
+	}
+
+	res := make([]Generator, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.Enabled() && !containsGenerator(res, candidate) {
+			res = append(res, candidate)
+		}
+	}
+	return res
+}
+
+func containsGenerator(list []Generator, generator Generator) bool {
+	for _, g := range list {
+		if g == generator {
+			return true
+		}
+	}
+	return false
+}
+
+// Supported 是否有生成器可处理该文件
+func Supported(name, mimeType string) bool {
+	return len(GetGenerators(name, mimeType)) > 0
+}
+
+// Generate 依次尝试可处理该文件的生成器，open 用于每次尝试时重新获取文件内容
+func Generate(ctx context.Context, name, mimeType string, open func() (io.ReadCloser, error)) (*Thumb, error) {
+	candidates := GetGenerators(name, mimeType)
+	if len(candidates) == 0 {
+		return nil, ErrNotAvailable
+	}
+
+	var errs []string
+	for _, generator := range candidates {
+		file, err := open()
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := generator.Generate(ctx, file, name)
+		file.Close()
+		if err == nil {
+			return res, nil
+		}
+		errs = append(errs, err.Error())
+	}
+
+	return nil, fmt.Errorf("failed to generate thumbnail: %s", strings.Join(errs, "; "))
+}
+
+// builtinGenerator 使用内置解码器处理常见图像格式
+type builtinGenerator struct{}
+
+func (builtinGenerator) Generate(ctx context.Context, file io.Reader, name string) (*Thumb, error) {
+	img, err := decodeImage(file)
+	if err != nil {
+		return nil, err
+	}
+	return NewThumbFromImage(img), nil
+}
+
+// decodeImage 解码图像，解码前根据图像头信息检查像素数，避免超大图像耗尽内存
+func decodeImage(file io.Reader) (image.Image, error) {
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(file, &header))
+	if err != nil {
+		return nil, err
+	}
+
+	maxPixels := int64(models.GetIntSetting("thumb_max_pixels", 50000000))
+	if maxPixels > 0 && int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(io.MultiReader(&header, file))
+	return img, err
+}
+
+func (builtinGenerator) Enabled() bool {
+	return true
+}
+
+func init() {
+	Register(
+		builtinGenerator{},
+		[]string{"jpg", "jpeg", "png", "gif", "webp", "bmp", "tif", "tiff"},
+		[]string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp", "image/tiff"},
+	)
+	Register(
+		&ffmpegGenerator{},
+		[]string{"3g2", "3gp", "asf", "avi", "divx", "flv", "m2ts", "m2v", "m4v", "mkv", "mov", "mp4", "mpeg",
+			"mpg", "mts", "mxf", "ogv", "rm", "swf", "webm", "wmv"},
+		[]string{"video/*"},
+	)
+	Register(&pdfGenerator{}, []string{"pdf"}, []string{"application/pdf"})
+}
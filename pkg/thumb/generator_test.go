@@ -0,0 +1,58 @@
+package thumb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// encodePNG 生成 PNG 图像，width 与 height 不为 0 时改写文件头中的尺寸
+func encodePNG(t *testing.T, width, height uint32) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if width > 0 && height > 0 {
+		// IHDR 位于 8 字节的文件签名之后，数据部分以宽高开头
+		ihdr := data[8+8 : 8+8+13]
+		binary.BigEndian.PutUint32(ihdr[0:4], width)
+		binary.BigEndian.PutUint32(ihdr[4:8], height)
+		binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+	}
+	return data
+}
+
+func TestDecodeImage(t *testing.T) {
+	img, err := decodeImage(bytes.NewReader(encodePNG(t, 0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 4 || size.Y != 3 {
+		t.Errorf("decoded size = %v", size)
+	}
+
+	// 仅读取文件头即拒绝，不会为像素分配内存
+	if _, err := decodeImage(bytes.NewReader(encodePNG(t, 100000, 100000))); err != ErrImageTooLarge {
+		t.Errorf("err = %v, want %v", err, ErrImageTooLarge)
+	}
+
+	if _, err := decodeImage(bytes.NewReader([]byte("not an image"))); err == nil {
+		t.Error("expected error for invalid image")
+	}
+}
+
+func TestBuiltinGenerator_Generate(t *testing.T) {
+	res, err := builtinGenerator{}.Generate(context.Background(), bytes.NewReader(encodePNG(t, 0, 0)), "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res == nil {
+		t.Fatal("expected thumbnail")
+	}
+}
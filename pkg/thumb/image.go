@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/utils"
+	"golang.org/x/image/bmp"
 	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
 	"image"
 	"image/gif"
 	"image/jpeg"
@@ -37,6 +40,12 @@ func NewThumbFromFile(file io.Reader, name string) (*Thumb, error) {
 		img, err = gif.Decode(file)
 	case "png":
 		img, err = png.Decode(file)
+	case "webp":
+		img, err = webp.Decode(file)
+	case "bmp":
+		img, err = bmp.Decode(file)
+	case "tif", "tiff":
+		img, err = tiff.Decode(file)
 	default:
 		return nil, errors.New("Unknown image type")
 	}
@@ -79,6 +88,22 @@ func Resize(newWidth, newHeight uint, img image.Image) image.Image {
 	return dst
 }
 
+// NewThumbFromImage 从已解码的图像创建缩略图
+func NewThumbFromImage(img image.Image) *Thumb {
+	return &Thumb{src: img}
+}
+
+// GetSize 获取图像尺寸
+func (image *Thumb) GetSize() (int, int) {
+	b := image.src.Bounds()
+	return b.Max.X - b.Min.X, b.Max.Y - b.Min.Y
+}
+
+// GetThumb 将图像缩放至指定尺寸
+func (image *Thumb) GetThumb(width, height uint) {
+	image.src = Resize(width, height, image.src)
+}
+
 func (image *Thumb) Save(path string) (err error) {
 	out, err := utils.CreatNestedFile(path)
 
@@ -86,12 +111,15 @@ func (image *Thumb) Save(path string) (err error) {
 		return err
 	}
 	defer out.Close()
+	return image.Encode(out)
+}
+
+// Encode 按设定的编码方式将图像写入 w
+func (image *Thumb) Encode(w io.Writer) error {
 	switch models.GetSettingByNameWithDefault("thumb_encode_method", "jpg") {
 	case "png":
-		err = png.Encode(out, image.src)
+		return png.Encode(w, image.src)
 	default:
-		err = jpeg.Encode(out, image.src, &jpeg.Options{Quality: models.GetIntSetting("thumb_encode_quality", 85)})
+		return jpeg.Encode(w, image.src, &jpeg.Options{Quality: models.GetIntSetting("thumb_encode_quality", 85)})
 	}
-
-	return err
 }
@@ -0,0 +1,46 @@
+package thumb
+
+import (
+	"github.com/jylc/cloudserver/models"
+	"github.com/sirupsen/logrus"
+	"runtime"
+	"sync"
+)
+
+// Pool 限制同时进行的缩略图生成任务数量，超出的任务排队等待
+type Pool struct {
+	worker chan struct{}
+}
+
+var (
+	pool     *Pool
+	poolOnce sync.Once
+)
+
+// GetPool 获取缩略图任务池，容量由 thumb_max_task_count 决定，不大于 0 时使用 CPU 核心数
+func GetPool() *Pool {
+	poolOnce.Do(func() {
+		maxWorker := models.GetIntSetting("thumb_max_task_count", -1)
+		if maxWorker <= 0 {
+			maxWorker = runtime.GOMAXPROCS(0)
+		}
+		pool = &Pool{worker: make(chan struct{}, maxWorker)}
+		logrus.Debugf("Initialize thumbnail task pool with %d workers", maxWorker)
+	})
+	return pool
+}
+
+// Submit 等待空闲位置后执行任务，任务完成后按设定触发 GC
+func (pool *Pool) Submit(task func()) {
+	pool.worker <- struct{}{}
+	defer func() {
+		<-pool.worker
+	}()
+
+	task()
+
+	if models.IsTrueVal(models.GetSettingByName("thumb_gc_after_gen")) {
+		logrus.Debugf("Run GC after thumbnail generation")
+		runtime.GC()
+	}
+}
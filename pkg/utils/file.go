@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 )
@@ -23,3 +25,22 @@ func CreateNestedFile(path string) (*os.File, error) {
 	}
 	return os.Create(path)
 }
+
+// CreateTempFile 将内容写入 dir 下的临时文件并返回文件路径，供只能处理本地文件的外部程序使用
+func CreateTempFile(dir, pattern string, content io.Reader) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	tempFile, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	defer tempFile.Close()
+
+	if _, err := io.Copy(tempFile, content); err != nil {
+		os.Remove(tempFile.Name())
+		return "", err
+	}
+	return tempFile.Name(), nil
+}
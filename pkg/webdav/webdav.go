@@ -302,7 +302,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	// 尝试作为文件删除
 	if ok, file := fs.IsFileExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{}, []uint{file.ID}, false); err != nil {
+		if err := fs.Trash(ctx, []uint{}, []uint{file.ID}); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
@@ -310,7 +310,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	// 尝试作为目录删除
 	if ok, folder := fs.IsPathExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{folder.ID}, []uint{}, false); err != nil {
+		if err := fs.Trash(ctx, []uint{folder.ID}, []uint{}); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
@@ -356,28 +356,49 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 	if exist {
 		// 已存在，为更新操作
 
-		// 检查此文件是否有软链接
-		fileList, err := models.RemoveFilesWithSoftLinks([]models.File{*originFile})
-		if err == nil && len(fileList) == 0 {
-			// 如果包含软连接，应重新生成新文件副本，并更新source_name
+		if fs.User.Group.OptionsSerialized.MaxVersions > 0 {
+			// 保留历史版本时新内容写入新的物理文件，原有内容转为历史版本
+			fs.Use("AfterUpload", filesystem.HookKeepVersion(*originFile))
 			originFile.SourceName = fs.GenerateSavePath(ctx, &fileData)
-			fileData.Mode &= ^fsctx.Overwrite
+			fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+			fs.Use("BeforeUpload", filesystem.HookValidateFile)
+			fs.Use("BeforeUpload", filesystem.HookValidateVersionCapacity)
+			fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+			fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
 			fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
-			fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
-			fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
-		}
+			fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+			fs.Use("AfterUpload", filesystem.HookExtractMetadata)
+			fs.Use("AfterUpload", filesystem.HookIndexContent)
+			fs.Use("AfterUpload", filesystem.HookRecordRecent(models.RecentActionEdit))
+			fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+			ctx = context.WithValue(ctx, fsctx.FileModelCtx, *originFile)
+		} else {
+			// 检查此文件是否有软链接
+			fileList, err := models.RemoveFilesWithSoftLinks([]models.File{*originFile})
+			if err == nil && len(fileList) == 0 {
+				// 如果包含软连接，应重新生成新文件副本，并更新source_name
+				originFile.SourceName = fs.GenerateSavePath(ctx, &fileData)
+				fileData.Mode &= ^fsctx.Overwrite
+				fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
+				fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
+				fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+			}
 
-		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
-		fs.Use("BeforeUpload", filesystem.HookValidateFile)
-		fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
-		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
-		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
-		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
-		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
-		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
-		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
-		ctx = context.WithValue(ctx, fsctx.FileModelCtx, *originFile)
-		fileData.Mode |= fsctx.Overwrite
+			fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+			fs.Use("BeforeUpload", filesystem.HookValidateFile)
+			fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
+			fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
+			fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+			fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
+			fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+			fs.Use("AfterUpload", filesystem.HookExtractMetadata)
+			fs.Use("AfterUpload", filesystem.HookIndexContent)
+			fs.Use("AfterUpload", filesystem.HookRecordRecent(models.RecentActionEdit))
+			fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
+			fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+			ctx = context.WithValue(ctx, fsctx.FileModelCtx, *originFile)
+			fileData.Mode |= fsctx.Overwrite
+		}
 	} else {
 		// 给文件系统分配钩子
 		fs.Use("BeforeUpload", filesystem.HookValidateFile)
@@ -386,6 +407,9 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
 		fs.Use("AfterUpload", filesystem.GenericAfterUpload)
 		fs.Use("AfterUpload", filesystem.HookGenerateThumb)
+		fs.Use("AfterUpload", filesystem.HookExtractMetadata)
+		fs.Use("AfterUpload", filesystem.HookIndexContent)
+		fs.Use("AfterUpload", filesystem.HookRecordRecent(models.RecentActionUpload))
 		fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
 	}
 
@@ -180,6 +180,38 @@ func AdminGetPolicy(c *gin.Context) {
 	}
 }
 
+func AdminRotatePolicyKey(c *gin.Context) {
+	var service admin.PolicyService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.RotateKey(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminRepairMirrorPolicy 创建镜像副本修复任务
+func AdminRepairMirrorPolicy(c *gin.Context) {
+	var service admin.PolicyService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.RepairMirror(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminMigratePolicy 创建存储策略迁移任务
+func AdminMigratePolicy(c *gin.Context) {
+	var service admin.PolicyMigrateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Migrate(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 func AdminDeletePolicy(c *gin.Context) {
 	var service admin.PolicyService
 	if err := c.ShouldBindUri(&service); err == nil {
@@ -386,6 +418,56 @@ func AdminCreateImportTask(c *gin.Context) {
 	}
 }
 
+// AdminRebuildSearchIndex 重建全文索引
+func AdminRebuildSearchIndex(c *gin.Context) {
+	res := admin.CreateIndexRebuildTask(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// AdminCreateScrubTask 创建存储巡检任务
+func AdminCreateScrubTask(c *gin.Context) {
+	var service admin.ScrubTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminCreateReconcileTask 创建存储对账任务
+func AdminCreateReconcileTask(c *gin.Context) {
+	var service admin.ReconcileTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminRecalculateStorage 创建容量重新计算任务
+func AdminRecalculateStorage(c *gin.Context) {
+	var service admin.StorageRecalcService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminListScrubIssues 列出存储巡检及对账报告
+func AdminListScrubIssues(c *gin.Context) {
+	var service admin.ListService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.ScrubIssues()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 func AdminListFolders(c *gin.Context) {
 	var service admin.ListFolderService
 	if err := c.ShouldBindUri(&service); err == nil {
@@ -445,3 +527,30 @@ func AdminGetNode(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// AdminListLifecycleRules 列出生命周期规则
+func AdminListLifecycleRules(c *gin.Context) {
+	c.JSON(200, admin.ListLifecycleRules())
+}
+
+// AdminAddLifecycleRule 添加或更新生命周期规则
+func AdminAddLifecycleRule(c *gin.Context) {
+	var service admin.AddLifecycleRuleService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Add()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminDeleteLifecycleRule 删除生命周期规则
+func AdminDeleteLifecycleRule(c *gin.Context) {
+	var service admin.LifecycleRuleService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
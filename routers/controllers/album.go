@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/service/explorer"
+)
+
+// PhotoTimeline 按天分组列出照片
+func PhotoTimeline(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TimelineService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Timeline(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListAlbums 列出相册
+func ListAlbums(c *gin.Context) {
+	c.JSON(200, explorer.ListAlbums(c, CurrentUser(c)))
+}
+
+// CreateAlbum 创建相册
+func CreateAlbum(c *gin.Context) {
+	var service explorer.AlbumCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RenameAlbum 重命名相册
+func RenameAlbum(c *gin.Context) {
+	var service explorer.AlbumCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Rename(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteAlbum 删除相册
+func DeleteAlbum(c *gin.Context) {
+	c.JSON(200, explorer.DeleteAlbum(c, CurrentUser(c)))
+}
+
+// ListAlbumFiles 列出相册中的文件
+func ListAlbumFiles(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.AlbumListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(ctx, c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AddAlbumFiles 将文件加入相册
+func AddAlbumFiles(c *gin.Context) {
+	var service explorer.AlbumFilesService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Add(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RemoveAlbumFiles 将文件移出相册
+func RemoveAlbumFiles(c *gin.Context) {
+	var service explorer.AlbumFilesService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Remove(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -2,7 +2,11 @@ package controllers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/service/callback"
+	"net/http"
+	"path"
+	"strconv"
 )
 
 func RemoteCallback(c *gin.Context) {
@@ -14,3 +18,46 @@ func RemoteCallback(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+func S3Callback(c *gin.Context) {
+	var callbackBody callback.S3Callback
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res := callbackBody.PreProcess(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// OneDriveCallback 客户端上传完成后通知，请求体为空
+func OneDriveCallback(c *gin.Context) {
+	var callbackBody callback.OneDriveCallback
+	c.JSON(200, callbackBody.PreProcess(c))
+}
+
+func OneDriveOAuth(c *gin.Context) {
+	var callbackBody callback.OneDriveOauthService
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res := callbackBody.Auth(c)
+		redirect := models.GetSiteURL()
+		redirect.Path = path.Join(redirect.Path, "/admin/policy")
+		queries := redirect.Query()
+		queries.Add("code", strconv.Itoa(res.Code))
+		queries.Add("msg", res.Msg)
+		queries.Add("err", res.Error)
+		redirect.RawQuery = queries.Encode()
+		c.Redirect(http.StatusMovedPermanently, redirect.String())
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+func COSCallback(c *gin.Context) {
+	var callbackBody callback.COSCallback
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res := callbackBody.PreProcess(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/service/explorer"
+)
+
+// ListFavorites 列出收藏的文件及目录
+func ListFavorites(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c.JSON(200, explorer.ListFavorites(ctx, c))
+}
+
+// AddFavorites 收藏文件及目录
+func AddFavorites(c *gin.Context) {
+	var service explorer.FavoriteService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Add(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RemoveFavorites 取消收藏文件及目录
+func RemoveFavorites(c *gin.Context) {
+	var service explorer.FavoriteService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Remove(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListRecentFiles 列出最近访问的文件
+func ListRecentFiles(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c.JSON(200, explorer.ListRecent(ctx, c))
+}
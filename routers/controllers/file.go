@@ -296,3 +296,14 @@ func SearchFile(c *gin.Context) {
 	res := service.Search(c)
 	c.JSON(200, res)
 }
+
+// QueryFile 结构化搜索文件
+func QueryFile(c *gin.Context) {
+	var service explorer.ItemQueryService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Search(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
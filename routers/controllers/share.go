@@ -208,3 +208,47 @@ func DeleteShare(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// ListSharedAlbum 列出分享相册中的文件
+func ListSharedAlbum(c *gin.Context) {
+	var service share.AlbumListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// GetSharedAlbumDownload 创建分享相册中文件的下载会话
+func GetSharedAlbumDownload(c *gin.Context) {
+	var service share.AlbumFileService
+	res := service.CreateDownloadSession(c)
+	c.JSON(200, res)
+}
+
+// PreviewSharedAlbum 预览分享相册中的文件
+func PreviewSharedAlbum(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service share.AlbumFileService
+	res := service.PreviewContent(ctx, c)
+	if res.Code == -301 {
+		c.Redirect(302, res.Data.(string))
+		return
+	}
+
+	if res.Code != 0 {
+		c.JSON(200, res)
+	}
+}
+
+// SharedAlbumThumb 获取分享相册中文件的缩略图
+func SharedAlbumThumb(c *gin.Context) {
+	var service share.AlbumFileService
+	res := service.Thumb(c)
+	if res.Code >= 0 {
+		c.JSON(200, res)
+	}
+}
@@ -68,3 +68,74 @@ func SlaveGetOneDriveCredential(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+func SlaveDownload(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveDownloadService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.ServeFile(ctx, c, true)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+func SlavePreview(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveDownloadService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.ServeFile(ctx, c, false)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+func SlaveThumb(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveFileService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Thumb(ctx, c)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+func SlaveDelete(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveFilesService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+func SlaveList(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveListService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.List(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
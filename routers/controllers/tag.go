@@ -1,6 +1,9 @@
 package controllers
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/service/explorer"
+)
 
 func CreateFilterTag(c *gin.Context) {
 	var service explorer.FilterTagCreateService
@@ -31,3 +34,47 @@ func DeleteTag(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// CreateSavedSearch 保存结构化搜索
+func CreateSavedSearch(c *gin.Context) {
+	var service explorer.SavedSearchCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateLabel 创建手动标签
+func CreateLabel(c *gin.Context) {
+	var service explorer.LabelCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AddObjectTags 为目录和文件添加手动标签
+func AddObjectTags(c *gin.Context) {
+	var service explorer.ObjectTagService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Add(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RemoveObjectTags 移除目录和文件的手动标签
+func RemoveObjectTags(c *gin.Context) {
+	var service explorer.ObjectTagService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Remove(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
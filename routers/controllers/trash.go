@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/service/explorer"
+)
+
+// ListTrash 列出回收站
+func ListTrash(c *gin.Context) {
+	var service explorer.TrashListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RestoreTrash 还原回收站中的对象
+func RestoreTrash(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TrashItemService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Restore(ctx, c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// PurgeTrash 永久删除回收站中的对象
+func PurgeTrash(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TrashItemService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Purge(ctx, c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/pkg/request"
+	"github.com/jylc/cloudserver/service/explorer"
+	"net/http"
+)
+
+// tusHandler 校验协议版本并输出 tus 响应，OPTIONS 请求无需携带版本
+func tusHandler(c *gin.Context, handler func(ctx context.Context) explorer.TusResponse) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c.Header("Tus-Resumable", explorer.TusVersion)
+	res := explorer.TusResponse{
+		Status:  http.StatusPreconditionFailed,
+		Headers: map[string]string{"Tus-Version": explorer.TusVersion},
+		Message: "Unsupported tus version",
+	}
+	if c.Request.Method == http.MethodOptions || c.GetHeader("Tus-Resumable") == explorer.TusVersion {
+		res = handler(ctx)
+	}
+
+	for key, value := range res.Headers {
+		c.Header(key, value)
+	}
+
+	if res.Message != "" && c.Request.Method != http.MethodHead {
+		c.String(res.Status, res.Message)
+		return
+	}
+	c.Status(res.Status)
+}
+
+// TusOptions 查询 tus 服务端信息
+func TusOptions(c *gin.Context) {
+	tusHandler(c, func(ctx context.Context) explorer.TusResponse {
+		return explorer.TusOptions(c, CurrentUser(c))
+	})
+}
+
+// TusCreate 创建 tus 上传
+func TusCreate(c *gin.Context) {
+	tusHandler(c, func(ctx context.Context) explorer.TusResponse {
+		return explorer.TusCreate(ctx, c)
+	})
+}
+
+// TusHead 查询 tus 上传进度
+func TusHead(c *gin.Context) {
+	tusHandler(c, func(ctx context.Context) explorer.TusResponse {
+		var service explorer.TusUploadService
+		if err := c.ShouldBindUri(&service); err != nil {
+			return explorer.TusResponse{Status: http.StatusNotFound}
+		}
+		return service.Head(ctx, c)
+	})
+}
+
+// TusPatch 上传 tus 文件内容
+func TusPatch(c *gin.Context) {
+	tusHandler(c, func(ctx context.Context) explorer.TusResponse {
+		var service explorer.TusUploadService
+		if err := c.ShouldBindUri(&service); err != nil {
+			return explorer.TusResponse{Status: http.StatusNotFound}
+		}
+		return service.Patch(ctx, c)
+	})
+	request.BlackHole(c.Request.Body)
+}
+
+// TusDelete 终止 tus 上传
+func TusDelete(c *gin.Context) {
+	tusHandler(c, func(ctx context.Context) explorer.TusResponse {
+		var service explorer.TusUploadService
+		if err := c.ShouldBindUri(&service); err != nil {
+			return explorer.TusResponse{Status: http.StatusNotFound}
+		}
+		return service.Delete(ctx, c)
+	})
+}
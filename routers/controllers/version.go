@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/service/explorer"
+)
+
+// ListFileVersions 列出文件历史版本
+func ListFileVersions(c *gin.Context) {
+	var service explorer.FileIDService
+	res := service.ListVersions(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// DownloadFileVersion 下载文件历史版本
+func DownloadFileVersion(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.VersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Download(ctx, c, CurrentUser(c))
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RestoreFileVersion 恢复文件至历史版本
+func RestoreFileVersion(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.VersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Restore(ctx, c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteFileVersion 删除文件历史版本
+func DeleteFileVersion(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.VersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(ctx, c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
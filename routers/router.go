@@ -86,13 +86,24 @@ func RouterInit() *gin.Engine {
 		slave.Use(middleware.SlaveRPCSignRequired(cluster.Default))
 		{
 			slave.PUT("notification/:subject", controllers.SlaveNotificationPush)
-			upload := slave.Group("upload")
+			slave.GET("credential/onedrive/:id", controllers.SlaveGetOneDriveCredential)
+		}
+
+		// 主机调用从机存储策略的接口，使用从机密钥签名
+		slaveStorage := version.Group("slave")
+		slaveStorage.Use(middleware.SignRequired(auth.General))
+		{
+			upload := slaveStorage.Group("upload")
 			{
 				upload.POST(":sessionId", controllers.SlaveUpload)
 				upload.PUT("", controllers.SlaveGetUploadSession)
 				upload.DELETE(":sessionId", controllers.SlaveDeleteUploadSession)
 			}
-			slave.GET("credential/onedrive/:id", controllers.SlaveGetOneDriveCredential)
+			slaveStorage.GET("download/:speed/:path/:name", controllers.SlaveDownload)
+			slaveStorage.GET("source/:speed/:path/:name", controllers.SlavePreview)
+			slaveStorage.GET("thumb/:path", controllers.SlaveThumb)
+			slaveStorage.POST("delete", controllers.SlaveDelete)
+			slaveStorage.POST("list", controllers.SlaveList)
 		}
 
 		callback := version.Group("callback")
@@ -101,6 +112,20 @@ func RouterInit() *gin.Engine {
 				middleware.UseUploadSession("remote"),
 				middleware.RemoteCallbackAuth(),
 				controllers.RemoteCallback)
+			callback.GET("cos/:sessionID",
+				middleware.UseUploadSession("cos"),
+				controllers.COSCallback)
+			onedrive := callback.Group("onedrive")
+			{
+				onedrive.GET("auth", controllers.OneDriveOAuth)
+				onedrive.POST("finish/:sessionID",
+					middleware.UseUploadSession("onedrive"),
+					controllers.OneDriveCallback)
+			}
+			callback.GET("s3/:sessionID",
+				middleware.SignRequired(auth.General),
+				middleware.UseUploadSession("s3"),
+				controllers.S3Callback)
 		}
 
 		share := version.Group("share", middleware.ShareAvailable())
@@ -109,6 +134,7 @@ func RouterInit() *gin.Engine {
 
 			share.PUT("download/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareNotAlbum(),
 				middleware.BeforeShareDownload(),
 				controllers.GetShareDownload,
 			)
@@ -116,6 +142,7 @@ func RouterInit() *gin.Engine {
 			share.GET("preview/:id",
 				middleware.CSRFCheck(),
 				middleware.CheckShareUnlocked(),
+				middleware.ShareNotAlbum(),
 				middleware.ShareCanPreview(),
 				middleware.BeforeShareDownload(),
 				controllers.PreviewShare,
@@ -123,6 +150,7 @@ func RouterInit() *gin.Engine {
 
 			share.GET("doc/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareNotAlbum(),
 				middleware.ShareCanPreview(),
 				middleware.BeforeShareDownload(),
 				controllers.GetShareDocPreview,
@@ -130,37 +158,67 @@ func RouterInit() *gin.Engine {
 
 			share.GET("content/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareNotAlbum(),
 				middleware.BeforeShareDownload(),
 				controllers.PreviewShareText,
 			)
 
 			share.GET("list/:id/*path",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareNotAlbum(),
 				controllers.ListSharedFolder,
 			)
 
 			share.GET("search/:id/:type/:keywords",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareNotAlbum(),
 				controllers.SearchSharedFolder,
 			)
 
 			share.POST("archive/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareNotAlbum(),
 				middleware.BeforeShareDownload(),
 				controllers.ArchiveShare,
 			)
 
 			share.GET("readme/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareNotAlbum(),
 				controllers.PreviewShareReadme,
 			)
 
 			share.GET("thumb/:id/:file",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareNotAlbum(),
 				middleware.ShareCanPreview(),
 				controllers.ShareThumb,
 			)
 
+			album := share.Group("album")
+			{
+				album.GET(":id",
+					middleware.CheckShareUnlocked(),
+					controllers.ListSharedAlbum,
+				)
+				album.PUT(":id/download/:file",
+					middleware.CheckShareUnlocked(),
+					middleware.BeforeShareDownload(),
+					controllers.GetSharedAlbumDownload,
+				)
+				album.GET(":id/preview/:file",
+					middleware.CheckShareUnlocked(),
+					middleware.ShareCanPreview(),
+					middleware.BeforeShareDownload(),
+					controllers.PreviewSharedAlbum,
+				)
+				album.GET(":id/thumb/:file",
+					middleware.CheckShareUnlocked(),
+					middleware.ShareCanPreview(),
+					controllers.SharedAlbumThumb,
+				)
+			}
+
 			version.Group("share").GET("search", controllers.SearchShare)
 		}
 
@@ -190,11 +248,21 @@ func RouterInit() *gin.Engine {
 					policy.POST("", controllers.AdminAddPolicy)
 					policy.POST("cors", controllers.AdminAddCORS)
 					policy.POST("scf", controllers.AdminAddSCF)
+					policy.POST("migrate", controllers.AdminMigratePolicy)
 					policy.GET(":id/oauth", controllers.AdminOneDriveOAuth)
+					policy.POST(":id/rotate", controllers.AdminRotatePolicyKey)
+					policy.POST(":id/repair", controllers.AdminRepairMirrorPolicy)
 					policy.GET(":id", controllers.AdminGetPolicy)
 					policy.DELETE(":id", controllers.AdminDeletePolicy)
 				}
 
+				lifecycle := admin.Group("lifecycle")
+				{
+					lifecycle.GET("", controllers.AdminListLifecycleRules)
+					lifecycle.POST("", controllers.AdminAddLifecycleRule)
+					lifecycle.DELETE(":id", controllers.AdminDeleteLifecycleRule)
+				}
+
 				group := admin.Group("group")
 				{
 					group.POST("list", controllers.AdminListGroup)
@@ -237,6 +305,11 @@ func RouterInit() *gin.Engine {
 					task.POST("list", controllers.AdminListTask)
 					task.POST("delete", controllers.AdminDeleteTask)
 					task.POST("import", controllers.AdminCreateImportTask)
+					task.POST("index", controllers.AdminRebuildSearchIndex)
+					task.POST("scrub", controllers.AdminCreateScrubTask)
+					task.POST("scrub/report", controllers.AdminListScrubIssues)
+					task.POST("reconcile", controllers.AdminCreateReconcileTask)
+					task.POST("storage", controllers.AdminRecalculateStorage)
 				}
 
 				node := admin.Group("node")
@@ -283,6 +356,15 @@ func RouterInit() *gin.Engine {
 					upload.DELETE("", controllers.DeleteAllUploadSession)
 				}
 
+				tus := file.Group("tus")
+				{
+					tus.OPTIONS("", controllers.TusOptions)
+					tus.POST("", controllers.TusCreate)
+					tus.HEAD(":sessionId", controllers.TusHead)
+					tus.PATCH(":sessionId", controllers.TusPatch)
+					tus.DELETE(":sessionId", controllers.TusDelete)
+				}
+
 				file.PUT("update/:id", controllers.PutContent)
 				file.POST("create", controllers.CreateFile)
 				file.PUT("download/:id", controllers.CreateDownloadSession)
@@ -295,6 +377,12 @@ func RouterInit() *gin.Engine {
 				file.POST("compress", controllers.Compress)
 				file.POST("decompress", controllers.Decompress)
 				file.GET("search/:type/:keywords", controllers.SearchFile)
+				file.GET("query", controllers.QueryFile)
+				file.GET("recent", controllers.ListRecentFiles)
+				file.GET("versions/:id", controllers.ListFileVersions)
+				file.GET("versions/:id/:version", controllers.DownloadFileVersion)
+				file.POST("versions/:id/:version", controllers.RestoreFileVersion)
+				file.DELETE("versions/:id/:version", controllers.DeleteFileVersion)
 			}
 
 			aria2 := auth.Group("aria2")
@@ -320,6 +408,38 @@ func RouterInit() *gin.Engine {
 				object.POST("copy", controllers.Copy)
 				object.POST("rename", controllers.Rename)
 				object.GET("property/:id", controllers.GetProperty)
+				object.POST("tag", controllers.AddObjectTags)
+				object.DELETE("tag", controllers.RemoveObjectTags)
+			}
+
+			favorite := auth.Group("favorite")
+			{
+				favorite.GET("", controllers.ListFavorites)
+				favorite.POST("", controllers.AddFavorites)
+				favorite.DELETE("", controllers.RemoveFavorites)
+			}
+
+			trash := auth.Group("trash")
+			{
+				trash.GET("", controllers.ListTrash)
+				trash.POST("restore", controllers.RestoreTrash)
+				trash.DELETE("", controllers.PurgeTrash)
+			}
+
+			photo := auth.Group("photo")
+			{
+				photo.GET("timeline", controllers.PhotoTimeline)
+			}
+
+			album := auth.Group("album", middleware.HashID(hashid.AlbumID))
+			{
+				album.GET("", controllers.ListAlbums)
+				album.POST("", controllers.CreateAlbum)
+				album.PATCH(":id", controllers.RenameAlbum)
+				album.DELETE(":id", controllers.DeleteAlbum)
+				album.GET(":id", controllers.ListAlbumFiles)
+				album.POST(":id/files", controllers.AddAlbumFiles)
+				album.DELETE(":id/files", controllers.RemoveAlbumFiles)
 			}
 
 			share := auth.Group("share")
@@ -338,6 +458,8 @@ func RouterInit() *gin.Engine {
 			{
 				tag.POST("filter", controllers.CreateFilterTag)
 				tag.POST("link", controllers.CreateLinkTag)
+				tag.POST("search", controllers.CreateSavedSearch)
+				tag.POST("label", controllers.CreateLabel)
 				tag.DELETE(":id", middleware.HashID(hashid.TagID), controllers.DeleteTag)
 			}
 
@@ -8,6 +8,7 @@ import (
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/serializer"
 	"github.com/jylc/cloudserver/service/explorer"
+	"github.com/sirupsen/logrus"
 	"strings"
 )
 
@@ -104,19 +105,22 @@ func (service *FileBatchService) Delete(c *gin.Context) serializer.Response {
 		for uid, file := range files {
 			user, err := models.GetUserByID(uid)
 			if err != nil {
+				logrus.Warningf("The owner of files to be deleted does not exist,%s", err)
 				continue
 			}
 
 			fs, err := filesystem.NewFileSystem(&user)
 			if err != nil {
-				fs.Recycle()
+				logrus.Warningf("Unable to initialize file system,%s", err)
 				continue
 			}
 			ids := make([]uint, 0, len(file))
 			for i := 0; i < len(file); i++ {
 				ids = append(ids, file[i].ID)
 			}
-			fs.Delete(context.Background(), []uint{}, ids, service.Force)
+			if err := fs.Delete(context.Background(), []uint{}, ids, service.Force); err != nil {
+				logrus.Warningf("Unable to delete files of user [%d],%s", uid, err)
+			}
 			fs.Recycle()
 		}
 	}(userFile)
@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/serializer"
+)
+
+// AddLifecycleRuleService 添加或更新生命周期规则服务
+type AddLifecycleRuleService struct {
+	Rule models.LifecycleRule `json:"rule" binding:"required"`
+}
+
+// LifecycleRuleService 生命周期规则服务
+type LifecycleRuleService struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+// ListLifecycleRules 列出所有生命周期规则
+func ListLifecycleRules() serializer.Response {
+	rules, err := models.GetLifecycleRules()
+	if err != nil {
+		return serializer.DBErr("Unable to list lifecycle rules", err)
+	}
+	return serializer.Response{Data: rules}
+}
+
+// Add 添加或更新生命周期规则
+func (service *AddLifecycleRuleService) Add() serializer.Response {
+	rule := &service.Rule
+	if rule.Days <= 0 {
+		return serializer.ParamErr("Days must be greater than 0", nil)
+	}
+
+	switch rule.Action {
+	case models.LifecycleTransition:
+		if _, err := models.GetPolicyByID(rule.TargetPolicyID); err != nil {
+			return serializer.Err(serializer.CodeNotFound, "Storage policy does not exist", nil)
+		}
+		if rule.TargetPolicyID == rule.PolicyID {
+			return serializer.ParamErr("Target storage policy must be different from the source", nil)
+		}
+	case models.LifecycleExpire:
+		rule.TargetPolicyID = 0
+	default:
+		return serializer.ParamErr("Unknown lifecycle action", nil)
+	}
+
+	if rule.ID > 0 {
+		// 条件可能已改变，从头开始评估，但仍须等待已创建的任务结束
+		var origin models.LifecycleRule
+		if err := models.Db.First(&origin, rule.ID).Error; err != nil {
+			return serializer.Err(serializer.CodeNotFound, "Lifecycle rule does not exist", nil)
+		}
+		rule.Cursor, rule.TaskIDs = 0, origin.TaskIDs
+
+		if err := models.Db.Save(rule).Error; err != nil {
+			return serializer.DBErr("Lifecycle rule save failed", err)
+		}
+	} else if err := models.Db.Create(rule).Error; err != nil {
+		return serializer.DBErr("Lifecycle rule addition failed", err)
+	}
+	return serializer.Response{Data: rule.ID}
+}
+
+// Delete 删除生命周期规则
+func (service *LifecycleRuleService) Delete() serializer.Response {
+	if err := models.DeleteLifecycleRule(service.ID); err != nil {
+		return serializer.DBErr("Unable to delete lifecycle rule", err)
+	}
+	return serializer.Response{}
+}
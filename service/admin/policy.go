@@ -4,15 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/auth"
 	"github.com/jylc/cloudserver/pkg/cache"
 	"github.com/jylc/cloudserver/pkg/filesystem/driver/cos"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/encrypt"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/mirror"
 	"github.com/jylc/cloudserver/pkg/filesystem/driver/onedrive"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/sftp"
 	"github.com/jylc/cloudserver/pkg/request"
 	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/jylc/cloudserver/pkg/task"
 	"github.com/jylc/cloudserver/pkg/utils"
 	"net/url"
 	"os"
@@ -39,10 +44,32 @@ type PolicyService struct {
 	Region string `json:"region"`
 }
 
+// PolicyMigrateService 存储策略迁移服务
+type PolicyMigrateService struct {
+	Src uint `json:"src" binding:"required"`
+	Dst uint `json:"dst" binding:"required,nefield=Src"`
+}
+
 func (service *AddPolicyService) Add() serializer.Response {
 	if service.Policy.Type != "local" && service.Policy.Type != "remote" {
 		service.Policy.DirNameRule = strings.TrimPrefix(service.Policy.DirNameRule, "/")
 	}
+
+	if service.Policy.Type == "sftp" {
+		if _, err := sftp.NewDriver(&service.Policy); err != nil {
+			return serializer.ParamErr("Invalid SFTP host key", err)
+		}
+	}
+
+	if service.Policy.Type == "mirror" {
+		if err := service.checkReplicas(); err != nil {
+			return serializer.ParamErr("Invalid replica policies", err)
+		}
+	}
+
+	if err := service.prepareEncryption(); err != nil {
+		return serializer.ParamErr("Unable to set up encryption", err)
+	}
 	if service.Policy.ID > 0 {
 		if err := models.Db.Save(&service.Policy).Error; err != nil {
 			return serializer.ParamErr("Storage policy save failed", err)
@@ -57,6 +84,52 @@ func (service *AddPolicyService) Add() serializer.Response {
 	return serializer.Response{Data: service.Policy.ID}
 }
 
+// checkReplicas 检查镜像存储策略的副本存储策略均存在，且不是镜像存储策略
+func (service *AddPolicyService) checkReplicas() error {
+	replicas := service.Policy.OptionsSerialized.Replicas
+	if len(replicas) < 2 {
+		return mirror.ErrNotEnoughReplicas
+	}
+
+	for _, id := range replicas {
+		replica, err := models.GetPolicyByID(id)
+		if err != nil {
+			return fmt.Errorf("replica policy %d not found", id)
+		}
+
+		if replica.ID == service.Policy.ID || replica.Type == "mirror" {
+			return mirror.ErrInvalidReplica
+		}
+	}
+	return nil
+}
+
+// prepareEncryption 首次开启加密时生成数据密钥，已有的数据密钥只能通过轮换任务修改
+func (service *AddPolicyService) prepareEncryption() error {
+	option := service.Policy.OptionsSerialized.Encryption
+	if service.Policy.ID > 0 {
+		origin, err := models.GetPolicyByID(service.Policy.ID)
+		if err == nil && origin.OptionsSerialized.Encryption != nil && len(origin.OptionsSerialized.Encryption.Keys) > 0 {
+			if option == nil || !option.Enabled {
+				return errors.New("encryption cannot be disabled once files have been encrypted")
+			}
+
+			option.KeyWrapping = origin.OptionsSerialized.Encryption.KeyWrapping
+			option.KeyVersion = origin.OptionsSerialized.Encryption.KeyVersion
+			option.Keys = origin.OptionsSerialized.Encryption.Keys
+			return nil
+		}
+	}
+
+	if option != nil && option.Enabled {
+		option.Keys = nil
+		option.KeyVersion = 0
+		return encrypt.NewKey(&service.Policy)
+	}
+
+	return nil
+}
+
 func (service *ListService) Policies() serializer.Response {
 	var res []models.Policy
 	total := int64(0)
@@ -196,6 +269,62 @@ func (service *PolicyService) Get() serializer.Response {
 	return serializer.Response{Data: policy}
 }
 
+// RotateKey 创建密钥轮换任务
+func (service *PolicyService) RotateKey(c *gin.Context, user *models.User) serializer.Response {
+	policy, err := models.GetPolicyByID(uint(service.ID))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Storage policy does not exist", nil)
+	}
+
+	if !policy.IsEncrypted() {
+		return serializer.ParamErr("Encryption is not enabled for this storage policy", nil)
+	}
+
+	job, err := task.NewKeyRotateTask(user.ID, policy.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Task creation failed", err)
+	}
+	task.TaskPool.Submit(job)
+	return serializer.Response{}
+}
+
+// RepairMirror 创建镜像副本修复任务
+func (service *PolicyService) RepairMirror(c *gin.Context, user *models.User) serializer.Response {
+	policy, err := models.GetPolicyByID(uint(service.ID))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Storage policy does not exist", nil)
+	}
+
+	if policy.Type != "mirror" {
+		return serializer.ParamErr("This storage policy is not a mirror policy", nil)
+	}
+
+	job, err := task.NewMirrorRepairTask(user.ID, []uint{policy.ID})
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Task creation failed", err)
+	}
+	task.TaskPool.Submit(job)
+	return serializer.Response{}
+}
+
+// Migrate 创建存储策略迁移任务，将源存储策略下的所有文件迁移至目标存储策略
+func (service *PolicyMigrateService) Migrate(c *gin.Context, user *models.User) serializer.Response {
+	if _, err := models.GetPolicyByID(service.Src); err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Storage policy does not exist", nil)
+	}
+
+	if _, err := models.GetPolicyByID(service.Dst); err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Storage policy does not exist", nil)
+	}
+
+	job, err := task.NewPolicyMigrateTask(user.ID, service.Src, service.Dst, nil)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Task creation failed", err)
+	}
+	task.TaskPool.Submit(job)
+	return serializer.Response{}
+}
+
 func (service *PolicyService) Delete() serializer.Response {
 	if service.ID == 1 {
 		return serializer.Err(serializer.CodeNoPermissionErr, "The default storage policy cannot be deleted", nil)
@@ -207,12 +336,22 @@ func (service *PolicyService) Delete() serializer.Response {
 	}
 
 	total := 0
-	row := models.Db.Model(&models.File{}).Where("policy_id = ?", service.ID).Select("count(id)").Row()
+	// 回收站中的文件同样使用此存储策略
+	row := models.Db.Unscoped().Model(&models.File{}).Where("policy_id = ?", service.ID).Select("count(id)").Row()
 	row.Scan(&total)
 	if total > 0 {
 		return serializer.ParamErr(fmt.Sprintf("There are %d files still using this storage policy. Please delete these files first", total), nil)
 	}
 
+	// 副本存储策略下的文件记录属于镜像存储策略，须单独检查
+	mirrors, err := models.GetMirrorsByReplica(policy.ID)
+	if err != nil {
+		return serializer.DBErr("Unable to list mirror policies", err)
+	}
+	if len(mirrors) > 0 {
+		return serializer.ParamErr(fmt.Sprintf("This storage policy is used as a replica by mirror policy %s, please remove it from the replicas first", mirrors[0].Name), nil)
+	}
+
 	var groups []models.Group
 	models.Db.Model(&models.Group{}).Where(
 		"policies like ?",
@@ -3,6 +3,7 @@ package admin
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/search"
 	"github.com/jylc/cloudserver/pkg/serializer"
 	"github.com/jylc/cloudserver/pkg/task"
 	"strings"
@@ -20,6 +21,25 @@ type ImportTaskService struct {
 	Recursive bool   `json:"recursive"`
 }
 
+// ScrubTaskService 创建存储巡检任务服务，PolicyIDs 为空时巡检所有存储策略
+type ScrubTaskService struct {
+	PolicyIDs []uint `json:"policy_ids"`
+	Rehash    bool   `json:"rehash"`
+	Repair    bool   `json:"repair"`
+}
+
+// StorageRecalcService 创建容量重新计算任务服务，UserIDs 为空时处理所有用户
+type StorageRecalcService struct {
+	UserIDs []uint `json:"user_ids"`
+}
+
+// ReconcileTaskService 创建存储对账任务服务，PolicyIDs 为空时对账所有存储策略
+type ReconcileTaskService struct {
+	PolicyIDs      []uint `json:"policy_ids"`
+	OrphanAction   string `json:"orphan_action" binding:"omitempty,eq=quarantine|eq=delete"`
+	DanglingAction string `json:"dangling_action" binding:"omitempty,eq=mark|eq=remove"`
+}
+
 func (service *ListService) Downloads() serializer.Response {
 	var res []models.Download
 	total := int64(0)
@@ -128,6 +148,10 @@ func (service *TaskBatchService) DeleteGeneral(c *gin.Context) serializer.Respon
 	if err := models.Db.Where("id in (?)", service.ID).Delete(&models.Task{}).Error; err != nil {
 		return serializer.DBErr("Cannot delete task", err)
 	}
+
+	if err := models.DeleteScrubIssuesByTasks(service.ID); err != nil {
+		return serializer.DBErr("Cannot delete scrub report", err)
+	}
 	return serializer.Response{}
 }
 
@@ -139,3 +163,82 @@ func (service *ImportTaskService) Create(c *gin.Context, user *models.User) seri
 	task.TaskPool.Submit(job)
 	return serializer.Response{}
 }
+
+// CreateIndexRebuildTask 创建全文索引重建任务
+func CreateIndexRebuildTask(c *gin.Context, user *models.User) serializer.Response {
+	if !search.Enabled() {
+		return serializer.ParamErr("Full-text search is not enabled", nil)
+	}
+
+	job, err := task.NewIndexRebuildTask(user.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Task creation failed", err)
+	}
+	task.TaskPool.Submit(job)
+	return serializer.Response{}
+}
+
+// Create 创建存储巡检任务
+func (service *ScrubTaskService) Create(c *gin.Context, user *models.User) serializer.Response {
+	for _, id := range service.PolicyIDs {
+		if _, err := models.GetPolicyByID(id); err != nil {
+			return serializer.Err(serializer.CodeNotFound, "Storage policy does not exist", nil)
+		}
+	}
+
+	job, err := task.NewScrubTask(user.ID, service.PolicyIDs, service.Rehash, service.Repair)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Task creation failed", err)
+	}
+	task.TaskPool.Submit(job)
+	return serializer.Response{}
+}
+
+// Create 创建存储对账任务
+func (service *ReconcileTaskService) Create(c *gin.Context, user *models.User) serializer.Response {
+	for _, id := range service.PolicyIDs {
+		if _, err := models.GetPolicyByID(id); err != nil {
+			return serializer.Err(serializer.CodeNotFound, "Storage policy does not exist", nil)
+		}
+	}
+
+	job, err := task.NewReconcileTask(user.ID, service.PolicyIDs, service.OrphanAction, service.DanglingAction)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Task creation failed", err)
+	}
+	task.TaskPool.Submit(job)
+	return serializer.Response{}
+}
+
+// Create 创建容量重新计算任务
+func (service *StorageRecalcService) Create(c *gin.Context, user *models.User) serializer.Response {
+	job, err := task.NewStorageRecalcTask(user.ID, service.UserIDs)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Task creation failed", err)
+	}
+	task.TaskPool.Submit(job)
+	return serializer.Response{}
+}
+
+// ScrubIssues 列出存储巡检及对账报告
+func (service *ListService) ScrubIssues() serializer.Response {
+	var res []models.ScrubIssue
+	total := int64(0)
+
+	tx := models.Db.Model(&models.ScrubIssue{})
+	if service.OrderBy != "" {
+		tx = tx.Order(service.OrderBy)
+	}
+
+	for k, v := range service.Conditions {
+		tx = tx.Where(k+" = ?", v)
+	}
+
+	tx.Count(&total)
+	tx.Limit(service.PageSize).Offset((service.Page - 1) * service.PageSize).Find(&res)
+
+	return serializer.Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
@@ -104,6 +104,9 @@ func (service *UserBatchService) Delete() serializer.Response {
 		if err != nil {
 			return serializer.Err(serializer.CodeNotFound, "Unable to find user root directory", err)
 		}
+		if trashes, err := models.GetTrashByUser(uid); err == nil {
+			fs.Purge(context.Background(), trashes)
+		}
 		fs.Delete(context.Background(), []uint{root.ID}, []uint{}, false)
 
 		models.Db.Where("user_id = ?", uid).Delete(&models.Download{})
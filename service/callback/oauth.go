@@ -0,0 +1,53 @@
+package callback
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/cache"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/onedrive"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/jylc/cloudserver/pkg/utils"
+)
+
+// OneDriveOauthService OneDrive 授权回调服务
+type OneDriveOauthService struct {
+	Code     string `form:"code"`
+	Error    string `form:"error"`
+	ErrorMsg string `form:"error_description"`
+}
+
+// Auth 使用授权码换取并保存 RefreshToken
+func (service *OneDriveOauthService) Auth(c *gin.Context) serializer.Response {
+	if service.Error != "" {
+		return serializer.ParamErr(service.ErrorMsg, nil)
+	}
+
+	policyID, ok := utils.GetSession(c, "onedrive_oauth_policy").(uint)
+	if !ok {
+		return serializer.Err(serializer.CodeNotFound, "Storage policy not found in session", nil)
+	}
+	utils.DeleteSession(c, "onedrive_oauth_policy")
+
+	policy, err := models.GetPolicyByID(policyID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Storage policy does not exist", nil)
+	}
+
+	client, err := onedrive.NewClient(&policy)
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "Unable to initialize onedrive client", err)
+	}
+
+	credential, err := client.ObtainToken(context.Background(), onedrive.WithCode(service.Code))
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "AccessToken exchange failed", err)
+	}
+
+	if err := policy.UpdateAccessKeyAndClearCache(credential.RefreshToken); err != nil {
+		return serializer.DBErr("Unable to update RefreshToken", err)
+	}
+
+	_ = cache.Deletes([]string{policy.BucketName}, "onedrive_")
+	return serializer.Response{}
+}
@@ -5,6 +5,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/cos"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/onedrive"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/s3"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/serializer"
 )
@@ -60,3 +63,109 @@ func ProcessCallback(service CallbackProcessService, c *gin.Context) serializer.
 	}
 	return serializer.Response{}
 }
+
+// S3Callback S3 存储策略上传完成后的回调
+type S3Callback struct{}
+
+func (service *S3Callback) GetBody() serializer.UploadCallback {
+	return serializer.UploadCallback{}
+}
+
+// PreProcess 确认对象已在存储端完成合并后再处理回调
+func (service *S3Callback) PreProcess(c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromCallback(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
+	handler, ok := fs.Handler.(*s3.Driver)
+	if !ok {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "Policy not supported", nil)
+	}
+
+	info, err := handler.Meta(context.Background(), uploadSession.SavePath)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, "Failed to get object metadata", err)
+	}
+
+	if info.Size != uploadSession.Size {
+		_, _ = handler.Delete(context.Background(), []string{uploadSession.SavePath})
+		return serializer.Err(serializer.CodeUploadFailed, "File size not match", nil)
+	}
+
+	return ProcessCallback(service, c)
+}
+
+// OneDriveCallback OneDrive 客户端上传完成后的回调
+type OneDriveCallback struct{}
+
+func (service *OneDriveCallback) GetBody() serializer.UploadCallback {
+	return serializer.UploadCallback{}
+}
+
+// PreProcess 确认文件已在 OneDrive 上传完成后再处理回调
+func (service *OneDriveCallback) PreProcess(c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromCallback(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
+	handler, ok := fs.Handler.(*onedrive.Driver)
+	if !ok {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "Policy not supported", nil)
+	}
+
+	info, err := handler.Client.Meta(context.Background(), uploadSession.SavePath)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, "Failed to get file metadata from OneDrive", err)
+	}
+
+	if info.Size != uploadSession.Size {
+		_, _ = handler.Delete(context.Background(), []string{uploadSession.SavePath})
+		return serializer.Err(serializer.CodeUploadFailed, "File size not match", nil)
+	}
+
+	return ProcessCallback(service, c)
+}
+
+// COSCallback COS 云函数触发的上传回调
+type COSCallback struct{}
+
+func (service *COSCallback) GetBody() serializer.UploadCallback {
+	return serializer.UploadCallback{}
+}
+
+// PreProcess 校验对象元信息中携带的会话 Key 和文件大小后再处理回调
+func (service *COSCallback) PreProcess(c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromCallback(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
+	handler, ok := fs.Handler.(*cos.Driver)
+	if !ok {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "Policy not supported", nil)
+	}
+
+	info, err := handler.Meta(context.Background(), uploadSession.SavePath)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, "Failed to get object metadata", err)
+	}
+
+	if info.CallbackKey != uploadSession.Key {
+		return serializer.Err(serializer.CodeUploadFailed, "Callback key not match", nil)
+	}
+
+	if info.Size != uploadSession.Size {
+		_, _ = handler.Delete(context.Background(), []string{uploadSession.SavePath})
+		return serializer.Err(serializer.CodeUploadFailed, "File size not match", nil)
+	}
+
+	return ProcessCallback(service, c)
+}
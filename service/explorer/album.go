@@ -0,0 +1,165 @@
+package explorer
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"github.com/jylc/cloudserver/pkg/serializer"
+)
+
+// TimelineService 照片时间线服务
+type TimelineService struct {
+	Page     uint `form:"page" binding:"required,min=1"`
+	PageSize uint `form:"page_size" binding:"omitempty,min=1,max=500"`
+}
+
+// AlbumCreateService 创建及重命名相册服务
+type AlbumCreateService struct {
+	Name string `json:"name" binding:"required,min=1,max=255"`
+}
+
+// AlbumListService 列出相册文件服务
+type AlbumListService struct {
+	Page     uint `form:"page" binding:"required,min=1"`
+	PageSize uint `form:"page_size" binding:"omitempty,min=1,max=500"`
+}
+
+// AlbumFilesService 相册文件管理服务
+type AlbumFilesService struct {
+	Items []string `json:"items" binding:"required,min=1"`
+}
+
+// Timeline 按天分组列出用户的照片
+func (service *TimelineService) Timeline(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	pageSize := service.PageSize
+	if pageSize == 0 {
+		pageSize = 100
+	}
+
+	buckets, total, err := fs.Timeline(ctx, int(service.Page), int(pageSize))
+	if err != nil {
+		return serializer.Err(serializer.CodeDBError, err.Error(), err)
+	}
+	return serializer.BuildTimeline(buckets, total)
+}
+
+// ListAlbums 列出用户的相册
+func ListAlbums(c *gin.Context, user *models.User) serializer.Response {
+	albums, err := models.GetAlbumsByUser(user.ID)
+	if err != nil {
+		return serializer.DBErr("Unable to list albums", err)
+	}
+	return serializer.BuildAlbumList(albums)
+}
+
+// DeleteAlbum 删除相册，相册中的文件不受影响
+func DeleteAlbum(c *gin.Context, user *models.User) serializer.Response {
+	album, err := currentAlbum(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Album not exist", err)
+	}
+
+	if err := album.Delete(); err != nil {
+		return serializer.DBErr("Unable to delete album", err)
+	}
+	return serializer.Response{}
+}
+
+// Create 创建相册
+func (service *AlbumCreateService) Create(c *gin.Context, user *models.User) serializer.Response {
+	album := &models.Album{
+		Name:   service.Name,
+		UserID: user.ID,
+	}
+	if _, err := album.Create(); err != nil {
+		return serializer.DBErr("Unable to create album", err)
+	}
+	return serializer.Response{Data: serializer.BuildAlbum(album)}
+}
+
+// Rename 重命名相册
+func (service *AlbumCreateService) Rename(c *gin.Context, user *models.User) serializer.Response {
+	album, err := currentAlbum(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Album not exist", err)
+	}
+
+	if err := album.Rename(service.Name); err != nil {
+		return serializer.DBErr("Unable to rename album", err)
+	}
+	return serializer.Response{Data: serializer.BuildAlbum(album)}
+}
+
+// List 分页列出相册中的文件
+func (service *AlbumListService) List(ctx context.Context, c *gin.Context, user *models.User) serializer.Response {
+	album, err := currentAlbum(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Album not exist", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	pageSize := service.PageSize
+	if pageSize == 0 {
+		pageSize = 100
+	}
+
+	objects, total := fs.ListAlbum(ctx, album, int(service.Page), int(pageSize))
+	return serializer.BuildAlbumObjects(album, objects, total)
+}
+
+// Add 将文件加入相册，文件仍保留在原有位置
+func (service *AlbumFilesService) Add(c *gin.Context, user *models.User) serializer.Response {
+	album, err := currentAlbum(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Album not exist", err)
+	}
+
+	added, err := album.AddFiles(service.fileIDs())
+	if err != nil {
+		return serializer.DBErr("Unable to add files to album", err)
+	}
+	return serializer.Response{Data: added}
+}
+
+// Remove 将文件移出相册
+func (service *AlbumFilesService) Remove(c *gin.Context, user *models.User) serializer.Response {
+	album, err := currentAlbum(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Album not exist", err)
+	}
+
+	if err := album.RemoveFiles(service.fileIDs()); err != nil {
+		return serializer.DBErr("Unable to remove files from album", err)
+	}
+	return serializer.Response{}
+}
+
+func (service *AlbumFilesService) fileIDs() []uint {
+	ids := make([]uint, 0, len(service.Items))
+	for _, raw := range service.Items {
+		if id, err := hashid.DecodeHashID(raw, hashid.FileID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// currentAlbum 获取路由参数指定的相册
+func currentAlbum(c *gin.Context, user *models.User) (*models.Album, error) {
+	albumID, _ := c.Get("object_id")
+	id, _ := albumID.(uint)
+	return models.GetAlbumByID(id, user.ID)
+}
@@ -0,0 +1,74 @@
+package explorer
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/serializer"
+)
+
+// FavoriteService 收藏、取消收藏文件及目录的服务
+type FavoriteService struct {
+	Src ItemIDService `json:"src"`
+}
+
+// Add 收藏文件及目录
+func (service *FavoriteService) Add(c *gin.Context, user *models.User) serializer.Response {
+	items, err := service.Src.Owned(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), nil)
+	}
+
+	if err := models.AddFavorites(user.ID, items.Dirs, items.Items); err != nil {
+		return serializer.DBErr("Unable to add favorites", err)
+	}
+	return serializer.Response{}
+}
+
+// Remove 取消收藏文件及目录，已删除的对象无需校验
+func (service *FavoriteService) Remove(c *gin.Context, user *models.User) serializer.Response {
+	items := service.Src.Raw()
+	if err := models.RemoveFavorites(user.ID, items.Dirs, items.Items); err != nil {
+		return serializer.DBErr("Unable to remove favorites", err)
+	}
+	return serializer.Response{}
+}
+
+// ListFavorites 列出用户收藏的目录和文件
+func ListFavorites(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	objects, err := fs.ListFavorites(ctx)
+	if err != nil {
+		return serializer.Err(serializer.CodeDBError, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: serializer.BuildObjectList(0, objects, nil),
+	}
+}
+
+// ListRecent 列出用户最近上传、下载、预览或编辑的文件
+func ListRecent(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	objects, err := fs.ListRecent(ctx)
+	if err != nil {
+		return serializer.Err(serializer.CodeDBError, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: serializer.BuildObjectList(0, objects, nil),
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/cache"
 	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/checksum"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/serializer"
 	"github.com/jylc/cloudserver/pkg/utils"
@@ -82,6 +83,7 @@ func (service *FileAnonymousGetService) Download(ctx context.Context, c *gin.Con
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
 
+	setDigestHeaders(c, fs.FileTarget[0].SHA256)
 	http.ServeContent(c.Writer, c.Request, service.Name, fs.FileTarget[0].UpdatedAt, rs)
 
 	return serializer.Response{
@@ -124,6 +126,11 @@ func (service *DownloadService) Download(ctx context.Context, c *gin.Context) se
 	}
 	fs.FileTarget = []models.File{file.(models.File)}
 
+	// 会话创建后文件可能已被迁移至其他存储策略，使用最新的文件记录
+	if current, err := models.GetFilesByIDs([]uint{fs.FileTarget[0].ID}, fs.FileTarget[0].UserID); err == nil && len(current) > 0 {
+		fs.FileTarget[0] = current[0]
+	}
+
 	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
 	rs, err := fs.GetDownloadContent(ctx, 0)
 	if err != nil {
@@ -137,6 +144,7 @@ func (service *DownloadService) Download(ctx context.Context, c *gin.Context) se
 		_ = cache.Deletes([]string{service.ID}, "download_")
 	}
 
+	setDigestHeaders(c, fs.FileTarget[0].SHA256)
 	http.ServeContent(c.Writer, c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, rs)
 	return serializer.Response{
 		Code: 0,
@@ -203,6 +211,7 @@ func (service *FileIDService) PreviewContent(ctx context.Context, c *gin.Context
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
+	fs.RecordRecent(&fs.FileTarget[0], models.RecentActionPreview)
 
 	if resp.Redirect {
 		c.Header("Cache-Control", fmt.Sprintf("max-age=%d", resp.MaxAge))
@@ -217,12 +226,24 @@ func (service *FileIDService) PreviewContent(ctx context.Context, c *gin.Context
 		c.Header("Cache-Control", "no-cache")
 	}
 
+	setDigestHeaders(c, fs.FileTarget[0].SHA256)
 	http.ServeContent(c.Writer, c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, resp.Content)
 	return serializer.Response{
 		Code: 0,
 	}
 }
 
+// setDigestHeaders 输出文件内容的 SHA-256 校验和，供客户端校验下载的完整性
+func setDigestHeaders(c *gin.Context, sha256 string) {
+	digest, err := checksum.FromSHA256(sha256)
+	if err != nil {
+		return
+	}
+
+	c.Header("Digest", digest.String())
+	c.Header("ETag", `"`+sha256+`"`)
+}
+
 func (service *FileIDService) CreateDownloadSession(ctx context.Context, c *gin.Context) serializer.Response {
 	fs, err := filesystem.NewFileSystemFromContext(c)
 	if err != nil {
@@ -236,6 +257,7 @@ func (service *FileIDService) CreateDownloadSession(ctx context.Context, c *gin.
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
+	fs.RecordRecent(&fs.FileTarget[0], models.RecentActionDownload)
 	return serializer.Response{
 		Code: 0,
 		Data: downloadURL,
@@ -270,23 +292,43 @@ func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) se
 	}
 	fileData.Name = originFile[0].Name
 
-	fileList, err := models.RemoveFilesWithSoftLinks([]models.File{originFile[0]})
-	if err == nil && len(fileList) == 0 {
+	if fs.User.Group.OptionsSerialized.MaxVersions > 0 {
+		// 保留历史版本时新内容写入新的物理文件，原有内容转为历史版本
+		fs.Use("AfterUpload", filesystem.HookKeepVersion(originFile[0]))
 		originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, &fileData)
 		fileData.Mode &= ^fsctx.Overwrite
+		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+		fs.Use("BeforeUpload", filesystem.HookValidateFile)
+		fs.Use("BeforeUpload", filesystem.HookValidateVersionCapacity)
+		fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
 		fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
-		fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
-		fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
-	}
+		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+		fs.Use("AfterUpload", filesystem.HookExtractMetadata)
+		fs.Use("AfterUpload", filesystem.HookIndexContent)
+		fs.Use("AfterUpload", filesystem.HookRecordRecent(models.RecentActionEdit))
+		fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+	} else {
+		fileList, err := models.RemoveFilesWithSoftLinks([]models.File{originFile[0]})
+		if err == nil && len(fileList) == 0 {
+			originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, &fileData)
+			fileData.Mode &= ^fsctx.Overwrite
+			fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
+			fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
+			fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+		}
 
-	fs.Use("BeforeUpload", filesystem.HookResetPolicy)
-	fs.Use("BeforeUpload", filesystem.HookValidateFile)
-	fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
-	fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
-	fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
-	fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
-	fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
-	fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+		fs.Use("BeforeUpload", filesystem.HookValidateFile)
+		fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
+		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
+		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+		fs.Use("AfterUpload", filesystem.HookExtractMetadata)
+		fs.Use("AfterUpload", filesystem.HookIndexContent)
+		fs.Use("AfterUpload", filesystem.HookRecordRecent(models.RecentActionEdit))
+		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
+		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+	}
 
 	uploadCtx = context.WithValue(uploadCtx, fsctx.FileModelCtx, originFile[0])
 	err = fs.Upload(uploadCtx, &fileData)
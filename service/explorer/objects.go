@@ -3,6 +3,7 @@ package explorer
 import (
 	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/jylc/cloudserver/models"
@@ -89,6 +90,31 @@ func (service *ItemIDService) Raw() *ItemService {
 	return service.Source
 }
 
+// Owned 解码对象 ID，并校验所有对象均存在且属于指定用户
+func (service *ItemIDService) Owned(user *models.User) (*ItemService, error) {
+	items := service.Raw()
+	if len(items.Items) != len(service.Items) || len(items.Dirs) != len(service.Dirs) {
+		return nil, errors.New("object not exist")
+	}
+	if len(items.Items) == 0 && len(items.Dirs) == 0 {
+		return nil, errors.New("no object selected")
+	}
+
+	if len(items.Dirs) > 0 {
+		if folders, err := models.GetFoldersByIDs(items.Dirs, user.ID); err != nil || len(folders) != len(items.Dirs) {
+			return nil, errors.New("object not exist")
+		}
+	}
+
+	if len(items.Items) > 0 {
+		if files, err := models.GetFilesByIDs(items.Items, user.ID); err != nil || len(files) != len(items.Items) {
+			return nil, errors.New("object not exist")
+		}
+	}
+
+	return items, nil
+}
+
 func (service *ItemIDService) Archive(ctx context.Context, c *gin.Context) serializer.Response {
 	fs, err := filesystem.NewFileSystemFromContext(c)
 	if err != nil {
@@ -237,7 +263,7 @@ func (service *ItemIDService) Delete(ctx context.Context, c *gin.Context) serial
 	defer fs.Recycle()
 
 	items := service.Raw()
-	err = fs.Delete(ctx, items.Dirs, items.Items, false)
+	err = fs.Trash(ctx, items.Dirs, items.Items)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -324,6 +350,7 @@ func (service *ItemPropertyService) GetProperty(ctx context.Context, c *gin.Cont
 		props.UpdateAt = file[0].UpdatedAt
 		props.Policy = file[0].GetPolicy().Name
 		props.Size = file[0].Size
+		props.Metadata = file[0].MetadataSerialized
 
 		if service.TraceRoot {
 			parent, err := models.GetFolderByIDs([]uint{file[0].FolderID}, user.ID)
@@ -6,6 +6,7 @@ import (
 	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/filesystem"
 	"github.com/jylc/cloudserver/pkg/hashid"
+	"github.com/jylc/cloudserver/pkg/search"
 	"github.com/jylc/cloudserver/pkg/serializer"
 	"strings"
 )
@@ -14,6 +15,19 @@ type ItemSearchService struct {
 	Type     string `uri:"type" binding:"required"`
 	Keywords string `uri:"keywords" binding:"required"`
 	Path     string `form:"path"`
+	// 分页参数，仅用于保存的结构化搜索
+	Page     uint `form:"page" binding:"omitempty,min=1"`
+	PageSize uint `form:"page_size" binding:"omitempty,min=1,max=500"`
+}
+
+// ItemQueryService 结构化搜索服务，语句格式见 search.ParseQuery
+type ItemQueryService struct {
+	Query    string `form:"q" binding:"required,max=65535"`
+	Path     string `form:"path"`
+	Page     uint   `form:"page" binding:"omitempty,min=1"`
+	PageSize uint   `form:"page_size" binding:"omitempty,min=1,max=500"`
+	OrderBy  string `form:"order_by" binding:"omitempty,eq=name|eq=size|eq=updated_at|eq=created_at"`
+	Order    string `form:"order" binding:"omitempty,eq=DESC|eq=ASC"`
 }
 
 func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
@@ -42,11 +56,32 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 		return service.SearchKeywords(c, fs, "%.mp3", "%.flac", "%.ape", "%.wav", "%.acc", "%.ogg", "%.midi", "%.mid")
 	case "doc":
 		return service.SearchKeywords(c, fs, "%.txt", "%.md", "%.pdf", "%.doc", "%.docx", "%.ppt", "%.pptx", "%.xls", "%.xlsx", "%.pub")
+	case "content":
+		if !search.Enabled() {
+			return serializer.Err(serializer.CodeNoPermissionErr, "Full-text search is not enabled", nil)
+		}
+		return service.SearchContent(c, fs, service.Keywords)
+	case "metadata":
+		// 关键字格式为 key=value，省略 value 时匹配包含该字段的文件
+		key, value := service.Keywords, ""
+		if i := strings.Index(service.Keywords, "="); i >= 0 {
+			key, value = service.Keywords[:i], service.Keywords[i+1:]
+		}
+		if key == "" {
+			return serializer.ParamErr("Metadata field cannot be empty", nil)
+		}
+		return service.SearchMetadata(c, fs, key, value)
 	case "tag":
 		if tid, err := hashid.DecodeHashID(service.Keywords, hashid.TagID); err == nil {
 			if tag, err := models.GetTagsByID(tid, fs.User.ID); err == nil {
+				if tag.Type == models.ManualTagType {
+					return service.SearchTagged(c, fs, tag.ID)
+				}
+				if tag.Type == models.FileTagType && tag.Query {
+					return service.SearchQuery(c, fs, tag.Expression)
+				}
 				if tag.Type == models.FileTagType {
-					exp := strings.Split(tag.Expression, "\n")
+					exp := filesystem.TagPatterns(tag)
 					expInput := make([]interface{}, len(exp))
 					for i := 0; i < len(exp); i++ {
 						expInput[i] = exp[i]
@@ -65,7 +100,147 @@ func (service *ItemSearchService) SearchKeywords(c *gin.Context, fs *filesystem.
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	objects, err := fs.Search(ctx, keywords)
+	objects, err := fs.Search(ctx, keywords...)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+		},
+	}
+}
+
+func (service *ItemSearchService) SearchMetadata(c *gin.Context, fs *filesystem.FileSystem, key, value string) serializer.Response {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects, err := fs.SearchMetadata(ctx, key, value)
+	if err != nil {
+		return serializer.Err(serializer.CodeDBError, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+		},
+	}
+}
+
+func (service *ItemSearchService) SearchContent(c *gin.Context, fs *filesystem.FileSystem, keywords string) serializer.Response {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects, err := fs.SearchContent(ctx, keywords)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+		},
+	}
+}
+
+// SearchTagged 搜索带有手动标签的目录和文件
+func (service *ItemSearchService) SearchTagged(c *gin.Context, fs *filesystem.FileSystem, tagID uint) serializer.Response {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects, err := fs.SearchTagged(ctx, tagID)
+	if err != nil {
+		return serializer.Err(serializer.CodeDBError, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+		},
+	}
+}
+
+// SearchQuery 分页执行保存的结构化搜索
+func (service *ItemSearchService) SearchQuery(c *gin.Context, fs *filesystem.FileSystem, raw string) serializer.Response {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	query, err := search.ParseQuery(raw)
+	if err != nil {
+		return serializer.ParamErr("Invalid search query: "+err.Error(), err)
+	}
+
+	page, pageSize := queryPage(service.Page, service.PageSize)
+	objects, total, err := fs.QueryFiles(ctx, query, int(page), int(pageSize), "updated_at DESC")
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+			"total":   total,
+			"page":    page,
+		},
+	}
+}
+
+// queryPage 补全结构化搜索的分页参数
+func queryPage(page, pageSize uint) (uint, uint) {
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = 50
+	}
+	return page, pageSize
+}
+
+// Search 按结构化搜索语句分页搜索文件
+func (service *ItemQueryService) Search(c *gin.Context) serializer.Response {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	query, err := search.ParseQuery(service.Query)
+	if err != nil {
+		return serializer.ParamErr("Invalid search query: "+err.Error(), err)
+	}
+
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	if service.Path != "" {
+		ok, parent := fs.IsPathExist(service.Path)
+		if !ok {
+			return serializer.Err(serializer.CodeParentNotExist, "Cannot find parent folder", nil)
+		}
+		fs.Root = parent
+	}
+
+	page, pageSize := queryPage(service.Page, service.PageSize)
+	orderBy, order := service.OrderBy, service.Order
+	if orderBy == "" {
+		orderBy = "updated_at"
+	}
+	if order == "" {
+		order = "DESC"
+	}
+
+	objects, total, err := fs.QueryFiles(ctx, query, int(page), int(pageSize), orderBy+" "+order)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -75,6 +250,8 @@ func (service *ItemSearchService) SearchKeywords(c *gin.Context, fs *filesystem.
 		Data: map[string]interface{}{
 			"parent":  0,
 			"objects": objects,
+			"total":   total,
+			"page":    page,
 		},
 	}
 }
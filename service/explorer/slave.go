@@ -2,11 +2,19 @@ package explorer
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
 	"github.com/jylc/cloudserver/pkg/cache"
 	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/driver/local"
 	"github.com/jylc/cloudserver/pkg/serializer"
 	"github.com/jylc/cloudserver/pkg/utils"
+	"net/http"
+	"net/url"
+	"time"
 )
 
 type SlaveCreateUploadSessionService struct {
@@ -31,3 +39,107 @@ func (service *SlaveCreateUploadSessionService) Create(ctx context.Context, c *g
 	}
 	return serializer.Response{}
 }
+
+// SlaveDownloadService 从机文件下载服务
+type SlaveDownloadService struct {
+	PathEncoded string `uri:"path" binding:"required"`
+	Name        string `uri:"name" binding:"required"`
+	Speed       int    `uri:"speed" binding:"min=0"`
+}
+
+// SlaveFileService 从机单文件服务
+type SlaveFileService struct {
+	PathEncoded string `uri:"path" binding:"required"`
+}
+
+// SlaveFilesService 从机多文件服务
+type SlaveFilesService struct {
+	Files []string `json:"files" binding:"required,gt=0"`
+}
+
+// SlaveListService 从机列取文件服务
+type SlaveListService struct {
+	Path      string `json:"path" binding:"required,min=1,max=65535"`
+	Recursive bool   `json:"recursive"`
+}
+
+// ServeFile 通过签名 URL 下载或预览从机文件
+func (service *SlaveDownloadService) ServeFile(ctx context.Context, c *gin.Context, isDownload bool) serializer.Response {
+	fs, err := filesystem.NewAnonymousFileSystem()
+	if err != nil {
+		return serializer.Err(serializer.CodeGroupNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+	fs.Handler = local.Driver{}
+
+	sourcePath, err := base64.RawURLEncoding.DecodeString(service.PathEncoded)
+	if err != nil {
+		return serializer.ParamErr("Unable to decode file path", err)
+	}
+
+	file := models.File{
+		Name:       service.Name,
+		SourceName: string(sourcePath),
+	}
+	fs.FileTarget = []models.File{file}
+	fs.User.Group.SpeedLimit = service.Speed
+
+	rs, err := fs.GetDownloadContent(ctx, 0)
+	if err != nil || rs == nil {
+		return serializer.Err(serializer.CodeNotFound, "File not exist", err)
+	}
+	defer rs.Close()
+
+	if isDownload {
+		c.Header("Content-Disposition", "attachment; filename=\""+url.PathEscape(service.Name)+"\"")
+	}
+
+	http.ServeContent(c.Writer, c.Request, service.Name, time.Now(), rs)
+	return serializer.Response{}
+}
+
+// Thumb 获取从机文件的缩略图
+func (service *SlaveFileService) Thumb(ctx context.Context, c *gin.Context) serializer.Response {
+	sourcePath, err := base64.RawURLEncoding.DecodeString(service.PathEncoded)
+	if err != nil {
+		return serializer.ParamErr("Unable to decode file path", err)
+	}
+
+	handler := local.Driver{}
+	res, err := handler.Thumb(ctx, string(sourcePath))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Unable to get thumbnail", err)
+	}
+	defer res.Content.Close()
+
+	http.ServeContent(c.Writer, c.Request, "thumb.jpg", time.Now(), res.Content)
+	return serializer.Response{}
+}
+
+// Delete 删除从机上的文件，失败的文件列表以 JSON 字符串返回
+func (service *SlaveFilesService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	handler := local.Driver{}
+	failed, err := handler.Delete(ctx, service.Files)
+	if err != nil {
+		data, _ := json.Marshal(serializer.RemoteDeleteRequest{Files: failed})
+		return serializer.Response{
+			Code:  serializer.CodeNotFullySuccess,
+			Data:  string(data),
+			Msg:   fmt.Sprintf("Failed to delete %d files", len(failed)),
+			Error: err.Error(),
+		}
+	}
+	return serializer.Response{}
+}
+
+// List 列取从机上的文件
+func (service *SlaveListService) List(ctx context.Context, c *gin.Context) serializer.Response {
+	handler := local.Driver{}
+	objects, err := handler.List(ctx, service.Path, service.Recursive)
+	if err != nil {
+		return serializer.Err(serializer.CodeIOFailed, "Cannot list files", err)
+	}
+
+	res, _ := json.Marshal(objects)
+	return serializer.Response{Data: string(res)}
+}
@@ -0,0 +1,214 @@
+package explorer
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"github.com/jylc/cloudserver/pkg/search"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"strings"
+)
+
+// FilterTagCreateService 文件分类标签创建服务
+type FilterTagCreateService struct {
+	Expression string `json:"expression" binding:"required,min=1,max=65535"`
+	Icon       string `json:"icon" binding:"required,min=1,max=255"`
+	Name       string `json:"name" binding:"required,min=1,max=255"`
+	Color      string `json:"color" binding:"omitempty,hexcolor|rgb|rgba|hsl"`
+}
+
+// SavedSearchCreateService 保存结构化搜索为文件分类标签的服务
+type SavedSearchCreateService struct {
+	Query string `json:"query" binding:"required,min=1,max=65535"`
+	Icon  string `json:"icon" binding:"required,min=1,max=255"`
+	Name  string `json:"name" binding:"required,min=1,max=255"`
+	Color string `json:"color" binding:"omitempty,hexcolor|rgb|rgba|hsl"`
+}
+
+// LinkTagCreateService 目录快捷方式标签创建服务
+type LinkTagCreateService struct {
+	Path string `json:"path" binding:"required,min=1,max=65535"`
+	Name string `json:"name" binding:"required,min=1,max=255"`
+}
+
+// LabelCreateService 手动标签创建服务
+type LabelCreateService struct {
+	Name  string `json:"name" binding:"required,min=1,max=255"`
+	Icon  string `json:"icon" binding:"omitempty,max=255"`
+	Color string `json:"color" binding:"omitempty,hexcolor|rgb|rgba|hsl"`
+}
+
+// ObjectTagService 批量为目录和文件添加、移除手动标签的服务
+type ObjectTagService struct {
+	Src  ItemIDService `json:"src"`
+	Tags []string      `json:"tags" binding:"required,min=1"`
+}
+
+// TagService 标签服务
+type TagService struct {
+}
+
+// Delete 删除标签
+func (service *TagService) Delete(c *gin.Context, user *models.User) serializer.Response {
+	id, _ := c.Get("object_id")
+	if err := models.DeleteTagByID(id.(uint), user.ID); err != nil {
+		return serializer.DBErr("Unable to delete tag", err)
+	}
+	return serializer.Response{}
+}
+
+// Create 创建目录快捷方式标签
+func (service *LinkTagCreateService) Create(c *gin.Context, user *models.User) serializer.Response {
+	tag := models.Tag{
+		Name:       service.Name,
+		Icon:       "FolderHeartOutline",
+		Type:       models.DirectoryLinkType,
+		Expression: service.Path,
+		UserID:     user.ID,
+	}
+	id, err := tag.Create()
+	if err != nil {
+		return serializer.DBErr("Unable to create tag", err)
+	}
+
+	return serializer.Response{
+		Data: hashid.HashID(id, hashid.TagID),
+	}
+}
+
+// Create 创建文件分类标签，表达式每行一个文件名通配符
+func (service *FilterTagCreateService) Create(c *gin.Context, user *models.User) serializer.Response {
+	expressions := strings.Split(service.Expression, "\n")
+	for i := 0; i < len(expressions); i++ {
+		if expressions[i] == "" {
+			return serializer.ParamErr("Expression cannot contain empty lines", nil)
+		}
+		expressions[i] = search.GlobToLike(expressions[i])
+	}
+
+	tag := models.Tag{
+		Name:       service.Name,
+		Icon:       service.Icon,
+		Color:      service.Color,
+		Type:       models.FileTagType,
+		Expression: strings.Join(expressions, "\n"),
+		UserID:     user.ID,
+	}
+	id, err := tag.Create()
+	if err != nil {
+		return serializer.DBErr("Unable to create tag", err)
+	}
+
+	return serializer.Response{
+		Data: hashid.HashID(id, hashid.TagID),
+	}
+}
+
+// Create 将结构化搜索语句保存为文件分类标签，在侧边栏中显示
+func (service *SavedSearchCreateService) Create(c *gin.Context, user *models.User) serializer.Response {
+	query, err := search.ParseQuery(service.Query)
+	if err != nil {
+		return serializer.ParamErr("Invalid search query: "+err.Error(), err)
+	}
+
+	for _, name := range query.Tags {
+		if tag, err := models.GetTagByName(name, models.FileTagType, user.ID); err == nil && tag.Query {
+			return serializer.ParamErr("Saved search cannot reference another saved search", nil)
+		}
+	}
+
+	tag := models.Tag{
+		Name:       service.Name,
+		Icon:       service.Icon,
+		Color:      service.Color,
+		Type:       models.FileTagType,
+		Expression: service.Query,
+		Query:      true,
+		UserID:     user.ID,
+	}
+	id, err := tag.Create()
+	if err != nil {
+		return serializer.DBErr("Unable to create tag", err)
+	}
+
+	return serializer.Response{
+		Data: hashid.HashID(id, hashid.TagID),
+	}
+}
+
+// Create 创建手动标签
+func (service *LabelCreateService) Create(c *gin.Context, user *models.User) serializer.Response {
+	if _, err := models.GetTagByName(service.Name, models.ManualTagType, user.ID); err == nil {
+		return serializer.Err(serializer.CodeObjectExist, "Tag with the same name already exists", nil)
+	}
+
+	icon := service.Icon
+	if icon == "" {
+		icon = "TagOutline"
+	}
+
+	tag := models.Tag{
+		Name:   service.Name,
+		Icon:   icon,
+		Color:  service.Color,
+		Type:   models.ManualTagType,
+		UserID: user.ID,
+	}
+	id, err := tag.Create()
+	if err != nil {
+		return serializer.DBErr("Unable to create tag", err)
+	}
+
+	return serializer.Response{
+		Data: hashid.HashID(id, hashid.TagID),
+	}
+}
+
+// Add 为对象添加标签
+func (service *ObjectTagService) Add(c *gin.Context, user *models.User) serializer.Response {
+	tagIDs, folderIDs, fileIDs, err := service.resolve(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), nil)
+	}
+
+	if err := models.AddObjectTags(tagIDs, folderIDs, fileIDs); err != nil {
+		return serializer.DBErr("Unable to add tags", err)
+	}
+	return serializer.Response{}
+}
+
+// Remove 移除对象的标签
+func (service *ObjectTagService) Remove(c *gin.Context, user *models.User) serializer.Response {
+	tagIDs, folderIDs, fileIDs, err := service.resolve(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), nil)
+	}
+
+	if err := models.RemoveObjectTags(tagIDs, folderIDs, fileIDs); err != nil {
+		return serializer.DBErr("Unable to remove tags", err)
+	}
+	return serializer.Response{}
+}
+
+// resolve 解码并校验标签与对象均属于当前用户
+func (service *ObjectTagService) resolve(user *models.User) (tagIDs, folderIDs, fileIDs []uint, err error) {
+	items, err := service.Src.Owned(user)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, raw := range service.Tags {
+		id, err := hashid.DecodeHashID(raw, hashid.TagID)
+		if err != nil {
+			return nil, nil, nil, errors.New("tag not exist")
+		}
+		tagIDs = append(tagIDs, id)
+	}
+
+	if tags, err := models.GetTagsByIDs(tagIDs, models.ManualTagType, user.ID); err != nil || len(tags) != len(tagIDs) {
+		return nil, nil, nil, errors.New("tag not exist")
+	}
+
+	return tagIDs, items.Dirs, items.Items, nil
+}
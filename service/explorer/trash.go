@@ -0,0 +1,84 @@
+package explorer
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"github.com/jylc/cloudserver/pkg/serializer"
+)
+
+// TrashListService 列出回收站服务
+type TrashListService struct {
+	Page    uint   `form:"page" binding:"required,min=1"`
+	OrderBy string `form:"order_by" binding:"required,eq=created_at|eq=name|eq=size"`
+	Order   string `form:"order" binding:"required,eq=DESC|eq=ASC"`
+}
+
+// TrashItemService 回收站对象操作服务，ID 为空时表示回收站中的全部对象
+type TrashItemService struct {
+	IDs []string `json:"ids"`
+}
+
+// List 列出回收站中的对象
+func (service *TrashListService) List(c *gin.Context, user *models.User) serializer.Response {
+	trashes, total := models.ListTrash(user.ID, int(service.Page), 50, service.OrderBy+" "+service.Order)
+	return serializer.BuildTrashList(trashes, total)
+}
+
+// Restore 还原回收站中的对象
+func (service *TrashItemService) Restore(ctx context.Context, c *gin.Context, user *models.User) serializer.Response {
+	if len(service.IDs) == 0 {
+		return serializer.ParamErr("No object selected", nil)
+	}
+
+	trashes, err := service.trashes(user)
+	if err != nil {
+		return serializer.DBErr("Unable to list trashed objects", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	if err := fs.Restore(ctx, trashes); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+	return serializer.Response{}
+}
+
+// Purge 永久删除回收站中的对象
+func (service *TrashItemService) Purge(ctx context.Context, c *gin.Context, user *models.User) serializer.Response {
+	trashes, err := service.trashes(user)
+	if err != nil {
+		return serializer.DBErr("Unable to list trashed objects", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	if err := fs.Purge(ctx, trashes); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+	return serializer.Response{}
+}
+
+func (service *TrashItemService) trashes(user *models.User) ([]models.Trash, error) {
+	if len(service.IDs) == 0 {
+		return models.GetTrashByUser(user.ID)
+	}
+
+	ids := make([]uint, 0, len(service.IDs))
+	for _, raw := range service.IDs {
+		if id, err := hashid.DecodeHashID(raw, hashid.TrashID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return models.GetTrashByIDs(ids, user.ID)
+}
@@ -0,0 +1,364 @@
+package explorer
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/cache"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/checksum"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TusVersion 支持的 tus 协议版本
+	TusVersion = "1.0.0"
+	// TusExtensions 支持的 tus 协议扩展
+	TusExtensions = "creation,termination,checksum"
+	// StatusChecksumMismatch tus 校验和不匹配时的响应状态码
+	StatusChecksumMismatch = 460
+)
+
+// TusResponse tus 协议响应，Message 不为空时作为响应正文
+type TusResponse struct {
+	Status  int
+	Headers map[string]string
+	Message string
+}
+
+// TusUploadService tus 上传会话服务，会话 ID 即原生上传流程的会话 ID
+type TusUploadService struct {
+	ID string `uri:"sessionId" binding:"required"`
+}
+
+func tusError(status int, msg string) TusResponse {
+	return TusResponse{Status: status, Message: msg}
+}
+
+// TusOptions 返回服务端支持的协议版本与扩展
+func TusOptions(c *gin.Context, user *models.User) TusResponse {
+	headers := map[string]string{
+		"Tus-Version":            TusVersion,
+		"Tus-Extension":          TusExtensions,
+		"Tus-Checksum-Algorithm": "md5,sha1,sha256,crc32c",
+	}
+
+	if user != nil && user.Policy.MaxSize > 0 {
+		headers["Tus-Max-Size"] = strconv.FormatUint(user.Policy.MaxSize, 10)
+	}
+
+	return TusResponse{Status: http.StatusNoContent, Headers: headers}
+}
+
+// TusCreate 创建上传会话，文件名、目录等通过 Upload-Metadata 传递，
+// 与原生流程一样会校验文件大小、扩展名与容量
+func TusCreate(ctx context.Context, c *gin.Context) TusResponse {
+	size, err := strconv.ParseUint(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		return tusError(http.StatusBadRequest, "Invalid Upload-Length")
+	}
+
+	meta, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		return tusError(http.StatusBadRequest, "Invalid Upload-Metadata")
+	}
+
+	name := meta["filename"]
+	if name == "" {
+		name = meta["name"]
+	}
+	if name == "" {
+		return tusError(http.StatusBadRequest, "File name is required in Upload-Metadata")
+	}
+
+	virtualPath := meta["path"]
+	if virtualPath == "" {
+		virtualPath = "/"
+	}
+
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return tusError(http.StatusForbidden, err.Error())
+	}
+	defer fs.Recycle()
+
+	if policyID := meta["policy_id"]; policyID != "" {
+		if rawID, err := hashid.DecodeHashID(policyID, hashid.PolicyID); err != nil || rawID != fs.Policy.ID {
+			return tusError(http.StatusPreconditionFailed, "The storage policy has changed")
+		}
+	}
+
+	// 文件内容须经主机中转写入
+	if !fs.Policy.IsTransitUpload(size) {
+		return tusError(http.StatusForbidden, "Storage policy not supported")
+	}
+
+	file := &fsctx.FileStream{
+		Size:        size,
+		Name:        name,
+		VirtualPath: virtualPath,
+		File:        ioutil.NopCloser(strings.NewReader("")),
+	}
+
+	// 可选的完整文件校验和，格式同 Digest 头
+	if digest := meta["digest"]; digest != "" {
+		if _, err := checksum.Parse(digest); err != nil {
+			return tusError(http.StatusBadRequest, "Invalid digest")
+		}
+		file.Digest = digest
+	}
+
+	if lastModified, err := strconv.ParseInt(meta["last_modified"], 10, 64); err == nil && lastModified > 0 {
+		t := time.UnixMilli(lastModified)
+		file.LastModified = &t
+	}
+
+	credential, err := fs.CreateUploadSession(ctx, file)
+	if err != nil {
+		return tusError(tusErrorStatus(err), err.Error())
+	}
+
+	res := TusResponse{
+		Status: http.StatusCreated,
+		Headers: map[string]string{
+			"Location": path.Join(c.Request.URL.Path, credential.SessionID),
+		},
+	}
+
+	// 空文件无需传输内容，直接完成上传
+	if size == 0 {
+		fs.CleanHooks("")
+		service := &TusUploadService{ID: credential.SessionID}
+		if patchRes := service.append(ctx, c, fs, 0, 0, nil); patchRes.Status != http.StatusNoContent {
+			return patchRes
+		}
+	}
+
+	return res
+}
+
+// Head 查询已上传的字节数
+func (service *TusUploadService) Head(ctx context.Context, c *gin.Context) TusResponse {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return tusError(http.StatusForbidden, err.Error())
+	}
+	defer fs.Recycle()
+
+	session, file, res := service.session(fs)
+	if file == nil {
+		return res
+	}
+
+	return TusResponse{
+		Status: http.StatusOK,
+		Headers: map[string]string{
+			"Upload-Offset": strconv.FormatUint(file.Size, 10),
+			"Upload-Length": strconv.FormatUint(session.Size, 10),
+			"Cache-Control": "no-store",
+		},
+	}
+}
+
+// Patch 从 Upload-Offset 处追加文件内容
+func (service *TusUploadService) Patch(ctx context.Context, c *gin.Context) TusResponse {
+	if c.ContentType() != "application/offset+octet-stream" {
+		return tusError(http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+	}
+
+	offset, err := strconv.ParseUint(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		return tusError(http.StatusBadRequest, "Invalid Upload-Offset")
+	}
+
+	if c.Request.ContentLength < 0 {
+		return tusError(http.StatusLengthRequired, "Content-Length is required")
+	}
+
+	var expected *checksum.Checksum
+	if header := c.GetHeader("Upload-Checksum"); header != "" {
+		parts := strings.SplitN(header, " ", 2)
+		if len(parts) != 2 {
+			return tusError(http.StatusBadRequest, "Invalid Upload-Checksum")
+		}
+
+		value, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return tusError(http.StatusBadRequest, "Invalid Upload-Checksum")
+		}
+
+		if expected, err = checksum.New(parts[0], value); err != nil {
+			return tusError(http.StatusBadRequest, "Unsupported checksum algorithm")
+		}
+	}
+
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return tusError(http.StatusForbidden, err.Error())
+	}
+	defer fs.Recycle()
+
+	return service.append(ctx, c, fs, offset, uint64(c.Request.ContentLength), expected)
+}
+
+// Delete 终止上传并删除已上传的内容
+func (service *TusUploadService) Delete(ctx context.Context, c *gin.Context) TusResponse {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return tusError(http.StatusForbidden, err.Error())
+	}
+	defer fs.Recycle()
+
+	_, file, res := service.session(fs)
+	if file == nil {
+		return res
+	}
+
+	if err := fs.Delete(ctx, []uint{}, []uint{file.ID}, false); err != nil {
+		return tusError(http.StatusInternalServerError, err.Error())
+	}
+	return TusResponse{Status: http.StatusNoContent}
+}
+
+// session 获取当前用户的上传会话及占位文件，占位文件大小即已上传的字节数
+func (service *TusUploadService) session(fs *filesystem.FileSystem) (*serializer.UploadSession, *models.File, TusResponse) {
+	sessionRaw, ok := cache.Get(filesystem.UploadSessionCachePrefix + service.ID)
+	if !ok {
+		return nil, nil, tusError(http.StatusNotFound, "Upload session expired or not exist")
+	}
+
+	session := sessionRaw.(serializer.UploadSession)
+	if session.UID != fs.User.ID {
+		return nil, nil, tusError(http.StatusNotFound, "Upload session expired or not exist")
+	}
+
+	file, err := models.GetFilesByUploadSession(service.ID, fs.User.ID)
+	if err != nil {
+		return nil, nil, tusError(http.StatusNotFound, "Upload session file placeholder not exist")
+	}
+
+	return &session, file, TusResponse{}
+}
+
+// append 以原生分片上传相同的钩子写入内容，expected 不为空时校验内容的校验和，
+// 校验失败时截断已写入的内容
+func (service *TusUploadService) append(ctx context.Context, c *gin.Context, fs *filesystem.FileSystem, offset, size uint64, expected *checksum.Checksum) TusResponse {
+	session, file, res := service.session(fs)
+	if file == nil {
+		return res
+	}
+
+	if offset != file.Size {
+		return tusError(http.StatusConflict, "Upload-Offset does not match the current offset")
+	}
+
+	if offset+size > session.Size {
+		return tusError(http.StatusRequestEntityTooLarge, "Upload exceeds Upload-Length")
+	}
+
+	fs.Policy = &session.Policy
+	if err := fs.DispatchHandler(); err != nil {
+		return tusError(http.StatusForbidden, "Unknown storage policy")
+	}
+
+	var body io.ReadCloser = ioutil.NopCloser(strings.NewReader(""))
+	if size > 0 {
+		body = c.Request.Body
+	}
+	var verifier *checksum.Reader
+	if expected != nil {
+		verifier = checksum.NewReader(body, expected)
+		body = verifier
+	}
+	hasher := filesystem.NewChunkHasher(session, offset)
+	if hasher != nil {
+		body = hasher.Wrap(body)
+	}
+
+	mode := fsctx.Append
+	if offset > 0 {
+		mode |= fsctx.Overwrite
+	}
+
+	fileData := fsctx.FileStream{
+		File:         body,
+		Size:         size,
+		Name:         session.Name,
+		VirtualPath:  session.VirtualPath,
+		SavePath:     session.SavePath,
+		Mode:         mode,
+		AppendStart:  offset,
+		Model:        file,
+		LastModified: session.LastModified,
+	}
+
+	fs.Use("AfterUploadCanceled", filesystem.HookTruncateFileTo(offset))
+	fs.Use("AfterValidateFailed", filesystem.HookTruncateFileTo(offset))
+	if verifier != nil {
+		fs.Use("AfterUpload", filesystem.HookVerifyChecksum(verifier))
+	}
+	useChunkHooks(fs, session, hasher, offset+size == session.Size)
+
+	uploadCtx := context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.Upload(uploadCtx, &fileData); err != nil {
+		return tusError(tusErrorStatus(err), err.Error())
+	}
+
+	return TusResponse{
+		Status: http.StatusNoContent,
+		Headers: map[string]string{
+			"Upload-Offset": strconv.FormatUint(offset+size, 10),
+		},
+	}
+}
+
+// tusErrorStatus 将上传错误转换为响应状态码
+func tusErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, checksum.ErrMismatch):
+		return StatusChecksumMismatch
+	case errors.Is(err, filesystem.ErrInsufficientCapacity), errors.Is(err, filesystem.ErrFileSizeTooBig):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, filesystem.ErrFileExtensionNotAllowed), errors.Is(err, filesystem.ErrIllegalObjectName):
+		return http.StatusForbidden
+	case errors.Is(err, filesystem.ErrFileExisted), errors.Is(err, filesystem.ErrFileUploadSessionExisted):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// parseTusMetadata 解析 Upload-Metadata，格式为逗号分隔的 key base64(value)
+func parseTusMetadata(raw string) (map[string]string, error) {
+	res := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, " ", 2)
+		if len(parts) == 1 {
+			res[parts[0]] = ""
+			continue
+		}
+
+		value, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		res[parts[0]] = string(value)
+	}
+	return res, nil
+}
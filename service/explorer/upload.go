@@ -8,11 +8,13 @@ import (
 	"github.com/jylc/cloudserver/pkg/auth"
 	"github.com/jylc/cloudserver/pkg/cache"
 	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/checksum"
 	"github.com/jylc/cloudserver/pkg/filesystem/driver/local"
 	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
 	"github.com/jylc/cloudserver/pkg/hashid"
 	"github.com/jylc/cloudserver/pkg/serializer"
 	"github.com/sirupsen/logrus"
+	"io"
 	"io/ioutil"
 	"strconv"
 	"strings"
@@ -30,6 +32,10 @@ type CreateUploadSessionService struct {
 	Name         string `json:"name" binding:"required"`
 	PolicyID     string `json:"policy_id" binding:"required"`
 	LastModified int64  `json:"last_modified"`
+	// SHA256 文件内容哈希，存储策略下已有相同文件时秒传
+	SHA256 string `json:"sha256" binding:"omitempty,len=64,hexadecimal"`
+	// Digest 完整文件的校验和，格式同 Digest 头，如 md5=base64、sha-256=base64、crc32c=base64
+	Digest string `json:"digest" binding:"omitempty,max=255"`
 }
 
 func (service *CreateUploadSessionService) Create(ctx context.Context, c *gin.Context) serializer.Response {
@@ -47,11 +53,24 @@ func (service *CreateUploadSessionService) Create(ctx context.Context, c *gin.Co
 		return serializer.Err(serializer.CodePolicyNotAllowed, "The storage policy has changed, please refresh the file list and add this task again", nil)
 	}
 
+	if service.Digest != "" {
+		if _, err := checksum.Parse(service.Digest); err != nil {
+			return serializer.ParamErr("Invalid digest: "+err.Error(), err)
+		}
+
+		// 客户端直传至存储端时主机无法读取内容，不能校验完整文件的校验和
+		if !fs.Policy.IsTransitUpload(service.Size) && fs.Policy.Type != "remote" {
+			return serializer.ParamErr("Digest is not supported by the current storage policy", nil)
+		}
+	}
+
 	file := &fsctx.FileStream{
 		Size:        service.Size,
 		Name:        service.Name,
 		VirtualPath: service.Path,
 		File:        ioutil.NopCloser(strings.NewReader("")),
+		SHA256:      strings.ToLower(service.SHA256),
+		Digest:      service.Digest,
 	}
 
 	if service.LastModified > 0 {
@@ -72,7 +91,7 @@ func (service *CreateUploadSessionService) Create(ctx context.Context, c *gin.Co
 func (service *UploadService) SlaveUpload(ctx context.Context, c *gin.Context) serializer.Response {
 	uploadSessionRaw, ok := cache.Get(filesystem.UploadSessionCachePrefix + service.ID)
 	if !ok {
-		serializer.Err(serializer.CodeUploadSessionExpired, "slave upload session expired or not exist", nil)
+		return serializer.Err(serializer.CodeUploadSessionExpired, "slave upload session expired or not exist", nil)
 	}
 	uploadSession := uploadSessionRaw.(serializer.UploadSession)
 
@@ -110,9 +129,34 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 		mode |= fsctx.Overwrite
 	}
 
+	// 分片校验和通过 Digest 头提供
+	var (
+		body     io.ReadCloser = c.Request.Body
+		verifier *checksum.Reader
+	)
+	if digest := c.GetHeader("Digest"); digest != "" {
+		expected, err := checksum.Parse(digest)
+		if err != nil {
+			return serializer.ParamErr("Invalid Digest header: "+err.Error(), err)
+		}
+		verifier = checksum.NewReader(body, expected)
+		body = verifier
+	}
+
+	// 主机接收的分片按顺序累加计算完整文件的哈希，用于去重及校验秒传的文件内容
+	var hasher *filesystem.ChunkHasher
+	if file != nil {
+		hasher = filesystem.NewChunkHasher(session, chunkSize*uint64(index))
+		if hasher != nil {
+			body = hasher.Wrap(body)
+		} else if session.SHA256 != "" {
+			return serializer.Err(serializer.CodeInvalidChunkIndex, "Chunk must be uploaded in order", nil)
+		}
+	}
+
 	fileData := fsctx.FileStream{
 		MIMEType:     c.Request.Header.Get("Content-Type"),
-		File:         c.Request.Body,
+		File:         body,
 		Size:         fileSize,
 		Name:         session.Name,
 		VirtualPath:  session.VirtualPath,
@@ -123,20 +167,24 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 		LastModified: session.LastModified,
 	}
 
-	fs.Use("AfterUploadCanceled", filesystem.HookTruncateFileTo(fileData.AppendStart))
-	fs.Use("AfterValidateFailed", filesystem.HookTruncateFileTo(fileData.AppendStart))
+	// 引用已有物理文件时分片不写入，无需截断
+	if session.SHA256 != "" && file != nil {
+		fileData.Mode |= fsctx.Nop
+	} else {
+		fs.Use("AfterUploadCanceled", filesystem.HookTruncateFileTo(fileData.AppendStart))
+		fs.Use("AfterValidateFailed", filesystem.HookTruncateFileTo(fileData.AppendStart))
+	}
+	if verifier != nil {
+		fs.Use("AfterUpload", filesystem.HookVerifyChecksum(verifier))
+	}
 
 	if file != nil {
-		fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
-		fs.Use("AfterUpload", filesystem.HookChunkUploaded)
-		fs.Use("AfterValidateFailed", filesystem.HookChunkUploadFailed)
-		if isLastChunk {
-			fs.Use("AfterUpload", filesystem.HookPopPlaceholderToFile(""))
-			fs.Use("AfterUpload", filesystem.HookGenerateThumb)
-			fs.Use("AfterUpload", filesystem.HookDeleteUploadSession(session.Key))
-		}
+		useChunkHooks(fs, session, hasher, isLastChunk)
 	} else {
 		if isLastChunk {
+			if session.Digest != "" {
+				fs.Use("AfterUpload", filesystem.HookVerifyFileDigest(session.Digest))
+			}
 			fs.Use("AfterUpload", filesystem.SlaveAfterUpload(session))
 			fs.Use("AfterUpload", filesystem.HookDeleteUploadSession(session.Key))
 		}
@@ -150,6 +198,41 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 	return serializer.Response{}
 }
 
+// useChunkHooks 为写入占位文件的分片注册钩子，最后一个分片完成后转为正式文件
+func useChunkHooks(fs *filesystem.FileSystem, session *serializer.UploadSession, hasher *filesystem.ChunkHasher, isLastChunk bool) {
+	fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+	if session.SHA256 != "" {
+		fs.Use("BeforeUpload", filesystem.HookDrainChunk)
+	}
+	fs.Use("AfterUpload", filesystem.HookChunkUploaded)
+	if hasher != nil {
+		fs.Use("AfterUpload", filesystem.HookSaveChunkHash(hasher))
+	}
+	fs.Use("AfterValidateFailed", filesystem.HookChunkUploadFailed)
+	if isLastChunk {
+		if session.SHA256 != "" {
+			fs.Use("AfterUpload", filesystem.HookVerifyLinkedContent(session))
+		}
+		if session.Digest != "" {
+			fs.Use("AfterUpload", filesystem.HookVerifyFileDigest(session.Digest))
+		}
+		fs.Use("AfterUpload", filesystem.HookPopPlaceholderToFile(""))
+		if session.SHA256 != "" {
+			fs.Use("AfterUpload", filesystem.HookLinkBlob)
+		} else {
+			fs.Use("AfterUpload", filesystem.HookSaveSHA256)
+		}
+		fs.Use("AfterUpload", filesystem.HookGenerateThumb)
+		fs.Use("AfterUpload", filesystem.HookExtractMetadata)
+		fs.Use("AfterUpload", filesystem.HookIndexContent)
+		fs.Use("AfterUpload", filesystem.HookRecordRecent(models.RecentActionUpload))
+		if session.SHA256 == "" {
+			fs.Use("AfterUpload", filesystem.HookDeduplicate)
+		}
+		fs.Use("AfterUpload", filesystem.HookDeleteUploadSession(session.Key))
+	}
+}
+
 type UploadSessionService struct {
 	ID string `uri:"sessionId" binding:"required"`
 }
@@ -176,6 +259,8 @@ func (service *UploadSessionService) SlaveDelete(ctx context.Context, c *gin.Con
 		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
 	}
 	defer fs.Recycle()
+	fs.Handler = local.Driver{}
+
 	session, ok := cache.Get(filesystem.UploadSessionCachePrefix + service.ID)
 	if !ok {
 		return serializer.Err(serializer.CodeUploadSessionExpired, "Slave Upload session file placeholder not exist", nil)
@@ -229,6 +314,11 @@ func (service *UploadService) LocalUpload(ctx context.Context, c *gin.Context) s
 		return serializer.Err(serializer.CodeInvalidChunkIndex, "Chunk must be uploaded in order", nil)
 	}
 
+	// 哈希只能按顺序累加，引用已有物理文件时不能重传已完成的分片
+	if uploadSession.SHA256 != "" && expextedSizeStart != actualSizeStart {
+		return serializer.Err(serializer.CodeInvalidChunkIndex, "Chunk must be uploaded in order", nil)
+	}
+
 	if expextedSizeStart > actualSizeStart {
 		logrus.Info("Attempt to upload overlay fragment [%d] start=%d", service.Index, actualSizeStart)
 	}
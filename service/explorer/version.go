@@ -0,0 +1,121 @@
+package explorer
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"net/http"
+	"net/url"
+)
+
+// VersionService 文件历史版本服务
+type VersionService struct {
+	Version string `uri:"version" binding:"required"`
+}
+
+// ListVersions 列出文件的历史版本
+func (service *FileIDService) ListVersions(c *gin.Context, user *models.User) serializer.Response {
+	file, err := versionTarget(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "file does not exist", err)
+	}
+
+	versions, err := models.GetVersionsByFile(file.ID, user.ID)
+	if err != nil {
+		return serializer.DBErr("Unable to list versions", err)
+	}
+	return serializer.BuildVersionList(versions)
+}
+
+// Download 下载文件的历史版本
+func (service *VersionService) Download(ctx context.Context, c *gin.Context, user *models.User) serializer.Response {
+	file, version, err := service.target(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "version does not exist", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	rs, err := fs.GetVersionContent(ctx, file, version)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+	defer rs.Close()
+
+	c.Header("Content-Disposition", "attachment; filename=\""+url.PathEscape(file.Name)+"\"")
+	setDigestHeaders(c, version.SHA256)
+	http.ServeContent(c.Writer, c.Request, file.Name, version.CreatedAt, rs)
+	return serializer.Response{}
+}
+
+// Restore 将文件恢复至历史版本
+func (service *VersionService) Restore(ctx context.Context, c *gin.Context, user *models.User) serializer.Response {
+	file, version, err := service.target(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "version does not exist", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	if err := fs.RestoreVersion(ctx, file, version); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+	return serializer.Response{}
+}
+
+// Delete 删除文件的历史版本
+func (service *VersionService) Delete(ctx context.Context, c *gin.Context, user *models.User) serializer.Response {
+	_, version, err := service.target(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "version does not exist", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	if failed := fs.DeleteVersions(ctx, []models.FileVersion{*version}); failed > 0 {
+		return serializer.Err(serializer.CodeNotSet, "Unable to delete version", nil)
+	}
+	return serializer.Response{}
+}
+
+func (service *VersionService) target(c *gin.Context, user *models.User) (*models.File, *models.FileVersion, error) {
+	file, err := versionTarget(c, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	id, err := hashid.DecodeHashID(service.Version, hashid.VersionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	version, err := models.GetVersionByID(id, file.ID, user.ID)
+	return file, version, err
+}
+
+// versionTarget 获取请求中的文件
+func versionTarget(c *gin.Context, user *models.User) (*models.File, error) {
+	fileID, _ := c.Get("object_id")
+	files, err := models.GetFilesByIDs([]uint{fileID.(uint)}, user.ID)
+	if err != nil || len(files) == 0 {
+		return nil, filesystem.ErrObjectNotExist.WithError(err)
+	}
+	return &files[0], nil
+}
@@ -0,0 +1,143 @@
+package share
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jylc/cloudserver/models"
+	"github.com/jylc/cloudserver/pkg/filesystem"
+	"github.com/jylc/cloudserver/pkg/filesystem/fsctx"
+	"github.com/jylc/cloudserver/pkg/hashid"
+	"github.com/jylc/cloudserver/pkg/serializer"
+	"github.com/jylc/cloudserver/service/explorer"
+	"net/http"
+)
+
+// AlbumListService 列出分享相册中文件的服务
+type AlbumListService struct {
+	Page     uint `form:"page" binding:"required,min=1"`
+	PageSize uint `form:"page_size" binding:"omitempty,min=1,max=500"`
+}
+
+// AlbumFileService 分享相册中单个文件的服务
+type AlbumFileService struct {
+}
+
+// List 分页列出分享相册中的文件
+func (service *AlbumListService) List(c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*models.Share)
+
+	if !share.IsAlbum {
+		return serializer.ParamErr("This share is not an album", nil)
+	}
+
+	fs, err := filesystem.NewFileSystem(share.Creator())
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = context.WithValue(ctx, fsctx.ShareKeyCtx, hashid.HashID(share.ID, hashid.ShareID))
+
+	pageSize := service.PageSize
+	if pageSize == 0 {
+		pageSize = 100
+	}
+
+	album := share.SourceAlbum()
+	objects, total := fs.ListAlbum(ctx, album, int(service.Page), int(pageSize))
+	return serializer.BuildAlbumObjects(album, objects, total)
+}
+
+// CreateDownloadSession 创建分享相册中文件的下载会话
+func (service *AlbumFileService) CreateDownloadSession(c *gin.Context) serializer.Response {
+	file, res := service.file(c)
+	if file == nil {
+		return res
+	}
+
+	userCtx, _ := c.Get("user")
+	fs, err := filesystem.NewFileSystem(userCtx.(*models.User))
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	fs.SetTargetFile(&[]models.File{*file})
+	downloadURL, err := fs.GetDownloadURL(context.Background(), 0, "download_timeout")
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: downloadURL,
+	}
+}
+
+// PreviewContent 预览分享相册中的文件
+func (service *AlbumFileService) PreviewContent(ctx context.Context, c *gin.Context) serializer.Response {
+	file, res := service.file(c)
+	if file == nil {
+		return res
+	}
+
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, file)
+	subService := explorer.FileIDService{}
+	return subService.PreviewContent(ctx, c, false)
+}
+
+// Thumb 获取分享相册中文件的缩略图
+func (service *AlbumFileService) Thumb(c *gin.Context) serializer.Response {
+	file, res := service.file(c)
+	if file == nil {
+		return res
+	}
+
+	shareCtx, _ := c.Get("share")
+	fs, err := filesystem.NewFileSystem(shareCtx.(*models.Share).Creator())
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	resp, err := fs.GetThumb(context.Background(), file.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Unable to get thumbnail", err)
+	}
+
+	if resp.Redirect {
+		c.Header("Cache-Control", fmt.Sprintf("max-age=%d", resp.MaxAge))
+		c.Redirect(http.StatusMovedPermanently, resp.URL)
+		return serializer.Response{Code: -1}
+	}
+
+	defer resp.Content.Close()
+	http.ServeContent(c.Writer, c.Request, "thumb.png", fs.FileTarget[0].UpdatedAt, resp.Content)
+
+	return serializer.Response{Code: -1}
+}
+
+// file 获取路由参数指定的相册文件，文件不在相册中时返回错误响应
+func (service *AlbumFileService) file(c *gin.Context) (*models.File, serializer.Response) {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*models.Share)
+
+	if !share.IsAlbum {
+		return nil, serializer.ParamErr("This share is not an album", nil)
+	}
+
+	fileID, err := hashid.DecodeHashID(c.Param("file"), hashid.FileID)
+	if err != nil {
+		return nil, serializer.ParamErr("Unable to resolve file ID", err)
+	}
+
+	file, ok := share.SourceAlbum().HasFile(fileID)
+	if !ok {
+		return nil, serializer.Err(serializer.CodeNotFound, "File not exist", nil)
+	}
+	return file, serializer.Response{}
+}
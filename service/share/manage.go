@@ -12,6 +12,7 @@ import (
 type ShareCreateService struct {
 	SourceID        string `json:"id" binding:"required"`
 	IsDir           bool   `json:"is_dir"`
+	IsAlbum         bool   `json:"is_album"`
 	Password        string `json:"password" binding:"max=255"`
 	RemainDownloads int    `json:"downloads"`
 	Expire          int    `json:"expire"`
@@ -72,7 +73,9 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 		sourceName string
 		err        error
 	)
-	if service.IsDir {
+	if service.IsAlbum {
+		sourceID, err = hashid.DecodeHashID(service.SourceID, hashid.AlbumID)
+	} else if service.IsDir {
 		sourceID, err = hashid.DecodeHashID(service.SourceID, hashid.FolderID)
 	} else {
 		sourceID, err = hashid.DecodeHashID(service.SourceID, hashid.FileID)
@@ -82,7 +85,14 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 	}
 
 	exist := true
-	if service.IsDir {
+	if service.IsAlbum {
+		album, err := models.GetAlbumByID(sourceID, user.ID)
+		if err != nil {
+			exist = false
+		} else {
+			sourceName = album.Name
+		}
+	} else if service.IsDir {
 		folder, err := models.GetFoldersByIDs([]uint{sourceID}, user.ID)
 		if err != nil || len(folder) == 0 {
 			exist = false
@@ -103,7 +113,8 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 
 	newShare := models.Share{
 		Password:        service.Password,
-		IsDir:           service.IsDir,
+		IsDir:           service.IsDir && !service.IsAlbum,
+		IsAlbum:         service.IsAlbum,
 		UserID:          user.ID,
 		SourceID:        sourceID,
 		RemainDownloads: -1,